@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/labstack/echo"
+)
+
+// Resource oriented routes under /api/v2. They share the models and list handlers with /api/*,
+// but lists are always PostList and a missing resource is 404 with a *_NOT_FOUND code.
+
+// slugParam returns the path parameter encoded like post_name and slug in WordPress.
+func slugParam(c echo.Context, name string) (string, error) {
+	params, err := parseParams(c, pathRule(slugRule(name)))
+	if err != nil {
+		return "", err
+	}
+
+	slug := params.String(name)
+	if unescaped, err := url.PathUnescape(slug); err == nil {
+		slug = unescaped
+	}
+	return url.QueryEscape(slug), nil
+}
+
+// respondPost responds the post or POST_NOT_FOUND if it is nil.
+func respondPost(c echo.Context, post *Post, err error, notFoundMessage string) error {
+	if err != nil {
+		return err
+	}
+
+	if post == nil {
+		return NewApiError(POST_NOT_FOUND, notFoundMessage)
+	}
+
+	return c.JSON(http.StatusOK, ApiResult{
+		Success: true,
+		Data:    post,
+		Message: "",
+	})
+}
+
+// GetPostV2 handles GET /api/v2/posts/{id}.
+func GetPostV2(c echo.Context) error {
+	params, err := parseParams(c, pathRule(idRule("id")), formatRule)
+	if err != nil {
+		return err
+	}
+	id := params.Int64("id")
+	ctx := withContentFormat(c.Request().Context(), params.String("format"))
+
+	post, err := Post{}.GetPostById(ctx, id)
+	return respondPost(c, post, err, fmt.Sprintf("Post %v Not Found", id))
+}
+
+// GetPostsV2 handles GET /api/v2/posts?ids=1,2,3.
+func GetPostsV2(c echo.Context) error {
+	params, err := parseParams(c, idsRule)
+	if err != nil {
+		return err
+	}
+
+	ids := make([]int64, 0)
+	for _, id := range params.IntList("ids") {
+		ids = append(ids, int64(id))
+	}
+
+	batch, err := Post{}.GetBatch(c.Request().Context(), ids)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, ApiResult{
+		Success: true,
+		Data:    batch,
+		Message: "",
+	})
+}
+
+// GetPostMarkdownV2 handles GET /api/v2/posts/{id}/markdown.
+func GetPostMarkdownV2(c echo.Context) error {
+	params, err := parseParams(c, pathRule(idRule("id")))
+	if err != nil {
+		return err
+	}
+	id := params.Int64("id")
+
+	// all fields for the front matter, and the content as HTML to convert
+	ctx := withContentFormat(withFields(c.Request().Context(), nil), HTML_FORMAT)
+	post, err := Post{}.GetPostById(ctx, id)
+	if err != nil {
+		return err
+	}
+	if post == nil {
+		return NewApiError(POST_NOT_FOUND, fmt.Sprintf("Post %v Not Found", id))
+	}
+
+	c.Response().Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="%v.md"`, post.ID))
+	return c.Blob(http.StatusOK, MIME_MARKDOWN, []byte(PostMarkdown(post)))
+}
+
+// GetPostBySlugV2 handles GET /api/v2/posts/slug/{slug}.
+func GetPostBySlugV2(c echo.Context) error {
+	slug, err := slugParam(c, "slug")
+	if err != nil {
+		return err
+	}
+	params, err := parseParams(c, formatRule)
+	if err != nil {
+		return err
+	}
+	ctx := withContentFormat(c.Request().Context(), params.String("format"))
+
+	post, err := Post{}.GetByPermalink(ctx, slug)
+	return respondPost(c, post, err, slug+" Not Found")
+}
+
+// GetAuthorV2 handles GET /api/v2/authors/{login}.
+func GetAuthorV2(c echo.Context) error {
+	params, err := parseParams(c, pathRule(slugRule("login")))
+	if err != nil {
+		return err
+	}
+
+	author, err := Author{}.GetByLoginName(c.Request().Context(), params.String("login"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, ApiResult{
+		Success: true,
+		Data:    author,
+		Message: "",
+	})
+}
+
+// GetAuthorPostsV2 handles GET /api/v2/authors/{login}/posts.
+func GetAuthorPostsV2(c echo.Context) error {
+	params, err := parseParams(c, pathRule(slugRule("login")))
+	if err != nil {
+		return err
+	}
+
+	author, err := Author{}.GetByLoginName(c.Request().Context(), params.String("login"))
+	if err != nil {
+		return err
+	}
+
+	return getPostsByAuthor(c, author, true)
+}
+
+// GetTermPostsV2 handles GET /api/v2/tags/{slug}/posts and /api/v2/categories/{slug}/posts.
+func GetTermPostsV2(taxonomy string) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		slug, err := slugParam(c, "slug")
+		if err != nil {
+			return err
+		}
+
+		term, err := Term{}.FinyBySlug(c.Request().Context(), slug, taxonomy)
+		if err != nil {
+			return err
+		}
+
+		return getPostsByTagId(c, term.ID, true)
+	}
+}
+
+// GetPreferenceV2 handles GET /api/v2/preferences/{name}.
+func GetPreferenceV2(c echo.Context) error {
+	params, err := parseParams(c, pathRule(slugRule("name")))
+	if err != nil {
+		return err
+	}
+	name := params.String("name")
+
+	sitePref, err := (SitePreference{}).GetByName(c.Request().Context(), name)
+	if err != nil {
+		return err
+	}
+
+	if sitePref == nil {
+		return NewApiError(PREFERENCE_NOT_FOUND, "No preference ["+name+"]")
+	}
+
+	return c.JSON(http.StatusOK, ApiResult{
+		Success: true,
+		Data:    sitePref,
+		Message: "",
+	})
+}
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"errors"
 	"fmt"
 	"crypto/md5"
 	"context"
@@ -17,80 +16,76 @@ type Author struct {
 	Email string         `json:"-"            xorm:"user_email"`
 }
 
-func (Author) TableName() (string) {
-	return "wprdh0703_users"
-}
-
 func (Author) GetOne(ctx context.Context, id int64) (*Author, error) {
-	var author Author
-
-	exists, err := GetDBConn(ctx).
-		Select("ID, user_login, display_name, user_url, user_email").
-		Where("ID = ?", id).Get(&author)
+	store, err := GetStore(ctx)
+	if err != nil {
+		return nil, err
+	}
 
+	author, err := store.Authors.FindOne(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 
-	if !exists {
-		return nil, errors.New("No Author Record")
+	if author == nil {
+		return nil, NewApiError(AUTHOR_NOT_FOUND, fmt.Sprintf("Author %v not found", id))
 	}
 
-	(&author).initAvatar();
+	addCacheTags(ctx, authorTag(author.ID))
+	author.initAvatar(GetConfig(ctx).GravatarURL);
 
-	return &author, nil
+	return author, nil
 }
 
-func (Author) GetByLoginName(ctx context.Context, loginName string) (*Author, error) {
-	var author Author
-
-	exists, err := GetDBConn(ctx).
-		Select("ID, user_login, display_name, user_url, user_email").
-		Where("user_login = ?", loginName).Get(&author)
+// GetByIds returns authors by id. Authors which do not exist are not in the result.
+func (Author) GetByIds(ctx context.Context, ids []int64) (map[int64]Author, error) {
+	authorMap := make(map[int64]Author)
+	if len(ids) == 0 {
+		return authorMap, nil
+	}
 
-	if !exists {
-		return nil, errors.New("No Author: " + loginName)
+	store, err := GetStore(ctx)
+	if err != nil {
+		return nil, err
 	}
 
+	authors, err := store.Authors.FindByIds(ctx, ids)
 	if err != nil {
 		return nil, err
 	}
 
-	(&author).initAvatar();
+	for _, author := range authors {
+		addCacheTags(ctx, authorTag(author.ID))
+		author.initAvatar(GetConfig(ctx).GravatarURL)
+		authorMap[author.ID] = author
+	}
 
-	return &author, nil
+	return authorMap, nil
 }
 
-func (a *Author)initAvatar() {
-	hash := md5.Sum([]byte(a.Email))
-	a.Avatar = fmt.Sprintf("https://www.gravatar.com/avatar/%x", hash)
-	a.Email = "";
-}
+func (Author) GetByLoginName(ctx context.Context, loginName string) (*Author, error) {
+	store, err := GetStore(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-func (Author) FindAuthorByPostCount(ctx context.Context, numPosts int) ([]Author, error) {
-	var authors []Author
-
-	sql := fmt.Sprintf(`
-		select b.ID, b.user_login, b.display_name, b.user_url, user_email from (
-			SELECT
-				post_author,
-				count(1) AS cnt
-			FROM wprdh0703_posts
-			WHERE post_status = 'publish'
-						AND post_type = 'post'
-			GROUP BY post_author
-			HAVING count(1) > %d
-		) a
-		join wprdh0703_users b on a.post_author = b.ID
-		order by b.ID;
-  `, numPosts - 1)
-
-  if err := GetDBConn(ctx).SQL(sql).Find(&authors); err != nil {
-  	return nil, err
+	author, err := store.Authors.FindByLoginName(ctx, loginName)
+	if err != nil {
+		return nil, err
 	}
 
-	for i := 0; i < len(authors); i++ {
-		authors[i].initAvatar()
+	if author == nil {
+		return nil, NewApiError(AUTHOR_NOT_FOUND, "Author " + loginName + " not found")
 	}
-	return authors, nil
-}
\ No newline at end of file
+
+	addCacheTags(ctx, authorTag(author.ID))
+	author.initAvatar(GetConfig(ctx).GravatarURL);
+
+	return author, nil
+}
+
+func (a *Author)initAvatar(gravatarURL string) {
+	hash := md5.Sum([]byte(a.Email))
+	a.Avatar = fmt.Sprintf("%v%x", gravatarURL, hash)
+	a.Email = "";
+}
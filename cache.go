@@ -0,0 +1,401 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+// API responses are cached in an in-process LRU cache or in Redis shared by API servers.
+// Each response is tagged with the posts, authors and terms it contains so that it can be dropped when they change.
+
+// POST_LIST_TAG is the tag of responses listing all posts(recent posts, number of posts).
+// A new post changes them without changing any cached post, author or term.
+const POST_LIST_TAG = "posts"
+
+// POST_INDEX_VERSION_TTL is how long versions of post id indexes are kept. An expired version loads indexes once more.
+const POST_INDEX_VERSION_TTL = 24 * time.Hour
+
+func postTag(id int64) string {
+	return fmt.Sprintf("post:%v", id)
+}
+
+func authorTag(id int64) string {
+	return fmt.Sprintf("author:%v", id)
+}
+
+func termTag(id int) string {
+	return fmt.Sprintf("term:%v", id)
+}
+
+// authorPostsTag is the tag of lists of the author's posts. A new post of the author changes them.
+func authorPostsTag(id int64) string {
+	return fmt.Sprintf("author_posts:%v", id)
+}
+
+// termPostsTag is the tag of lists of the term's posts. A new post of the term changes them.
+func termPostsTag(id int) string {
+	return fmt.Sprintf("term_posts:%v", id)
+}
+
+// Cache stores JSON encoded values with tags. Values are dropped together by one of their tags.
+type Cache interface {
+	// Get decodes the value of key into value. It returns false if the key is not cached.
+	Get(key string, value interface{}) (bool, error)
+	Set(key string, value interface{}, tags []string, ttl time.Duration) error
+	// Invalidate drops values having one of the tags and returns the number of dropped values.
+	// The time of invalidation of each tag is kept for INVALIDATION_WINDOW.
+	Invalidate(tags ...string) (int, error)
+	// SetUnlessInvalidated sets the value unless one of the tags was invalidated at or after since, in one step
+	// with the check so that no invalidation comes between them. It returns false if the value is not set.
+	// Values are never set if since is older than INVALIDATION_WINDOW because older invalidations are not kept.
+	SetUnlessInvalidated(key string, value interface{}, tags []string, ttl time.Duration, since time.Time) (bool, error)
+	Close() error
+}
+
+// INVALIDATION_WINDOW is how long times of invalidations are kept to find responses loaded before them.
+// Loads longer than it are not cached.
+const INVALIDATION_WINDOW = time.Minute
+
+// INVALIDATION_CLOCK_SKEW is the allowed skew of clocks of API servers comparing times of invalidations.
+const INVALIDATION_CLOCK_SKEW = time.Second
+
+func NewCache(config CacheConfig) (Cache, error) {
+	switch config.Driver {
+	case "redis":
+		return NewRedisCache(config.Redis, config.MaxTTL())
+	default:
+		return NewMemoryCache(config.MaxEntries), nil
+	}
+}
+
+// cachedResponse is a cached API response. Body is the JSON of ApiResult with posts, TermPosts, AuthorPosts, ...
+// Headers are the headers set by the handler, e.g. X-Random-Seed.
+type cachedResponse struct {
+	Status      int             `json:"status"`
+	ContentType string          `json:"contentType"`
+	Headers     http.Header     `json:"headers,omitempty"`
+	Body        json.RawMessage `json:"body"`
+}
+
+// cacheCall is a response being loaded. Concurrent misses of the same key wait for it.
+type cacheCall struct {
+	wg       sync.WaitGroup
+	response *cachedResponse
+}
+
+// ResponseCache caches API responses of all sites in a Cache.
+type ResponseCache struct {
+	config CacheConfig
+	cache  Cache
+	mutex  sync.Mutex
+	calls  map[string]*cacheCall
+}
+
+func NewResponseCache(config CacheConfig) (*ResponseCache, error) {
+	cache, err := NewCache(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ResponseCache{
+		config: config,
+		cache:  cache,
+		calls:  make(map[string]*cacheCall),
+	}, nil
+}
+
+func (c *ResponseCache) enabled(ttl time.Duration) bool {
+	if c.config.Driver == "memory" && c.config.MaxEntries <= 0 {
+		return false
+	}
+	return ttl > 0
+}
+
+func (c *ResponseCache) Get(key string) *cachedResponse {
+	var response cachedResponse
+	has, err := c.cache.Get(key, &response)
+	if err != nil {
+		fmt.Println("ERROR cache get:", err.Error())
+		return nil
+	}
+	if !has {
+		return nil
+	}
+	return &response
+}
+
+// Set caches the response of the site for ttl with tags of the site. A response started loading at loadedAt is not
+// cached if one of its tags was invalidated after that, because it may have been loaded before the change.
+func (c *ResponseCache) Set(key string, site string, response *cachedResponse, tags []string, ttl time.Duration, loadedAt time.Time) {
+	since := loadedAt.Add(-INVALIDATION_CLOCK_SKEW)
+	if _, err := c.cache.SetUnlessInvalidated(key, response, siteTags(site, tags...), ttl, since); err != nil {
+		fmt.Println("ERROR cache set:", err.Error())
+	}
+}
+
+func siteTags(site string, tags ...string) []string {
+	siteTags := make([]string, 0)
+	for _, tag := range tags {
+		siteTags = append(siteTags, site+"|"+tag)
+	}
+	return siteTags
+}
+
+// Invalidate drops the responses of the site having one of the tags and returns the number of dropped responses.
+func (c *ResponseCache) Invalidate(site string, tags ...string) int {
+	removed, err := c.cache.Invalidate(siteTags(site, tags...)...)
+	if err != nil {
+		fmt.Println("ERROR cache invalidate:", err.Error())
+	}
+	return removed
+}
+
+// InvalidatePosts drops responses containing the posts and the lists of all posts.
+func (c *ResponseCache) InvalidatePosts(site string, ids ...int64) int {
+	tags := []string{POST_LIST_TAG}
+	for _, id := range ids {
+		tags = append(tags, postTag(id))
+	}
+	return c.Invalidate(site, tags...)
+}
+
+func (c *ResponseCache) InvalidateAuthors(site string, ids ...int64) int {
+	tags := make([]string, 0)
+	for _, id := range ids {
+		tags = append(tags, authorTag(id))
+	}
+	return c.Invalidate(site, tags...)
+}
+
+func (c *ResponseCache) InvalidateTerms(site string, ids ...int) int {
+	tags := make([]string, 0)
+	for _, id := range ids {
+		tags = append(tags, termTag(id))
+	}
+	return c.Invalidate(site, tags...)
+}
+
+// InvalidateAuthorPosts drops lists of posts of the authors.
+func (c *ResponseCache) InvalidateAuthorPosts(site string, ids ...int64) int {
+	tags := make([]string, 0)
+	for _, id := range ids {
+		tags = append(tags, authorPostsTag(id))
+	}
+	return c.Invalidate(site, tags...)
+}
+
+// InvalidateTermPosts drops lists of posts of the terms.
+func (c *ResponseCache) InvalidateTermPosts(site string, ids ...int) int {
+	tags := make([]string, 0)
+	for _, id := range ids {
+		tags = append(tags, termPostsTag(id))
+	}
+	return c.Invalidate(site, tags...)
+}
+
+func postIndexVersionKey(site string) string {
+	return site + "|post_index_version"
+}
+
+// PostIndexVersion returns the version of post id indexes of the site shared by API servers, 0 if not set.
+func (c *ResponseCache) PostIndexVersion(site string) int64 {
+	var version int64
+	if _, err := c.cache.Get(postIndexVersionKey(site), &version); err != nil {
+		fmt.Println("ERROR cache get:", err.Error())
+	}
+	return version
+}
+
+// NewPostIndexVersion changes the version of post id indexes of the site.
+func (c *ResponseCache) NewPostIndexVersion(site string) {
+	if err := c.cache.Set(postIndexVersionKey(site), time.Now().UnixNano(), nil, POST_INDEX_VERSION_TTL); err != nil {
+		fmt.Println("ERROR cache set:", err.Error())
+	}
+}
+
+func (c *ResponseCache) Close() error {
+	return c.cache.Close()
+}
+
+// load returns the cached response of key. On a miss, only one caller in this process loads the response
+// and the others wait for it. loaded is true for the caller which called load.
+func (c *ResponseCache) load(key string, load func() *cachedResponse) (response *cachedResponse, loaded bool) {
+	if response := c.Get(key); response != nil {
+		return response, false
+	}
+
+	c.mutex.Lock()
+	if call, has := c.calls[key]; has {
+		c.mutex.Unlock()
+		call.wg.Wait()
+		return call.response, false
+	}
+
+	call := &cacheCall{}
+	call.wg.Add(1)
+	c.calls[key] = call
+	c.mutex.Unlock()
+
+	defer func() {
+		c.mutex.Lock()
+		delete(c.calls, key)
+		c.mutex.Unlock()
+		call.wg.Done()
+	}()
+
+	call.response = load()
+	return call.response, true
+}
+
+// cacheKey makes the same key regardless of the order of query parameters.
+// Empty parameters are ignored because handlers treat them as missing.
+func cacheKey(site string, path string, query url.Values) string {
+	normalized := url.Values{}
+	for name, values := range query {
+		if name == "site" {
+			continue
+		}
+		for _, value := range values {
+			if len(value) > 0 {
+				normalized.Add(name, value)
+			}
+		}
+	}
+	return site + "|" + path + "?" + normalized.Encode()
+}
+
+// cacheTags collects tags of a response while it is being loaded.
+type cacheTags struct {
+	mutex sync.Mutex
+	tags  map[string]bool
+}
+
+func (t *cacheTags) list() []string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	tags := make([]string, 0)
+	for tag := range t.tags {
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+func withCacheTags(ctx context.Context, tags *cacheTags) context.Context {
+	return context.WithValue(ctx, "CACHE_TAGS", tags)
+}
+
+// addCacheTags tags the response being loaded. It does nothing if the response is not cached.
+func addCacheTags(ctx context.Context, tags ...string) {
+	collector, ok := ctx.Value("CACHE_TAGS").(*cacheTags)
+	if !ok {
+		return
+	}
+
+	collector.mutex.Lock()
+	defer collector.mutex.Unlock()
+
+	if collector.tags == nil {
+		collector.tags = make(map[string]bool)
+	}
+	for _, tag := range tags {
+		collector.tags[tag] = true
+	}
+}
+
+type responseRecorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+// handlerHeaders returns the headers set or changed by the handler. Content-Type is cached by itself.
+func handlerHeaders(before http.Header, after http.Header) http.Header {
+	headers := http.Header{}
+	for name, values := range after {
+		if name == echo.HeaderContentType || name == echo.HeaderContentLength || reflect.DeepEqual(before[name], values) {
+			continue
+		}
+		headers[name] = values
+	}
+	if len(headers) == 0 {
+		return nil
+	}
+	return headers
+}
+
+// cacheResponse serves successful GET responses from the cache with the headers set by the handler.
+// Responses with "Cache-Control: no-store"(e.g. GraphQL results with errors, random posts without a seed) are not cached.
+func cacheResponse(cache *ResponseCache) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			req := ctx.Request()
+			ttl := cache.config.TTL(ctx.Path())
+			if !cache.enabled(ttl) || req.Method != http.MethodGet {
+				return next(ctx)
+			}
+
+			site := GetSite(req.Context())
+			key := cacheKey(site.Name, req.URL.Path, req.URL.Query())
+
+			var err error
+			response, loaded := cache.load(key, func() *cachedResponse {
+				loadedAt := time.Now()
+				tags := &cacheTags{}
+				ctx.SetRequest(req.WithContext(withCacheTags(req.Context(), tags)))
+
+				res := ctx.Response()
+				recorder := &responseRecorder{ResponseWriter: res.Writer}
+				res.Writer = recorder
+				res.Header().Set("X-Cache", "MISS")
+				before := res.Header().Clone()
+
+				err = next(ctx)
+				res.Writer = recorder.ResponseWriter
+
+				body := recorder.body.Bytes()
+				noStore := res.Header().Get("Cache-Control") == "no-store"
+				if err != nil || res.Status != http.StatusOK || noStore || !json.Valid(body) {
+					return nil
+				}
+
+				response := &cachedResponse{
+					Status:      res.Status,
+					ContentType: res.Header().Get(echo.HeaderContentType),
+					Headers:     handlerHeaders(before, res.Header()),
+					Body:        body,
+				}
+				cache.Set(key, site.Name, response, tags.list(), ttl, loadedAt)
+				return response
+			})
+
+			if loaded {
+				return err
+			}
+
+			// the response failed to load for the other caller
+			if response == nil {
+				return next(ctx)
+			}
+
+			for name, values := range response.Headers {
+				ctx.Response().Header()[name] = values
+			}
+			ctx.Response().Header().Set("X-Cache", "HIT")
+			return ctx.Blob(response.Status, response.ContentType, response.Body)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+func newTestResponseCache(cache Cache) *ResponseCache {
+	return &ResponseCache{
+		config: CacheConfig{Driver: "memory", MaxEntries: 100, TTLSec: 60},
+		cache:  cache,
+		calls:  make(map[string]*cacheCall),
+	}
+}
+
+// testSetUnlessInvalidated checks that values loaded before an invalidation of one of their tags are not set.
+func testSetUnlessInvalidated(t *testing.T, cache Cache) {
+	loadedAt := time.Now()
+	if set, err := cache.SetUnlessInvalidated("post:101", Post{ID: 101}, []string{postTag(101)}, time.Minute, loadedAt); err != nil || !set {
+		t.Fatalf("expected the value to be set, got set=%v err=%v", set, err)
+	}
+
+	if _, err := cache.Invalidate(postTag(101)); err != nil {
+		t.Fatal(err)
+	}
+	if set, _ := cache.SetUnlessInvalidated("post:101", Post{ID: 101}, []string{postTag(102), postTag(101)}, time.Minute, loadedAt); set {
+		t.Fatal("expected post:101 not to be set after it was invalidated while loading")
+	}
+	var post Post
+	if has, _ := cache.Get("post:101", &post); has {
+		t.Fatal("expected post:101 not to be cached")
+	}
+
+	if set, _ := cache.SetUnlessInvalidated("post:101", Post{ID: 101}, []string{postTag(101)}, time.Minute, time.Now().Add(time.Second)); !set {
+		t.Fatal("expected post:101 to be set when loading started after the invalidation")
+	}
+	if has, _ := cache.Get("post:101", &post); !has || post.ID != 101 {
+		t.Fatal("expected post:101 to be cached")
+	}
+
+	if set, _ := cache.SetUnlessInvalidated("post:102", Post{ID: 102}, []string{postTag(102)}, time.Minute, time.Now().Add(-2*INVALIDATION_WINDOW)); set {
+		t.Fatal("expected loads older than the window not to be set")
+	}
+}
+
+// testSetWhileInvalidating invalidates responses while they are being set. A response loaded before an invalidation
+// of its tag must never be left in the cache, whichever of them comes first.
+func testSetWhileInvalidating(t *testing.T, cache Cache) {
+	responses := newTestResponseCache(cache)
+
+	for i := int64(1); i <= 200; i++ {
+		key := fmt.Sprintf("post:%v", i)
+		loadedAt := time.Now()
+
+		set := func() {
+			responses.Set(key, "test", &cachedResponse{Status: 200, Body: []byte("{}")}, []string{postTag(i)}, time.Minute, loadedAt)
+		}
+		invalidate := func() {
+			responses.InvalidatePosts("test", i)
+		}
+
+		// both are started in turns because the one started later tends to run first
+		calls := []func(){set, invalidate}
+		if i%2 == 0 {
+			calls = []func(){invalidate, set}
+		}
+
+		var wg sync.WaitGroup
+		for _, call := range calls {
+			wg.Add(1)
+			go func(call func()) {
+				defer wg.Done()
+				call()
+			}(call)
+		}
+		wg.Wait()
+
+		if responses.Get(key) != nil {
+			t.Fatalf("%v was loaded before it was invalidated but is cached", key)
+		}
+	}
+}
+
+func TestMemoryCacheSetUnlessInvalidated(t *testing.T) {
+	testSetUnlessInvalidated(t, NewMemoryCache(100))
+}
+
+func TestMemoryCacheSetWhileInvalidating(t *testing.T) {
+	testSetWhileInvalidating(t, NewMemoryCache(100))
+}
+
+func TestCacheKey(t *testing.T) {
+	tests := []struct {
+		query string
+		key   string
+	}{
+		{"", "default|/api/PostsByTag?"},
+		{"tag=go&page=2", "default|/api/PostsByTag?page=2&tag=go"},
+		{"page=2&tag=go", "default|/api/PostsByTag?page=2&tag=go"},
+		{"tag=go&page=&size=", "default|/api/PostsByTag?tag=go"},
+		{"tag=go&site=other", "default|/api/PostsByTag?tag=go"},
+		{"excludes=2&excludes=1&tag=go", "default|/api/PostsByTag?excludes=2&excludes=1&tag=go"},
+		{"tag=%EA%B0%9C%EB%B0%9C", "default|/api/PostsByTag?tag=%EA%B0%9C%EB%B0%9C"},
+	}
+
+	for _, test := range tests {
+		query, _ := url.ParseQuery(test.query)
+		if key := cacheKey(DEFAULT_SITE_NAME, "/api/PostsByTag", query); key != test.key {
+			t.Errorf("%q: expected %v, got %v", test.query, test.key, key)
+		}
+	}
+}
+
+// newTestCacheEcho serves the handler at /api/RecentPosts of the default site through cacheResponse.
+func newTestCacheEcho(cache *ResponseCache, handler echo.HandlerFunc) *echo.Echo {
+	site := &Site{Name: DEFAULT_SITE_NAME, Config: DefaultConfig()}
+
+	e := echo.New()
+	e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.SetRequest(c.Request().WithContext(withSite(c.Request().Context(), site)))
+			return next(c)
+		}
+	})
+	e.Use(cacheResponse(cache))
+	e.GET("/api/RecentPosts", handler)
+	return e
+}
+
+func getTestCached(e *echo.Echo, target string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	return rec
+}
+
+func TestCacheResponseInvalidatesByTag(t *testing.T) {
+	tests := []struct {
+		name       string
+		invalidate func(cache *ResponseCache)
+		dropped    bool
+	}{
+		{"post", func(cache *ResponseCache) { cache.InvalidatePosts(DEFAULT_SITE_NAME, 100) }, true},
+		{"author", func(cache *ResponseCache) { cache.InvalidateAuthors(DEFAULT_SITE_NAME, 1) }, true},
+		{"term", func(cache *ResponseCache) { cache.InvalidateTerms(DEFAULT_SITE_NAME, 20) }, true},
+		{"new post", func(cache *ResponseCache) { cache.InvalidatePosts(DEFAULT_SITE_NAME, 999) }, true},
+		{"other author", func(cache *ResponseCache) { cache.InvalidateAuthors(DEFAULT_SITE_NAME, 2) }, false},
+		{"other term", func(cache *ResponseCache) { cache.InvalidateTerms(DEFAULT_SITE_NAME, 21) }, false},
+		{"other site", func(cache *ResponseCache) { cache.InvalidatePosts("other", 100) }, false},
+	}
+
+	for _, test := range tests {
+		cache := newTestResponseCache(NewMemoryCache(100))
+		e := newTestCacheEcho(cache, func(c echo.Context) error {
+			addCacheTags(c.Request().Context(), POST_LIST_TAG, postTag(100), authorTag(1), termTag(20))
+			return c.JSON(http.StatusOK, ApiResult{Success: true, Data: []int{100}})
+		})
+
+		if rec := getTestCached(e, "/api/RecentPosts"); rec.Header().Get("X-Cache") != "MISS" {
+			t.Fatalf("%v: expected the first request to miss", test.name)
+		}
+		if rec := getTestCached(e, "/api/RecentPosts"); rec.Header().Get("X-Cache") != "HIT" {
+			t.Fatalf("%v: expected the second request to hit", test.name)
+		}
+
+		test.invalidate(cache)
+		expected := "HIT"
+		if test.dropped {
+			expected = "MISS"
+		}
+		if rec := getTestCached(e, "/api/RecentPosts"); rec.Header().Get("X-Cache") != expected {
+			t.Errorf("%v: expected %v after the invalidation, got %v", test.name, expected, rec.Header().Get("X-Cache"))
+		}
+	}
+}
+
+func TestCacheResponseLoadsConcurrentMissesOnce(t *testing.T) {
+	var calls int32
+	release := make(chan bool)
+	cache := newTestResponseCache(NewMemoryCache(100))
+	e := newTestCacheEcho(cache, func(c echo.Context) error {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return c.JSON(http.StatusOK, ApiResult{Success: true, Data: []int{100}})
+	})
+
+	const requests = 10
+	recs := make([]*httptest.ResponseRecorder, requests)
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// the order of the parameters does not make another key
+			target := "/api/RecentPosts?size=4&page=1"
+			if i%2 == 0 {
+				target = "/api/RecentPosts?page=1&size=4"
+			}
+			recs[i] = getTestCached(e, target)
+		}(i)
+	}
+
+	// the first request waits in the handler until the others wait for it
+	for {
+		cache.mutex.Lock()
+		call := cache.calls[cacheKey(DEFAULT_SITE_NAME, "/api/RecentPosts", url.Values{"page": {"1"}, "size": {"4"}})]
+		cache.mutex.Unlock()
+		if call != nil {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("expected the handler to be called once, got %v", calls)
+	}
+	misses := 0
+	for _, rec := range recs {
+		if rec.Code != http.StatusOK || rec.Body.String() != recs[0].Body.String() {
+			t.Errorf("unexpected response %v %v", rec.Code, rec.Body.String())
+		}
+		if rec.Header().Get("X-Cache") == "MISS" {
+			misses++
+		}
+	}
+	if misses != 1 {
+		t.Errorf("expected one miss, got %v", misses)
+	}
+}
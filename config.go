@@ -0,0 +1,475 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const DEFAULT_CONFIG_FILE = "config.json"
+
+type Config struct {
+	Listen      string   `json:"listen"`
+	DB          DBConfig `json:"db"`
+	TablePrefix string   `json:"tablePrefix"`
+	// ApiTablePrefix is the prefix of the tables owned by this API(site_prefs, post_external_metas, ...)
+	ApiTablePrefix string `json:"apiTablePrefix"`
+	SiteURL        string `json:"siteUrl"`
+	SearchAPI      string `json:"searchApi"`
+	// SearchIndexAPI is the index API of the search service. Posts are not reindexed if it is empty.
+	SearchIndexAPI string       `json:"searchIndexApi"`
+	SearchPageSize int          `json:"searchPageSize"`
+	GravatarURL    string       `json:"gravatarUrl"`
+	Sites          []SiteConfig `json:"sites"`
+	DefaultSite    string       `json:"defaultSite"`
+	Cache          CacheConfig  `json:"cache"`
+	// WebhookSecret is the HMAC key of WordPress webhooks. Webhooks are rejected if it is empty.
+	WebhookSecret string `json:"webhookSecret"`
+}
+
+// SiteConfig describes one WordPress blog served by this API.
+// Empty fields are inherited from the top level Config.
+type SiteConfig struct {
+	Name           string    `json:"name"`
+	Hosts          []string  `json:"hosts"`
+	DB             *DBConfig `json:"db"`
+	TablePrefix    *string   `json:"tablePrefix"`
+	ApiTablePrefix *string   `json:"apiTablePrefix"`
+	SiteURL        string    `json:"siteUrl"`
+	SearchAPI      string    `json:"searchApi"`
+	SearchIndexAPI string    `json:"searchIndexApi"`
+	SearchPageSize int       `json:"searchPageSize"`
+	WebhookSecret  string    `json:"webhookSecret"`
+}
+
+type DBConfig struct {
+	// Driver is "mysql", "sqlite3", "postgres" or "memory". Conn of the memory driver is the path of a JSON data file.
+	Driver             string `json:"driver"`
+	Conn               string `json:"conn"`
+	MaxOpenConns       int    `json:"maxOpenConns"`
+	MaxIdleConns       int    `json:"maxIdleConns"`
+	ConnMaxLifetimeSec int    `json:"connMaxLifetimeSec"`
+	ShowSQL            bool   `json:"showSql"`
+	// Replicas are DSNs of read replicas. GET requests are served by a healthy replica.
+	Replicas        []string `json:"replicas"`
+	ReplicaCheckSec int      `json:"replicaCheckSec"`
+}
+
+// CacheConfig configures the response cache shared by all sites.
+type CacheConfig struct {
+	// Driver is "memory"(in-process) or "redis"(shared by API servers).
+	Driver string `json:"driver"`
+	// MaxEntries is the maximum number of responses in the memory cache. 0 disables the cache.
+	MaxEntries int `json:"maxEntries"`
+	TTLSec     int `json:"ttlSec"`
+	// EndpointTTLSec overrides TTLSec by API path(/api/RecentPosts, ...). 0 disables caching of the path.
+	EndpointTTLSec map[string]int `json:"endpointTtlSec"`
+	Redis          RedisConfig    `json:"redis"`
+}
+
+type RedisConfig struct {
+	Addr     string `json:"addr"`
+	Password string `json:"password"`
+	DB       int    `json:"db"`
+	// KeyPrefix separates keys of this API from the others in the same Redis database.
+	KeyPrefix string `json:"keyPrefix"`
+}
+
+var tablePrefixPattern = regexp.MustCompile(`^[A-Za-z0-9_]*$`)
+
+func DefaultConfig() *Config {
+	return &Config{
+		Listen: ":8000",
+		DB: DBConfig{
+			Driver:             "mysql",
+			Conn:               "root:@tcp(127.0.0.1:3306)/wordpress?charset=utf8&parseTime=True",
+			MaxOpenConns:       100,
+			MaxIdleConns:       20,
+			ConnMaxLifetimeSec: 60,
+			ReplicaCheckSec:    10,
+		},
+		TablePrefix:    "wprdh0703_",
+		SiteURL:        "https://www.popit.kr/",
+		SearchAPI:      "http://127.0.0.1:8099",
+		SearchPageSize: 10,
+		GravatarURL:    "https://www.gravatar.com/avatar/",
+		Cache: CacheConfig{
+			Driver:     "memory",
+			MaxEntries: 10000,
+			TTLSec:     300,
+			EndpointTTLSec: map[string]int{
+				"/api/Search":                 60,
+				"/api/TagPosts":               30,
+				"/api/RandomAuthorPosts":      30,
+				"/api/GetSlideShareEmbedLink": 3600,
+			},
+			Redis: RedisConfig{
+				Addr:      "127.0.0.1:6379",
+				KeyPrefix: "popit-api:",
+			},
+		},
+	}
+}
+
+// LoadConfig reads defaults, then the JSON config file, then environment variables.
+// If path is empty, config.json is used when it exists.
+func LoadConfig(path string) (*Config, error) {
+	config := DefaultConfig()
+
+	if len(path) == 0 {
+		if _, err := os.Stat(DEFAULT_CONFIG_FILE); err == nil {
+			path = DEFAULT_CONFIG_FILE
+		}
+	}
+
+	if len(path) > 0 {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, config); err != nil {
+			return nil, fmt.Errorf("%v: %v", path, err)
+		}
+	}
+
+	if err := config.applyEnv(); err != nil {
+		return nil, err
+	}
+
+	config.normalize()
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+func (c *Config) applyEnv() error {
+	stringEnvs := map[string]*string{
+		"LISTEN_ADDR":      &c.Listen,
+		"DB_DRIVER":        &c.DB.Driver,
+		"DB_CONN":          &c.DB.Conn,
+		"TABLE_PREFIX":     &c.TablePrefix,
+		"API_TABLE_PREFIX": &c.ApiTablePrefix,
+		"SITE_URL":         &c.SiteURL,
+		"SEARCH_API":       &c.SearchAPI,
+		"SEARCH_INDEX_API": &c.SearchIndexAPI,
+		"GRAVATAR_URL":     &c.GravatarURL,
+		"WEBHOOK_SECRET":   &c.WebhookSecret,
+		"CACHE_DRIVER":     &c.Cache.Driver,
+		"REDIS_ADDR":       &c.Cache.Redis.Addr,
+		"REDIS_PASSWORD":   &c.Cache.Redis.Password,
+	}
+	for name, field := range stringEnvs {
+		if value, has := os.LookupEnv(name); has {
+			*field = value
+		}
+	}
+
+	intEnvs := map[string]*int{
+		"DB_MAX_OPEN_CONNS":        &c.DB.MaxOpenConns,
+		"DB_MAX_IDLE_CONNS":        &c.DB.MaxIdleConns,
+		"DB_CONN_MAX_LIFETIME_SEC": &c.DB.ConnMaxLifetimeSec,
+		"DB_REPLICA_CHECK_SEC":     &c.DB.ReplicaCheckSec,
+		"SEARCH_PAGE_SIZE":         &c.SearchPageSize,
+		"CACHE_MAX_ENTRIES":        &c.Cache.MaxEntries,
+		"CACHE_TTL_SEC":            &c.Cache.TTLSec,
+		"REDIS_DB":                 &c.Cache.Redis.DB,
+	}
+	for name, field := range intEnvs {
+		value, has := os.LookupEnv(name)
+		if !has {
+			continue
+		}
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%v: %v is not a number", name, value)
+		}
+		*field = i
+	}
+
+	if value, has := os.LookupEnv("DB_REPLICAS"); has {
+		c.DB.Replicas = nil
+		for _, dsn := range strings.Split(value, ",") {
+			if dsn = strings.TrimSpace(dsn); len(dsn) > 0 {
+				c.DB.Replicas = append(c.DB.Replicas, dsn)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (c *Config) normalize() {
+	if len(c.SiteURL) > 0 && !strings.HasSuffix(c.SiteURL, "/") {
+		c.SiteURL += "/"
+	}
+	if len(c.GravatarURL) > 0 && !strings.HasSuffix(c.GravatarURL, "/") {
+		c.GravatarURL += "/"
+	}
+	c.SearchAPI = strings.TrimSuffix(c.SearchAPI, "/")
+	c.SearchIndexAPI = strings.TrimSuffix(c.SearchIndexAPI, "/")
+
+	for i := range c.Sites {
+		for j, host := range c.Sites[i].Hosts {
+			c.Sites[i].Hosts[j] = strings.ToLower(strings.TrimSpace(host))
+		}
+	}
+}
+
+func (c *Config) Validate() error {
+	if _, _, err := net.SplitHostPort(c.Listen); err != nil {
+		return fmt.Errorf("listen: %v", err)
+	}
+
+	if !isSupportedDriver(c.DB.Driver) {
+		return fmt.Errorf("db.driver: unsupported driver %v", c.DB.Driver)
+	}
+	if len(c.DB.Conn) == 0 && c.DB.Driver != "memory" {
+		return errors.New("db.conn: must not be empty")
+	}
+	if c.DB.MaxOpenConns <= 0 {
+		return fmt.Errorf("db.maxOpenConns: must be positive, got %v", c.DB.MaxOpenConns)
+	}
+	if c.DB.MaxIdleConns < 0 || c.DB.MaxIdleConns > c.DB.MaxOpenConns {
+		return fmt.Errorf("db.maxIdleConns: must be between 0 and maxOpenConns(%v), got %v", c.DB.MaxOpenConns, c.DB.MaxIdleConns)
+	}
+	if c.DB.ConnMaxLifetimeSec < 0 {
+		return fmt.Errorf("db.connMaxLifetimeSec: must not be negative, got %v", c.DB.ConnMaxLifetimeSec)
+	}
+	if len(c.DB.Replicas) > 0 {
+		if c.DB.Driver == "memory" || c.DB.Driver == "sqlite3" {
+			return fmt.Errorf("db.replicas: not supported by driver %v", c.DB.Driver)
+		}
+		if c.DB.ReplicaCheckSec <= 0 {
+			return fmt.Errorf("db.replicaCheckSec: must be positive, got %v", c.DB.ReplicaCheckSec)
+		}
+		for i, dsn := range c.DB.Replicas {
+			if len(dsn) == 0 || dsn == c.DB.Conn {
+				return fmt.Errorf("db.replicas[%v]: must be a DSN other than db.conn", i)
+			}
+		}
+	}
+
+	switch c.Cache.Driver {
+	case "memory":
+	case "redis":
+		if len(c.Cache.Redis.Addr) == 0 {
+			return errors.New("cache.redis.addr: must not be empty")
+		}
+	default:
+		return fmt.Errorf("cache.driver: unsupported driver %v", c.Cache.Driver)
+	}
+	if c.Cache.MaxEntries < 0 {
+		return fmt.Errorf("cache.maxEntries: must not be negative, got %v", c.Cache.MaxEntries)
+	}
+	if c.Cache.TTLSec < 0 {
+		return fmt.Errorf("cache.ttlSec: must not be negative, got %v", c.Cache.TTLSec)
+	}
+	for path, ttl := range c.Cache.EndpointTTLSec {
+		if ttl < 0 {
+			return fmt.Errorf("cache.endpointTtlSec[%v]: must not be negative, got %v", path, ttl)
+		}
+	}
+
+	if c.SearchPageSize <= 0 {
+		return fmt.Errorf("searchPageSize: must be positive, got %v", c.SearchPageSize)
+	}
+
+	if !tablePrefixPattern.MatchString(c.TablePrefix) {
+		return fmt.Errorf("tablePrefix: only letters, digits and '_' are allowed, got %v", c.TablePrefix)
+	}
+	if !tablePrefixPattern.MatchString(c.ApiTablePrefix) {
+		return fmt.Errorf("apiTablePrefix: only letters, digits and '_' are allowed, got %v", c.ApiTablePrefix)
+	}
+
+	urls := map[string]string{
+		"siteUrl":     c.SiteURL,
+		"searchApi":   c.SearchAPI,
+		"gravatarUrl": c.GravatarURL,
+	}
+	for name, value := range urls {
+		if err := validateHttpURL(value); err != nil {
+			return fmt.Errorf("%v: %v", name, err)
+		}
+	}
+	if len(c.SearchIndexAPI) > 0 {
+		if err := validateHttpURL(c.SearchIndexAPI); err != nil {
+			return fmt.Errorf("searchIndexApi: %v", err)
+		}
+	}
+
+	return c.validateSites()
+}
+
+func (c *Config) validateSites() error {
+	names := make(map[string]bool)
+	hosts := make(map[string]string)
+	tables := make(map[string]string)
+
+	for i, site := range c.Sites {
+		if len(site.Name) == 0 {
+			return fmt.Errorf("sites[%v].name: must not be empty", i)
+		}
+		if names[site.Name] {
+			return fmt.Errorf("sites[%v].name: duplicated site %v", i, site.Name)
+		}
+		names[site.Name] = true
+
+		for _, host := range site.Hosts {
+			if other, has := hosts[host]; has {
+				return fmt.Errorf("sites[%v].hosts: %v is already used by site %v", i, host, other)
+			}
+			hosts[host] = site.Name
+		}
+
+		siteConfig := c.ForSite(site)
+		if err := siteConfig.Validate(); err != nil {
+			return fmt.Errorf("sites[%v](%v).%v", i, site.Name, err)
+		}
+
+		// sites on the same database must not share tables
+		prefixes := map[string]string{
+			"tablePrefix":    siteConfig.TablePrefix,
+			"apiTablePrefix": siteConfig.ApiTablePrefix,
+		}
+		for name, prefix := range prefixes {
+			key := siteConfig.DB.Driver + "|" + siteConfig.DB.Conn + "|" + name + "|" + prefix
+			if other, has := tables[key]; has {
+				return fmt.Errorf("sites[%v](%v).%v: '%v' is already used by site %v on the same database", i, site.Name, name, prefix, other)
+			}
+			tables[key] = site.Name
+		}
+	}
+
+	if len(c.DefaultSite) > 0 && !names[c.DefaultSite] {
+		return fmt.Errorf("defaultSite: unknown site %v", c.DefaultSite)
+	}
+
+	return nil
+}
+
+// ForSite returns a copy of the config with the site's settings applied.
+func (c *Config) ForSite(site SiteConfig) *Config {
+	siteConfig := *c
+	siteConfig.Sites = nil
+	siteConfig.DefaultSite = ""
+
+	if site.DB != nil {
+		if len(site.DB.Driver) > 0 {
+			siteConfig.DB.Driver = site.DB.Driver
+		}
+		if len(site.DB.Conn) > 0 {
+			// replicas of the top level database are not replicas of the site's database
+			siteConfig.DB.Conn = site.DB.Conn
+			siteConfig.DB.Replicas = site.DB.Replicas
+		} else if site.DB.Replicas != nil {
+			siteConfig.DB.Replicas = site.DB.Replicas
+		}
+		if site.DB.MaxOpenConns > 0 {
+			siteConfig.DB.MaxOpenConns = site.DB.MaxOpenConns
+		}
+		if site.DB.MaxIdleConns > 0 {
+			siteConfig.DB.MaxIdleConns = site.DB.MaxIdleConns
+		}
+		if site.DB.ConnMaxLifetimeSec > 0 {
+			siteConfig.DB.ConnMaxLifetimeSec = site.DB.ConnMaxLifetimeSec
+		}
+		if site.DB.ReplicaCheckSec > 0 {
+			siteConfig.DB.ReplicaCheckSec = site.DB.ReplicaCheckSec
+		}
+		siteConfig.DB.ShowSQL = siteConfig.DB.ShowSQL || site.DB.ShowSQL
+	}
+	if site.TablePrefix != nil {
+		siteConfig.TablePrefix = *site.TablePrefix
+	}
+	if site.ApiTablePrefix != nil {
+		siteConfig.ApiTablePrefix = *site.ApiTablePrefix
+	}
+	if len(site.SiteURL) > 0 {
+		siteConfig.SiteURL = site.SiteURL
+	}
+	if len(site.SearchAPI) > 0 {
+		siteConfig.SearchAPI = site.SearchAPI
+	}
+	if len(site.SearchIndexAPI) > 0 {
+		siteConfig.SearchIndexAPI = site.SearchIndexAPI
+	}
+	if site.SearchPageSize > 0 {
+		siteConfig.SearchPageSize = site.SearchPageSize
+	}
+	if len(site.WebhookSecret) > 0 {
+		siteConfig.WebhookSecret = site.WebhookSecret
+	}
+	siteConfig.normalize()
+
+	return &siteConfig
+}
+
+func validateHttpURL(value string) error {
+	u, err := url.Parse(value)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%v is not a http(s) url", value)
+	}
+	if len(u.Host) == 0 {
+		return fmt.Errorf("%v has no host", value)
+	}
+	return nil
+}
+
+func (c DBConfig) ConnMaxLifetime() time.Duration {
+	return time.Duration(c.ConnMaxLifetimeSec) * time.Second
+}
+
+// TTL returns how long responses of the API path are cached. 0 means not cached.
+func (c CacheConfig) TTL(path string) time.Duration {
+	if ttl, has := c.EndpointTTLSec[path]; has {
+		return time.Duration(ttl) * time.Second
+	}
+	return time.Duration(c.TTLSec) * time.Second
+}
+
+// MaxTTL returns the longest TTL of all API paths.
+func (c CacheConfig) MaxTTL() time.Duration {
+	maxTTL := c.TTLSec
+	for _, ttl := range c.EndpointTTLSec {
+		if ttl > maxTTL {
+			maxTTL = ttl
+		}
+	}
+	return time.Duration(maxTTL) * time.Second
+}
+
+// SiteHost returns host part of SiteURL(www.popit.kr)
+func (c *Config) SiteHost() string {
+	u, err := url.Parse(c.SiteURL)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// GetConfig returns the config of the site the request is served for.
+func GetConfig(ctx context.Context) *Config {
+	v := ctx.Value("CONFIG")
+	if v == nil {
+		panic("Config is not exist")
+	}
+	if config, ok := v.(*Config); ok {
+		return config
+	}
+	panic("Config is not exist")
+}
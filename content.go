@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Contents of single posts are rendered by the format parameter.
+// raw is post_content as it has always been returned: only [gallery] lines get image URLs(see renderRawGalleries).
+// html is rendered like the WordPress theme(postShortcodes and wpautop) with anchors of headings,
+// and text is the html without tags(see Post.renderContent).
+
+const (
+	RAW_FORMAT  = "raw"
+	HTML_FORMAT = "html"
+	TEXT_FORMAT = "text"
+)
+
+func withContentFormat(ctx context.Context, format string) context.Context {
+	return context.WithValue(ctx, "CONTENT_FORMAT", format)
+}
+
+// GetContentFormat returns the format of contents of the request. The default is raw.
+func GetContentFormat(ctx context.Context) string {
+	if format, ok := ctx.Value("CONTENT_FORMAT").(string); ok && len(format) > 0 {
+		return format
+	}
+	return RAW_FORMAT
+}
+
+// renderContent renders post_content in the format.
+func renderContent(ctx context.Context, content string, format string) string {
+	switch format {
+	case HTML_FORMAT:
+		return wpautop(postShortcodes.Process(ctx, content))
+	case TEXT_FORMAT:
+		return htmlToText(wpautop(postShortcodes.Process(ctx, content)))
+	default:
+		return renderRawGalleries(ctx, content)
+	}
+}
+
+// renderRawGalleries replaces ids of attachments in lines starting with [gallery with their URLs and excerpts.
+// [gallery columns="2" size="medium" ids="17068,17069"] is [gallery columns="2" size="medium" images="..." captions="..."]
+// Other shortcodes are left to clients. The content is not changed if any gallery fails.
+func renderRawGalleries(ctx context.Context, content string) string {
+	if !strings.Contains(content, "[gallery") {
+		return content
+	}
+
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		if !strings.HasPrefix(strings.TrimSpace(line), "[gallery") {
+			continue
+		}
+
+		columns := ""
+		size := ""
+		ids := make([]int64, 0)
+		tokens := strings.Split(strings.Replace(strings.Replace(line, "[gallery ", "", -1), "]", "", -1), " ")
+		for _, eachToken := range tokens {
+			keyVal := strings.Split(eachToken, "=")
+			if len(keyVal) != 2 {
+				continue
+			}
+			key := keyVal[0]
+			val := keyVal[1]
+
+			if key == "ids" {
+				idStr, err := strconv.Unquote(strings.TrimSpace(val))
+				if err != nil {
+					fmt.Println("gallery id Unquote error:", val, "==>", err.Error())
+					return content
+				}
+				for _, id := range strings.Split(idStr, ",") {
+					i64, err := strconv.ParseInt(id, 10, 32)
+					if err != nil {
+						fmt.Println("gallery id error:", err.Error())
+						return content
+					}
+					ids = append(ids, i64)
+				}
+			} else if key == "columns" {
+				columns = val
+			} else if key == "size" {
+				size = val
+			}
+		}
+
+		childPosts, err := galleryImages(ctx, ids)
+		if err != nil {
+			fmt.Println("error while getting child post:", err.Error())
+			return content
+		}
+
+		images := make([]string, 0)
+		postExcerpts := make([]string, 0)
+		for _, eachChildPost := range childPosts {
+			images = append(images, eachChildPost.Guid)
+			parameters := url.Values{}
+			parameters.Add("", eachChildPost.PostExcerpt)
+			encodedPostExcerpt := parameters.Encode()
+
+			postExcerpts = append(postExcerpts, encodedPostExcerpt[1:])
+		}
+
+		lines[i] = fmt.Sprintf("[gallery columns=%v size=%v images=\"%v\" captions=\"%v\"]",
+			columns, size, strings.Join(images, ","), strings.Join(postExcerpts, ","))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+const autopBlocks = `(?:table|thead|tfoot|caption|col|colgroup|tbody|tr|td|th|div|dl|dd|dt|ul|ol|li|pre|form|map|area|blockquote|address|math|style|p|h[1-6]|hr|fieldset|legend|section|article|aside|hgroup|header|footer|nav|figure|figcaption|details|menu|summary)`
+
+var (
+	autopPrePattern          = regexp.MustCompile(`(?is)<pre[\s>].*?</pre>`)
+	autopTagPattern          = regexp.MustCompile(`(?s)<[^>]*>`)
+	autopDoubleBrPattern     = regexp.MustCompile(`(?i)<br\s*/?>\s*<br\s*/?>`)
+	autopBlockOpenPattern    = regexp.MustCompile(`(?i)(<` + autopBlocks + `[\s/>])`)
+	autopBlockClosePattern   = regexp.MustCompile(`(?i)(</` + autopBlocks + `>)`)
+	autopHrPattern           = regexp.MustCompile(`(?i)<hr\s*?/?>`)
+	autopNewlinesPattern     = regexp.MustCompile(`\n\n+`)
+	autopParagraphPattern    = regexp.MustCompile(`\n\s*\n`)
+	autopEmptyPattern        = regexp.MustCompile(`<p>\s*</p>`)
+	autopUnclosedPattern     = regexp.MustCompile(`<p>([^<]+)</(div|address|form)>`)
+	autopOnlyBlockPattern    = regexp.MustCompile(`(?i)<p>\s*(</?` + autopBlocks + `[^>]*>)\s*</p>`)
+	autopListPattern         = regexp.MustCompile(`(?i)<p>(<li.+?)</p>`)
+	autopBlockquotePattern   = regexp.MustCompile(`(?i)<p><blockquote([^>]*)>`)
+	autopBeforeBlockPattern  = regexp.MustCompile(`(?i)<p>\s*(</?` + autopBlocks + `[^>]*>)`)
+	autopAfterBlockPattern   = regexp.MustCompile(`(?i)(</?` + autopBlocks + `[^>]*>)\s*</p>`)
+	autopBrPattern           = regexp.MustCompile(`(?i)<br\s*/?>`)
+	autopLineBreakPattern    = regexp.MustCompile(`(<br />)?\s*\n`)
+	autopBlockBrPattern      = regexp.MustCompile(`(?i)(</?` + autopBlocks + `[^>]*>)\s*<br />`)
+	autopBrBeforeTagsPattern = regexp.MustCompile(`(?i)<br />(\s*</?(?:p|li|div|dl|dd|dt|th|pre|td|ul|ol)[^>]*>)`)
+)
+
+// wpautop makes paragraphs of blank lines and line breaks of new lines like wpautop of WordPress.
+// <pre> blocks and new lines in tags are kept as they are.
+func wpautop(text string) string {
+	if len(strings.TrimSpace(text)) == 0 {
+		return ""
+	}
+
+	pres := make([]string, 0)
+	text = autopPrePattern.ReplaceAllStringFunc(text, func(pre string) string {
+		pres = append(pres, pre)
+		return fmt.Sprintf("<pre wp-pre-tag-%v></pre>", len(pres)-1)
+	})
+	text = autopTagPattern.ReplaceAllStringFunc(text, func(tag string) string {
+		return strings.Replace(tag, "\n", "\x00", -1)
+	})
+
+	text = strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(text + "\n")
+	text = autopDoubleBrPattern.ReplaceAllString(text, "\n\n")
+	text = autopBlockOpenPattern.ReplaceAllString(text, "\n\n$1")
+	text = autopBlockClosePattern.ReplaceAllString(text, "$1\n\n")
+	text = autopHrPattern.ReplaceAllString(text, "<hr />\n\n")
+	text = autopNewlinesPattern.ReplaceAllString(text, "\n\n")
+
+	var paragraphs strings.Builder
+	for _, paragraph := range autopParagraphPattern.Split(text, -1) {
+		if len(strings.TrimSpace(paragraph)) > 0 {
+			paragraphs.WriteString("<p>" + strings.Trim(paragraph, "\n") + "</p>\n")
+		}
+	}
+	text = paragraphs.String()
+
+	text = autopEmptyPattern.ReplaceAllString(text, "")
+	text = autopUnclosedPattern.ReplaceAllString(text, "<p>$1</p></$2>")
+	text = autopOnlyBlockPattern.ReplaceAllString(text, "$1")
+	text = autopListPattern.ReplaceAllString(text, "$1")
+	text = autopBlockquotePattern.ReplaceAllString(text, "<blockquote$1><p>")
+	text = strings.Replace(text, "</blockquote></p>", "</p></blockquote>", -1)
+	text = autopBeforeBlockPattern.ReplaceAllString(text, "$1")
+	text = autopAfterBlockPattern.ReplaceAllString(text, "$1")
+
+	text = autopBrPattern.ReplaceAllString(text, "<br />")
+	text = autopLineBreakPattern.ReplaceAllStringFunc(text, func(lineBreak string) string {
+		if strings.HasPrefix(lineBreak, "<br />") {
+			return lineBreak
+		}
+		return "<br />\n"
+	})
+	text = autopBlockBrPattern.ReplaceAllString(text, "$1")
+	text = autopBrBeforeTagsPattern.ReplaceAllString(text, "$1")
+	text = strings.TrimSuffix(text, "\n")
+	text = strings.Replace(text, "<br />\n</p>", "</p>", -1)
+
+	text = dropUnopenedParagraphEnds(text)
+
+	text = strings.Replace(text, "\x00", "\n", -1)
+	for i, pre := range pres {
+		text = strings.Replace(text, fmt.Sprintf("<pre wp-pre-tag-%v></pre>", i), pre, 1)
+	}
+	return text
+}
+
+var autopParagraphTagPattern = regexp.MustCompile(`(?i)<p[\s>]|</p>`)
+
+// dropUnopenedParagraphEnds drops </p> left by removing <p> before a block which does not end the paragraph,
+// e.g. <figure><img /></p>.
+func dropUnopenedParagraphEnds(text string) string {
+	open := 0
+	return autopParagraphTagPattern.ReplaceAllStringFunc(text, func(tag string) string {
+		if !strings.HasPrefix(tag, "</") {
+			open++
+			return tag
+		}
+		if open == 0 {
+			return ""
+		}
+		open--
+		return tag
+	})
+}
+
+// textBreaks are tags which break lines of texts. Paragraphs and other blocks are separated by blank lines,
+// and lines(list items, rows, ...) start with a line break.
+var textBreaks = map[string]string{
+	"br": "\n", "li": "\n", "tr": "\n", "dt": "\n", "dd": "\n",
+	"p": "\n\n", "div": "\n\n", "pre": "\n\n", "blockquote": "\n\n", "figure": "\n\n", "figcaption": "\n",
+	"h1": "\n\n", "h2": "\n\n", "h3": "\n\n", "h4": "\n\n", "h5": "\n\n", "h6": "\n\n",
+	"ul": "\n\n", "ol": "\n\n", "table": "\n\n", "hr": "\n\n",
+}
+
+var (
+	textSpacesPattern = regexp.MustCompile(`[ \t\r\n]+`)
+	textLinesPattern  = regexp.MustCompile(` *\n *`)
+	textBlanksPattern = regexp.MustCompile(`\n{3,}`)
+)
+
+// htmlToText strips tags of the HTML like getDescriptionFromContents. Spaces are collapsed except in <pre>,
+// and texts of <script>, <style> and media elements are dropped.
+func htmlToText(content string) string {
+	htmlToken := html.NewTokenizer(strings.NewReader(content))
+	var text strings.Builder
+	preDepth := 0
+	skipDepth := 0
+	for {
+		tokenType := htmlToken.Next()
+		if tokenType == html.ErrorToken {
+			break
+		}
+		token := htmlToken.Token()
+		switch tokenType {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			switch token.Data {
+			case "pre":
+				preDepth++
+			case "script", "style", "video", "audio", "iframe":
+				if tokenType == html.StartTagToken {
+					skipDepth++
+				}
+			}
+			text.WriteString(textBreaks[token.Data])
+		case html.TextToken:
+			if skipDepth > 0 {
+				continue
+			}
+			if preDepth > 0 {
+				// marks new lines of <pre> not to be collapsed
+				text.WriteString(strings.Replace(token.Data, "\n", "\x00", -1))
+			} else {
+				text.WriteString(textSpacesPattern.ReplaceAllString(token.Data, " "))
+			}
+		case html.EndTagToken:
+			switch token.Data {
+			case "pre":
+				if preDepth > 0 {
+					preDepth--
+				}
+			case "script", "style", "video", "audio", "iframe":
+				if skipDepth > 0 {
+					skipDepth--
+				}
+			}
+			if textBreaks[token.Data] == "\n\n" {
+				text.WriteString(textBreaks[token.Data])
+			}
+		}
+	}
+
+	result := textLinesPattern.ReplaceAllString(text.String(), "\n")
+	result = textBlanksPattern.ReplaceAllString(result, "\n\n")
+	return strings.TrimSpace(strings.Replace(result, "\x00", "\n", -1))
+}
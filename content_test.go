@@ -0,0 +1,81 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// newTestGalleryStorage has attachments 901 and 902 for galleries.
+func newTestGalleryStorage() *MemoryStorage {
+	storage := &MemoryStorage{}
+	storage.data.Posts = []MemoryPost{
+		{ID: 901, Type: "attachment", Status: "inherit", Guid: "https://www.popit.kr/1.png", Excerpt: "gopher one"},
+		{ID: 902, Type: "attachment", Status: "inherit", Guid: "https://www.popit.kr/2.png", Excerpt: "고퍼"},
+	}
+	return storage
+}
+
+func TestRenderRawContent(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		result  string
+	}{
+		{"no gallery", "<h2>Title</h2>\n[caption]<img src=\"a.png\" /> a[/caption]\n\nline", "<h2>Title</h2>\n[caption]<img src=\"a.png\" /> a[/caption]\n\nline"},
+		{"gallery",
+			"<h2>Title</h2>\n[gallery columns=\"2\" size=\"medium\" ids=\"902,901\"]\n[embed]https://youtu.be/abc[/embed]",
+			"<h2>Title</h2>\n[gallery columns=\"2\" size=\"medium\" images=\"https://www.popit.kr/2.png,https://www.popit.kr/1.png\" captions=\"%EA%B3%A0%ED%8D%BC,gopher+one\"]\n[embed]https://youtu.be/abc[/embed]"},
+		// the whole line is the gallery
+		{"indented gallery", "a\n  [gallery ids=\"901,999\"] b", "a\n[gallery columns= size= images=\"https://www.popit.kr/1.png\" captions=\"gopher+one\"]"},
+		{"gallery not at the start of a line", "a [gallery ids=\"901\"]", "a [gallery ids=\"901\"]"},
+		{"wrong ids", "[gallery ids=\"901\"]\n[gallery ids=\"a,b\"]", "[gallery ids=\"901\"]\n[gallery ids=\"a,b\"]"},
+		{"unquoted ids", "[gallery ids=901]", "[gallery ids=901]"},
+	}
+
+	ctx, closeStore := newTestSiteContext(newTestGalleryStorage())
+	defer closeStore()
+
+	for _, test := range tests {
+		if result := renderContent(ctx, test.content, RAW_FORMAT); result != test.result {
+			t.Errorf("%v: expected\n%q\ngot\n%q", test.name, test.result, result)
+		}
+	}
+}
+
+func TestPostRenderContent(t *testing.T) {
+	content := "<h2>설치 방법</h2>\n[gallery ids=\"901\"]\n\nGo를 설치합니다."
+	tests := []struct {
+		format string
+		result string
+	}{
+		{RAW_FORMAT, "<h2>설치 방법</h2>\n[gallery columns= size= images=\"https://www.popit.kr/1.png\" captions=\"gopher+one\"]\n\nGo를 설치합니다."},
+		{HTML_FORMAT, `<h2 id="설치-방법">설치 방법</h2>` + "\n" +
+			`<div class="gallery gallery-columns-3 gallery-size-thumbnail">` + "\n" +
+			`<figure class="gallery-item"><img src="https://www.popit.kr/1.png" alt="gopher one" />` + "\n" +
+			`<figcaption class="gallery-caption">gopher one</figcaption>` + "\n</figure>\n</div>\n" +
+			"<p>Go를 설치합니다.</p>"},
+		{TEXT_FORMAT, "설치 방법\n\ngopher one\n\nGo를 설치합니다."},
+	}
+
+	ctx, closeStore := newTestSiteContext(newTestGalleryStorage())
+	defer closeStore()
+
+	for _, test := range tests {
+		post := &Post{Content: content}
+		post.renderContent(withContentFormat(ctx, test.format))
+
+		if post.Content != test.result {
+			t.Errorf("%v: expected\n%q\ngot\n%q", test.format, test.result, post.Content)
+		}
+		// the toc of every format is of the html format
+		if len(post.Toc) != 1 || post.Toc[0].Anchor != "설치-방법" {
+			t.Errorf("%v: unexpected toc %+v", test.format, post.Toc)
+		}
+	}
+
+	post := &Post{Content: content}
+	post.renderContent(withFields(withContentFormat(ctx, RAW_FORMAT), FieldSet{"content": nil}))
+	if post.Toc != nil || strings.Contains(post.Content, "id=") {
+		t.Errorf("unexpected toc %+v of %q", post.Toc, post.Content)
+	}
+}
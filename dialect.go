@@ -0,0 +1,42 @@
+package main
+
+import (
+	"strings"
+
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// sqlDialect holds the SQL which differs by database.
+// LIMIT/OFFSET of the query builder is translated by xorm.
+type sqlDialect struct {
+	// Random is the function for random ordering.
+	Random string
+	// Quote quotes identifiers. WordPress names like ID are mixed-case and PostgreSQL folds unquoted names to lowercase.
+	Quote string
+}
+
+// db.driver values which are executed by xorm
+var sqlDialects = map[string]sqlDialect{
+	"mysql":    {Random: "RAND()", Quote: "`"},
+	"sqlite3":  {Random: "RANDOM()", Quote: `"`},
+	"postgres": {Random: "RANDOM()", Quote: `"`},
+}
+
+// quote quotes a name or each part of a qualified name(table.column).
+func (d sqlDialect) quote(name string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = d.Quote + part + d.Quote
+	}
+	return strings.Join(parts, ".")
+}
+
+func isSupportedDriver(driver string) bool {
+	if driver == "memory" {
+		return true
+	}
+	_, has := sqlDialects[driver]
+	return has
+}
@@ -0,0 +1,40 @@
+package main
+
+import "testing"
+
+func TestSqlDialectQuote(t *testing.T) {
+	tests := []struct {
+		driver   string
+		name     string
+		expected string
+	}{
+		{"mysql", "ID", "`ID`"},
+		{"mysql", "wp_posts.post_date", "`wp_posts`.`post_date`"},
+		{"sqlite3", "ID", `"ID"`},
+		{"postgres", "ID", `"ID"`},
+		{"postgres", "WP_posts.ID", `"WP_posts"."ID"`},
+	}
+
+	for _, test := range tests {
+		if quoted := sqlDialects[test.driver].quote(test.name); quoted != test.expected {
+			t.Errorf("%v %v: expected %v, got %v", test.driver, test.name, test.expected, quoted)
+		}
+	}
+}
+
+func TestXormRepositoryQuotesNames(t *testing.T) {
+	r := xormRepository{dialect: sqlDialects["postgres"], config: &Config{TablePrefix: "wp_"}}
+
+	if columns := r.columns("wp_posts", "ID", "post_date"); columns != `"wp_posts"."ID", "wp_posts"."post_date"` {
+		t.Errorf("unexpected columns %v", columns)
+	}
+	if columns := r.columns("", authorColumns...); columns != `"ID", "user_login", "display_name", "user_url", "user_email"` {
+		t.Errorf("unexpected columns %v", columns)
+	}
+	if orderBy := r.orderBy(ORDER_BY_DATE_DESC); orderBy != `"wp_posts"."post_date" desc, "wp_posts"."ID" desc` {
+		t.Errorf("unexpected order %v", orderBy)
+	}
+	if orderBy := r.orderBy(ORDER_BY_RANDOM); orderBy != "RANDOM()" {
+		t.Errorf("unexpected order %v", orderBy)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo"
+)
+
+// ErrorCode is the stable code of a failed ApiResult. Clients should check the code, not the message.
+type ErrorCode string
+
+const (
+	INVALID_PARAM        ErrorCode = "INVALID_PARAM"
+	QUERY_TOO_COMPLEX    ErrorCode = "QUERY_TOO_COMPLEX"
+	UNAUTHORIZED         ErrorCode = "UNAUTHORIZED"
+	SITE_NOT_FOUND       ErrorCode = "SITE_NOT_FOUND"
+	POST_NOT_FOUND       ErrorCode = "POST_NOT_FOUND"
+	AUTHOR_NOT_FOUND     ErrorCode = "AUTHOR_NOT_FOUND"
+	TERM_NOT_FOUND       ErrorCode = "TERM_NOT_FOUND"
+	PREFERENCE_NOT_FOUND ErrorCode = "PREFERENCE_NOT_FOUND"
+	ROUTE_NOT_FOUND      ErrorCode = "ROUTE_NOT_FOUND"
+	METHOD_NOT_ALLOWED   ErrorCode = "METHOD_NOT_ALLOWED"
+	UPSTREAM_FAILED      ErrorCode = "UPSTREAM_FAILED"
+	INTERNAL_ERROR       ErrorCode = "INTERNAL_ERROR"
+)
+
+// errorStatuses maps each code to its HTTP status.
+var errorStatuses = map[ErrorCode]int{
+	INVALID_PARAM:        http.StatusBadRequest,
+	QUERY_TOO_COMPLEX:    http.StatusBadRequest,
+	UNAUTHORIZED:         http.StatusUnauthorized,
+	SITE_NOT_FOUND:       http.StatusNotFound,
+	POST_NOT_FOUND:       http.StatusNotFound,
+	AUTHOR_NOT_FOUND:     http.StatusNotFound,
+	TERM_NOT_FOUND:       http.StatusNotFound,
+	PREFERENCE_NOT_FOUND: http.StatusNotFound,
+	ROUTE_NOT_FOUND:      http.StatusNotFound,
+	METHOD_NOT_ALLOWED:   http.StatusMethodNotAllowed,
+	UPSTREAM_FAILED:      http.StatusBadGateway,
+	INTERNAL_ERROR:       http.StatusInternalServerError,
+}
+
+// ApiError is a failure with a code. Handlers and models return it and apiErrorHandler responds it.
+type ApiError struct {
+	Code    ErrorCode
+	Message string
+}
+
+func NewApiError(code ErrorCode, message string) *ApiError {
+	return &ApiError{Code: code, Message: message}
+}
+
+func (e *ApiError) Error() string {
+	return e.Message
+}
+
+func (e *ApiError) Status() int {
+	if status, has := errorStatuses[e.Code]; has {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// Extensions puts the code into GraphQL errors.
+func (e *ApiError) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": e.Code}
+}
+
+// invalidParam is the error of a wrong query parameter.
+func invalidParam(name string, value string) *ApiError {
+	return NewApiError(INVALID_PARAM, "Wrong "+name+" parameter["+value+"]")
+}
+
+// upstreamError is the error of a failed call to other services(search API, SlideShare, ...).
+func upstreamError(err error) *ApiError {
+	if apiErr, ok := err.(*ApiError); ok {
+		return apiErr
+	}
+	return NewApiError(UPSTREAM_FAILED, err.Error())
+}
+
+// IsNotFound reports whether the error is about a missing post, author, term, ...
+func IsNotFound(err error) bool {
+	apiErr, ok := err.(*ApiError)
+	return ok && apiErr.Status() == http.StatusNotFound
+}
+
+// toApiError gives a code to errors of echo and others. Unknown errors are INTERNAL_ERROR.
+func toApiError(err error) *ApiError {
+	switch e := err.(type) {
+	case *ApiError:
+		return e
+	case *echo.HTTPError:
+		message := fmt.Sprint(e.Message)
+		switch {
+		case e.Code == http.StatusNotFound:
+			return NewApiError(ROUTE_NOT_FOUND, message)
+		case e.Code == http.StatusMethodNotAllowed:
+			return NewApiError(METHOD_NOT_ALLOWED, message)
+		case e.Code == http.StatusUnauthorized:
+			return NewApiError(UNAUTHORIZED, message)
+		case e.Code < http.StatusInternalServerError:
+			return NewApiError(INVALID_PARAM, message)
+		default:
+			return NewApiError(INTERNAL_ERROR, message)
+		}
+	default:
+		return NewApiError(INTERNAL_ERROR, err.Error())
+	}
+}
+
+// apiErrorHandler responds errors returned by handlers and middlewares as ApiResult with a code.
+func apiErrorHandler(err error, c echo.Context) {
+	apiErr := toApiError(err)
+	if apiErr.Code == INTERNAL_ERROR || apiErr.Code == UPSTREAM_FAILED {
+		fmt.Println("ERROR", c.Request().Method, c.Request().URL, ":", err.Error())
+	}
+
+	if c.Response().Committed {
+		return
+	}
+
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(apiErr.Status())
+	} else {
+		err = c.JSON(apiErr.Status(), ApiResult{
+			Success: false,
+			Code:    apiErr.Code,
+			Message: apiErr.Message,
+		})
+	}
+	if err != nil {
+		fmt.Println("ERROR writing error response:", err.Error())
+	}
+}
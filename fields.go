@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/labstack/echo"
+)
+
+// FieldSet is the set of JSON fields of posts selected by the fields parameter,
+// e.g. fields=id,title,author.displayName is {id: nil, title: nil, author: {displayName: nil}}.
+// A nil FieldSet(or a nil sub set) selects all fields.
+type FieldSet map[string]FieldSet
+
+// ParseFieldSet parses comma separated field paths and checks them against the JSON fields of typ.
+func ParseFieldSet(param string, typ reflect.Type) (FieldSet, error) {
+	if len(strings.TrimSpace(param)) == 0 {
+		return nil, nil
+	}
+
+	fields := FieldSet{}
+	for _, path := range strings.Split(param, ",") {
+		path = strings.TrimSpace(path)
+		if len(path) == 0 {
+			continue
+		}
+		if err := fields.add(strings.Split(path, "."), typ); err != nil {
+			return nil, fmt.Errorf("Unknown field[%v]", path)
+		}
+	}
+	return fields, nil
+}
+
+func (f FieldSet) add(names []string, typ reflect.Type) error {
+	fieldType, has := jsonFieldType(typ, names[0])
+	if !has {
+		return fmt.Errorf("Unknown field[%v]", names[0])
+	}
+
+	sub, selected := f[names[0]]
+	if selected && sub == nil {
+		// the whole field is already selected
+		return nil
+	}
+	if len(names) == 1 {
+		f[names[0]] = nil
+		return nil
+	}
+
+	if sub == nil {
+		sub = FieldSet{}
+		f[names[0]] = sub
+	}
+	return sub.add(names[1:], fieldType)
+}
+
+// jsonFieldType finds the type of the JSON field of a struct. Elements of slices are looked into.
+func jsonFieldType(typ reflect.Type, name string) (reflect.Type, bool) {
+	for typ.Kind() == reflect.Slice || typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return nil, false
+	}
+
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if tag := strings.Split(field.Tag.Get("json"), ",")[0]; tag == name && len(field.PkgPath) == 0 {
+			return field.Type, true
+		}
+	}
+	return nil, false
+}
+
+// Has reports whether the field is selected.
+func (f FieldSet) Has(name string) bool {
+	if f == nil {
+		return true
+	}
+	_, has := f[name]
+	return has
+}
+
+// HasAny reports whether one of the fields is selected.
+func (f FieldSet) HasAny(names ...string) bool {
+	for _, name := range names {
+		if f.Has(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// trim removes unselected fields from a JSON object or array of objects.
+func (f FieldSet) trim(data []byte) ([]byte, error) {
+	trimmed := strings.TrimSpace(string(data))
+	if f == nil || len(trimmed) == 0 {
+		return data, nil
+	}
+
+	switch trimmed[0] {
+	case '[':
+		var elements []json.RawMessage
+		if err := json.Unmarshal(data, &elements); err != nil {
+			return nil, err
+		}
+		for i := range elements {
+			element, err := f.trim(elements[i])
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = element
+		}
+		return json.Marshal(elements)
+	case '{':
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(data, &object); err != nil {
+			return nil, err
+		}
+		for name, value := range object {
+			var err error
+			sub, has := f[name]
+			if !has {
+				delete(object, name)
+				continue
+			}
+			if object[name], err = sub.trim(value); err != nil {
+				return nil, err
+			}
+		}
+		return json.Marshal(object)
+	default:
+		return data, nil
+	}
+}
+
+// setFieldsContext parses the fields parameter of posts.
+func setFieldsContext() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			fields, err := ParseFieldSet(ctx.QueryParam("fields"), reflect.TypeOf(Post{}))
+			if err != nil {
+				return NewApiError(INVALID_PARAM, err.Error())
+			}
+
+			if fields != nil {
+				req := ctx.Request()
+				ctx.SetRequest(req.WithContext(withFields(req.Context(), fields)))
+			}
+
+			return next(ctx)
+		}
+	}
+}
+
+func withFields(ctx context.Context, fields FieldSet) context.Context {
+	return context.WithValue(ctx, "FIELDS", fields)
+}
+
+// GetFields returns the fields of posts selected by the request. nil means all fields.
+func GetFields(ctx context.Context) FieldSet {
+	fields, _ := ctx.Value("FIELDS").(FieldSet)
+	return fields
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/labstack/echo"
+)
+
+// /graphql answers queries of NewGraphQLSchema. Responses are GraphQL results({"data": ..., "errors": [...]})
+// instead of ApiResult. Errors have their ErrorCode in extensions.code.
+
+const (
+	MAX_QUERY_DEPTH      = 8
+	MAX_QUERY_COMPLEXITY = 5000
+	// LIST_COMPLEXITY is the assumed number of items of lists other than connections.
+	LIST_COMPLEXITY  = 10
+	MAX_GRAPHQL_BODY = 64 * 1024
+)
+
+type GraphQLRequest struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+}
+
+// readGraphQLRequest reads query, variables(JSON) and operationName parameters of GET or the JSON body of POST.
+func readGraphQLRequest(c echo.Context) (*GraphQLRequest, error) {
+	request := &GraphQLRequest{}
+	if c.Request().Method == http.MethodPost {
+		body := http.MaxBytesReader(c.Response(), c.Request().Body, MAX_GRAPHQL_BODY)
+		if err := json.NewDecoder(body).Decode(request); err != nil {
+			return nil, NewApiError(INVALID_PARAM, "Wrong GraphQL request: "+err.Error())
+		}
+	} else {
+		request.Query = c.QueryParam("query")
+		request.OperationName = c.QueryParam("operationName")
+		if variables := c.QueryParam("variables"); len(variables) > 0 {
+			if err := json.Unmarshal([]byte(variables), &request.Variables); err != nil {
+				return nil, invalidParam("variables", variables)
+			}
+		}
+	}
+
+	if len(strings.TrimSpace(request.Query)) == 0 {
+		return nil, NewApiError(INVALID_PARAM, "No query")
+	}
+	return request, nil
+}
+
+// GraphQL executes the query of the request with new loaders.
+func GraphQL(schema graphql.Schema) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		request, err := readGraphQLRequest(c)
+		if err != nil {
+			return respondGraphQLErrors(c, err)
+		}
+
+		doc, err := parser.Parse(parser.ParseParams{Source: request.Query})
+		if err != nil {
+			return respondGraphQLErrors(c, err)
+		}
+
+		validation := graphql.ValidateDocument(&schema, doc, nil)
+		if !validation.IsValid {
+			errs := make([]error, 0)
+			for _, validationErr := range validation.Errors {
+				errs = append(errs, validationErr)
+			}
+			return respondGraphQLErrors(c, errs...)
+		}
+
+		if err := checkQueryLimits(schema, doc, request.OperationName, request.Variables); err != nil {
+			return respondGraphQLErrors(c, err)
+		}
+
+		cacheControl := &graphqlCacheControl{}
+		ctx := withGraphQLCacheControl(withLoaders(c.Request().Context(), newLoaders()), cacheControl)
+		result := graphql.Execute(graphql.ExecuteParams{
+			Schema:        schema,
+			AST:           doc,
+			OperationName: request.OperationName,
+			Args:          request.Variables,
+			Context:       ctx,
+		})
+
+		if result.HasErrors() {
+			for _, resultErr := range result.Errors {
+				if apiErr := toApiError(resultErr.OriginalError()); apiErr.Code == INTERNAL_ERROR || apiErr.Code == UPSTREAM_FAILED {
+					fmt.Println("ERROR", c.Request().Method, c.Request().URL, ":", resultErr.Message)
+				}
+			}
+			// results with errors may have failed by a temporary error
+			c.Response().Header().Set("Cache-Control", "no-store")
+		}
+		if cacheControl.isNoStore() {
+			c.Response().Header().Set("Cache-Control", "no-store")
+		}
+		return c.JSON(http.StatusOK, result)
+	}
+}
+
+// graphqlCacheControl is marked by resolvers whose results must not be cached, e.g. random posts without a seed.
+type graphqlCacheControl struct {
+	mutex   sync.Mutex
+	noStore bool
+}
+
+func (c *graphqlCacheControl) isNoStore() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.noStore
+}
+
+func withGraphQLCacheControl(ctx context.Context, control *graphqlCacheControl) context.Context {
+	return context.WithValue(ctx, "GRAPHQL_CACHE_CONTROL", control)
+}
+
+// setNoStore responds the GraphQL result with "Cache-Control: no-store".
+func setNoStore(ctx context.Context) {
+	control, ok := ctx.Value("GRAPHQL_CACHE_CONTROL").(*graphqlCacheControl)
+	if !ok {
+		return
+	}
+
+	control.mutex.Lock()
+	defer control.mutex.Unlock()
+	control.noStore = true
+}
+
+// respondGraphQLErrors responds errors of a request which could not be executed.
+func respondGraphQLErrors(c echo.Context, errs ...error) error {
+	formatted := make([]gqlerrors.FormattedError, 0)
+	for _, err := range errs {
+		formattedErr := gqlerrors.FormatError(err)
+		code := INVALID_PARAM
+		if apiErr, ok := err.(*ApiError); ok {
+			code = apiErr.Code
+		}
+		formattedErr.Extensions = map[string]interface{}{"code": code}
+		formatted = append(formatted, formattedErr)
+	}
+	// no data because the request was not executed
+	return c.JSON(http.StatusBadRequest, map[string]interface{}{"errors": formatted})
+}
+
+// queryLimits walks the selections of an operation to find its depth and complexity.
+// Complexity counts one for each field of each object. A field of a list multiplies the complexity of its selections
+// by the size of the list: first or last of connections, LIST_COMPLEXITY for others.
+type queryLimits struct {
+	schema    graphql.Schema
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+	// visiting are the fragments being walked to stop at cycles.
+	visiting map[string]bool
+}
+
+func checkQueryLimits(schema graphql.Schema, doc *ast.Document, operationName string, variables map[string]interface{}) error {
+	limits := &queryLimits{
+		schema:    schema,
+		fragments: make(map[string]*ast.FragmentDefinition),
+		variables: variables,
+		visiting:  make(map[string]bool),
+	}
+
+	var operation *ast.OperationDefinition
+	for _, definition := range doc.Definitions {
+		switch definition := definition.(type) {
+		case *ast.FragmentDefinition:
+			limits.fragments[definition.Name.Value] = definition
+		case *ast.OperationDefinition:
+			if len(operationName) == 0 || (definition.Name != nil && definition.Name.Value == operationName) {
+				operation = definition
+			}
+		}
+	}
+	if operation == nil {
+		// graphql.Execute reports the missing operation
+		return nil
+	}
+
+	depth, complexity := limits.selections(schema.QueryType(), operation.SelectionSet)
+	if depth > MAX_QUERY_DEPTH {
+		return NewApiError(QUERY_TOO_COMPLEX, fmt.Sprintf("Query depth %v exceeds %v", depth, MAX_QUERY_DEPTH))
+	}
+	if complexity > MAX_QUERY_COMPLEXITY {
+		return NewApiError(QUERY_TOO_COMPLEX, fmt.Sprintf("Query complexity %v exceeds %v", complexity, MAX_QUERY_COMPLEXITY))
+	}
+	return nil
+}
+
+// selections returns the depth and the complexity of the selections of an object of the type.
+func (l *queryLimits) selections(parent graphql.Type, selectionSet *ast.SelectionSet) (int, int) {
+	object, ok := parent.(*graphql.Object)
+	if !ok || selectionSet == nil {
+		return 0, 0
+	}
+
+	depth, complexity := 0, 0
+	add := func(d int, c int) {
+		if d > depth {
+			depth = d
+		}
+		complexity += c
+	}
+
+	for _, selection := range selectionSet.Selections {
+		switch selection := selection.(type) {
+		case *ast.Field:
+			name := selection.Name.Value
+			field, has := object.Fields()[name]
+			if strings.HasPrefix(name, "__") || !has {
+				continue
+			}
+
+			fieldType := field.Type
+			if nonNull, ok := fieldType.(*graphql.NonNull); ok {
+				fieldType = nonNull.OfType
+			}
+			namedType, _ := graphql.GetNamed(fieldType).(graphql.Type)
+
+			multiplier := 1
+			if _, isList := fieldType.(*graphql.List); isList && object.Name() != "PostConnection" {
+				multiplier = LIST_COMPLEXITY
+			}
+			if namedType != nil && namedType.Name() == "PostConnection" {
+				multiplier = l.connectionSize(selection)
+			}
+
+			childDepth, childComplexity := l.selections(namedType, selection.SelectionSet)
+			add(childDepth+1, 1+multiplier*childComplexity)
+		case *ast.InlineFragment:
+			add(l.selections(l.conditionType(parent, selection.TypeCondition), selection.SelectionSet))
+		case *ast.FragmentSpread:
+			name := selection.Name.Value
+			fragment, has := l.fragments[name]
+			if !has || l.visiting[name] {
+				continue
+			}
+			l.visiting[name] = true
+			add(l.selections(l.conditionType(parent, fragment.TypeCondition), fragment.SelectionSet))
+			delete(l.visiting, name)
+		}
+	}
+	return depth, complexity
+}
+
+func (l *queryLimits) conditionType(parent graphql.Type, condition *ast.Named) graphql.Type {
+	if condition == nil || condition.Name == nil {
+		return parent
+	}
+	return l.schema.Type(condition.Name.Value)
+}
+
+// connectionSize returns first or last of a connection field, DEFAULT_CONNECTION_SIZE if neither is given.
+// Sizes less than 1 are counted as 1 and are rejected by connectionRange.
+func (l *queryLimits) connectionSize(field *ast.Field) int {
+	size := DEFAULT_CONNECTION_SIZE
+	for _, argument := range field.Arguments {
+		name := argument.Name.Value
+		if name != "first" && name != "last" {
+			continue
+		}
+
+		switch value := argument.Value.(type) {
+		case *ast.IntValue:
+			if parsed, err := strconv.Atoi(value.Value); err == nil {
+				size = parsed
+			}
+		case *ast.Variable:
+			switch variable := l.variables[value.Name.Value].(type) {
+			case float64:
+				size = int(variable)
+			case int:
+				size = variable
+			}
+		}
+	}
+	if size < 1 {
+		return 1
+	}
+	return size
+}
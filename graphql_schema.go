@@ -0,0 +1,625 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"net/url"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// GraphQL types of posts, authors, terms, site preferences and external metas.
+// Fields have the names of the JSON fields of the REST API.
+// Posts are found with only the selected fields(see FieldSet), and their authors, terms and external metas
+// are batched by the loaders of the request.
+
+const (
+	DEFAULT_CONNECTION_SIZE = 10
+	MAX_PREFERENCE_NAMES    = 20
+)
+
+// loaderPostFields are fields of posts resolved by the loaders instead of loadAssociations.
+var loaderPostFields = map[string]bool{"author": true, "categories": true, "tags": true, "metas": true}
+
+// postEdge is an edge of PostConnection. cursor is the forward cursor of the post.
+type postEdge struct {
+	Cursor string `json:"cursor"`
+	Node   Post   `json:"node"`
+}
+
+type pageInfo struct {
+	HasNextPage     bool   `json:"hasNextPage"`
+	HasPreviousPage bool   `json:"hasPreviousPage"`
+	StartCursor     string `json:"startCursor"`
+	EndCursor       string `json:"endCursor"`
+}
+
+// postConnection is a page of posts. count counts all posts of the list only if totalCount is selected.
+type postConnection struct {
+	page  *PostPage
+	count func() (int64, error)
+}
+
+func (c *postConnection) edges() []postEdge {
+	edges := make([]postEdge, 0)
+	for _, post := range c.page.Posts {
+		edges = append(edges, postEdge{Cursor: newPostCursor(post, false).Encode(), Node: post})
+	}
+	return edges
+}
+
+func (c *postConnection) pageInfo() pageInfo {
+	info := pageInfo{
+		HasNextPage:     len(c.page.NextCursor) > 0,
+		HasPreviousPage: len(c.page.PrevCursor) > 0,
+	}
+	if posts := c.page.Posts; len(posts) > 0 {
+		info.StartCursor = newPostCursor(posts[0], false).Encode()
+		info.EndCursor = newPostCursor(posts[len(posts)-1], false).Encode()
+	}
+	return info
+}
+
+// connectionRange returns the range of posts selected by first and after, or last and before.
+func connectionRange(args map[string]interface{}) (PostRange, error) {
+	size := DEFAULT_CONNECTION_SIZE
+	cursorParam, _ := args["after"].(string)
+	backward := false
+
+	if last, has := args["last"].(int); has {
+		before, _ := args["before"].(string)
+		if len(before) == 0 {
+			return PostRange{}, NewApiError(INVALID_PARAM, "last needs before")
+		}
+		size, cursorParam, backward = last, before, true
+	} else if first, has := args["first"].(int); has {
+		size = first
+	}
+
+	if size < 1 || size > MAX_PAGE_SIZE {
+		return PostRange{}, NewApiError(INVALID_PARAM, fmt.Sprintf("first and last must be between 1 and %v", MAX_PAGE_SIZE))
+	}
+
+	postRange := PostRange{Limit: size}
+	if len(cursorParam) > 0 {
+		cursor, err := DecodePostCursor(cursorParam)
+		if err != nil {
+			return PostRange{}, NewApiError(INVALID_PARAM, "Wrong cursor["+cursorParam+"]")
+		}
+		if cursor != nil {
+			cursor.Prev = backward
+			postRange.Cursor = cursor
+		}
+	}
+	return postRange, nil
+}
+
+// excludesArg returns ids of the excludes argument like excludesRule. A missing list is empty.
+func excludesArg(args map[string]interface{}) ([]int, error) {
+	excludes := make([]int, 0)
+	values, _ := args["excludes"].([]interface{})
+	if len(values) > MAX_EXCLUDES {
+		return nil, NewApiError(INVALID_PARAM, fmt.Sprintf("excludes must have at most %v ids", MAX_EXCLUDES))
+	}
+	for _, value := range values {
+		if id, ok := value.(int); ok {
+			excludes = append(excludes, id)
+		}
+	}
+	return excludes, nil
+}
+
+// selectedPostFields returns the fields of posts selected under one of the paths of the field being resolved.
+// Fields resolved by the loaders are left out so that loadAssociations does not load them.
+func selectedPostFields(info graphql.ResolveInfo, paths ...[]string) FieldSet {
+	fields := FieldSet{"id": nil}
+	for _, field := range info.FieldASTs {
+		for _, path := range paths {
+			collectPostFields(info, field.SelectionSet, path, fields)
+		}
+	}
+	return fields
+}
+
+func collectPostFields(info graphql.ResolveInfo, selectionSet *ast.SelectionSet, path []string, fields FieldSet) {
+	if selectionSet == nil {
+		return
+	}
+
+	for _, selection := range selectionSet.Selections {
+		switch selection := selection.(type) {
+		case *ast.Field:
+			name := selection.Name.Value
+			if len(path) > 0 {
+				if name == path[0] {
+					collectPostFields(info, selection.SelectionSet, path[1:], fields)
+				}
+			} else if !loaderPostFields[name] && !strings.HasPrefix(name, "__") {
+				fields[name] = nil
+			}
+		case *ast.InlineFragment:
+			collectPostFields(info, selection.SelectionSet, path, fields)
+		case *ast.FragmentSpread:
+			if fragment, ok := info.Fragments[selection.Name.Value].(*ast.FragmentDefinition); ok {
+				collectPostFields(info, fragment.SelectionSet, path, fields)
+			}
+		}
+	}
+}
+
+// connectionPostPaths are the paths of posts in PostConnection.
+var connectionPostPaths = [][]string{{"nodes"}, {"edges", "node"}}
+
+func sourcePost(p graphql.ResolveParams) Post {
+	if post, ok := p.Source.(*Post); ok {
+		return *post
+	}
+	post, _ := p.Source.(Post)
+	return post
+}
+
+func sourceAuthor(p graphql.ResolveParams) Author {
+	if author, ok := p.Source.(*Author); ok {
+		return *author
+	}
+	author, _ := p.Source.(Author)
+	return author
+}
+
+func sourceTerm(p graphql.ResolveParams) Term {
+	if term, ok := p.Source.(*Term); ok {
+		return *term
+	}
+	term, _ := p.Source.(Term)
+	return term
+}
+
+// resolvePostTerms resolves categories or tags of the post by the terms loader.
+func resolvePostTerms(taxonomy string) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		load := getLoaders(p.Context).terms.Load(p.Context, sourcePost(p).ID)
+		return func() (interface{}, error) {
+			value, err := load()
+			if err != nil {
+				return nil, err
+			}
+
+			terms, _ := value.(postTerms)
+			if taxonomy == "category" {
+				return terms.Categories, nil
+			}
+			return terms.Tags, nil
+		}, nil
+	}
+}
+
+// NewGraphQLSchema makes the schema of /graphql.
+func NewGraphQLSchema() (graphql.Schema, error) {
+	var postType, authorType, termType, postConnectionType, tocEntryType *graphql.Object
+
+	taxonomyEnum := graphql.NewEnum(graphql.EnumConfig{
+		Name: "Taxonomy",
+		Values: graphql.EnumValueConfigMap{
+			"CATEGORY": &graphql.EnumValueConfig{Value: "category"},
+			"TAG":      &graphql.EnumValueConfig{Value: "post_tag"},
+		},
+	})
+
+	connectionArgs := func(withExcludes bool) graphql.FieldConfigArgument {
+		args := graphql.FieldConfigArgument{
+			"first":  &graphql.ArgumentConfig{Type: graphql.Int, Description: fmt.Sprintf("Number of posts after the cursor. %v if neither first nor last is given.", DEFAULT_CONNECTION_SIZE)},
+			"after":  &graphql.ArgumentConfig{Type: graphql.String, Description: "Cursor of an edge. The first page if empty."},
+			"last":   &graphql.ArgumentConfig{Type: graphql.Int, Description: "Number of posts before the cursor."},
+			"before": &graphql.ArgumentConfig{Type: graphql.String, Description: "Cursor of an edge. Required with last."},
+		}
+		if withExcludes {
+			args["excludes"] = &graphql.ArgumentConfig{Type: graphql.NewList(graphql.NewNonNull(graphql.Int)), Description: "Ids of posts not to be listed."}
+		}
+		return args
+	}
+
+	externalMetaType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "ExternalMeta",
+		Fields: graphql.Fields{
+			"id":     &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"postId": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"name":   &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"value":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		},
+	})
+
+	sitePreferenceType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "SitePreference",
+		Fields: graphql.Fields{
+			"id":    &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"name":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"value": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		},
+	})
+
+	tocEntryType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "TocEntry",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"level":    &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+				"title":    &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"anchor":   &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"children": &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(tocEntryType)))},
+			}
+		}),
+	})
+
+	postType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Post",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":             &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+				"title":          &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"content":        &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"date":           &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+				"postName":       &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"image":          &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"mediumImage":    &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"thumbnailImage": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"socialTitle":    &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"socialDesc":     &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"wordCount":      &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+				"readingMinutes": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+				"toc": &graphql.Field{
+					Type:        graphql.NewList(graphql.NewNonNull(tocEntryType)),
+					Description: "Table of contents of h2 to h4 headings of post. Empty in lists.",
+				},
+				"author": &graphql.Field{
+					Type:        graphql.NewNonNull(authorType),
+					Description: "Author of the post. Empty(id 0) if the author was deleted, like the REST API.",
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						load := getLoaders(p.Context).authors.Load(p.Context, sourcePost(p).AuthorID)
+						return func() (interface{}, error) {
+							value, err := load()
+							if err != nil {
+								return nil, err
+							}
+							// a null author would fail the whole list of posts
+							if value == nil {
+								return Author{}, nil
+							}
+							return value, nil
+						}, nil
+					},
+				},
+				"categories": &graphql.Field{
+					Type:    graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(termType))),
+					Resolve: resolvePostTerms("category"),
+				},
+				"tags": &graphql.Field{
+					Type:    graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(termType))),
+					Resolve: resolvePostTerms("post_tag"),
+				},
+				"metas": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(externalMetaType))),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return getLoaders(p.Context).externalMetas.Load(p.Context, sourcePost(p).ID), nil
+					},
+				},
+			}
+		}),
+	})
+
+	authorType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Author",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":          &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+				"userLogin":   &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"displayName": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"userUrl":     &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"avatar":      &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"posts": &graphql.Field{
+					Type: graphql.NewNonNull(postConnectionType),
+					Args: connectionArgs(true),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						postRange, err := connectionRange(p.Args)
+						if err != nil {
+							return nil, err
+						}
+
+						author := sourceAuthor(p)
+						excludes, err := excludesArg(p.Args)
+						if err != nil {
+							return nil, err
+						}
+						ctx := withFields(p.Context, selectedPostFields(p.Info, connectionPostPaths...))
+
+						page, err := Post{}.GetByAuthor(ctx, author.ID, excludes, postRange)
+						if err != nil {
+							return nil, err
+						}
+						return &postConnection{page: page, count: func() (int64, error) {
+							return Post{}.CountByAuthor(ctx, author.ID, excludes)
+						}}, nil
+					},
+				},
+			}
+		}),
+	})
+
+	termType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Term",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":       &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+				"name":     &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"slug":     &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"taxonomy": &graphql.Field{Type: graphql.NewNonNull(taxonomyEnum)},
+				"posts": &graphql.Field{
+					Type: graphql.NewNonNull(postConnectionType),
+					Args: connectionArgs(true),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						postRange, err := connectionRange(p.Args)
+						if err != nil {
+							return nil, err
+						}
+
+						term := sourceTerm(p)
+						excludes, err := excludesArg(p.Args)
+						if err != nil {
+							return nil, err
+						}
+						ctx := withFields(p.Context, selectedPostFields(p.Info, connectionPostPaths...))
+
+						page, err := Post{}.GetByTag(ctx, term.ID, excludes, postRange)
+						if err != nil {
+							return nil, err
+						}
+						return &postConnection{page: page, count: func() (int64, error) {
+							return Post{}.CountByTag(ctx, term.ID, excludes)
+						}}, nil
+					},
+				},
+			}
+		}),
+	})
+
+	postEdgeType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "PostEdge",
+		Fields: graphql.Fields{
+			"cursor": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"node":   &graphql.Field{Type: graphql.NewNonNull(postType)},
+		},
+	})
+
+	pageInfoType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "PageInfo",
+		Fields: graphql.Fields{
+			"hasNextPage":     &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+			"hasPreviousPage": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+			"startCursor":     &graphql.Field{Type: graphql.String},
+			"endCursor":       &graphql.Field{Type: graphql.String},
+		},
+	})
+
+	postConnectionType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "PostConnection",
+		Fields: graphql.Fields{
+			"edges": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(postEdgeType))),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*postConnection).edges(), nil
+				},
+			},
+			"nodes": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(postType))),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*postConnection).page.Posts, nil
+				},
+			},
+			"pageInfo": &graphql.Field{
+				Type: graphql.NewNonNull(pageInfoType),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*postConnection).pageInfo(), nil
+				},
+			},
+			"totalCount": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Int),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*postConnection).count()
+				},
+			},
+		},
+	})
+
+	termPostsType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "TermPosts",
+		Fields: graphql.Fields{
+			"term":  &graphql.Field{Type: graphql.NewNonNull(termType)},
+			"posts": &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(postType)))},
+		},
+	})
+
+	authorPostsType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "AuthorPosts",
+		Fields: graphql.Fields{
+			"author": &graphql.Field{Type: graphql.NewNonNull(authorType)},
+			"posts":  &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(postType)))},
+		},
+	})
+
+	randomArgs := graphql.FieldConfigArgument{
+		"isMobile": &graphql.ArgumentConfig{Type: graphql.Boolean, DefaultValue: false, Description: "Picks less posts for mobile."},
+		"seed":     &graphql.ArgumentConfig{Type: graphql.Int, Description: "Seed of the random picks. The same seed picks the same posts."},
+	}
+	// randomSeed returns the seed argument or a new seed. Results of new seeds are not cached like getSeed.
+	randomSeed := func(p graphql.ResolveParams) int64 {
+		if seed, has := p.Args["seed"].(int); has {
+			return int64(seed)
+		}
+		setNoStore(p.Context)
+		return rand.Int63()
+	}
+
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"post": &graphql.Field{
+				Type:        postType,
+				Description: "A post by id(published, future or draft) or slug(published). null if not found.",
+				Args: graphql.FieldConfigArgument{
+					"id":   &graphql.ArgumentConfig{Type: graphql.Int},
+					"slug": &graphql.ArgumentConfig{Type: graphql.String},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					ctx := withFields(p.Context, selectedPostFields(p.Info, []string{}))
+
+					var post *Post
+					var err error
+					if id, has := p.Args["id"].(int); has {
+						post, err = Post{}.GetPostById(ctx, int64(id))
+					} else if slug, has := p.Args["slug"].(string); has {
+						post, err = Post{}.GetByPermalink(ctx, url.QueryEscape(slug))
+					} else {
+						return nil, NewApiError(INVALID_PARAM, "post needs id or slug")
+					}
+
+					if err != nil || post == nil {
+						return nil, err
+					}
+					return *post, nil
+				},
+			},
+			"recentPosts": &graphql.Field{
+				Type: graphql.NewNonNull(postConnectionType),
+				Args: connectionArgs(false),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					postRange, err := connectionRange(p.Args)
+					if err != nil {
+						return nil, err
+					}
+
+					ctx := withFields(p.Context, selectedPostFields(p.Info, connectionPostPaths...))
+					page, err := Post{}.GetRecent(ctx, postRange)
+					if err != nil {
+						return nil, err
+					}
+					return &postConnection{page: page, count: func() (int64, error) {
+						return Post{}.GetNumberOfPosts(ctx)
+					}}, nil
+				},
+			},
+			"author": &graphql.Field{
+				Type:        authorType,
+				Description: "An author by login name or id. null if not found.",
+				Args: graphql.FieldConfigArgument{
+					"login": &graphql.ArgumentConfig{Type: graphql.String},
+					"id":    &graphql.ArgumentConfig{Type: graphql.Int},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					var author *Author
+					var err error
+					if login, has := p.Args["login"].(string); has {
+						author, err = Author{}.GetByLoginName(p.Context, login)
+					} else if id, has := p.Args["id"].(int); has {
+						author, err = Author{}.GetOne(p.Context, int64(id))
+					} else {
+						return nil, NewApiError(INVALID_PARAM, "author needs login or id")
+					}
+
+					if IsNotFound(err) {
+						return nil, nil
+					}
+					if err != nil {
+						return nil, err
+					}
+					return *author, nil
+				},
+			},
+			"term": &graphql.Field{
+				Type:        termType,
+				Description: "A tag or category by slug. null if not found.",
+				Args: graphql.FieldConfigArgument{
+					"slug":     &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"taxonomy": &graphql.ArgumentConfig{Type: taxonomyEnum, DefaultValue: "post_tag"},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					slug, _ := p.Args["slug"].(string)
+					taxonomy, _ := p.Args["taxonomy"].(string)
+
+					term, err := Term{}.FinyBySlug(p.Context, url.QueryEscape(slug), taxonomy)
+					if IsNotFound(err) {
+						return nil, nil
+					}
+					if err != nil {
+						return nil, err
+					}
+					return *term, nil
+				},
+			},
+			"randomTermPosts": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(termPostsType))),
+				Description: "Random posts of random tags like /api/TagPosts.",
+				Args:        randomArgs,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					isMobile, _ := p.Args["isMobile"].(bool)
+					ctx := withFields(p.Context, selectedPostFields(p.Info, []string{"posts"}))
+					return Post{}.GetRandomPostsByTerm(ctx, isMobile, randomSeed(p))
+				},
+			},
+			"randomAuthorPosts": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(authorPostsType))),
+				Description: "Random posts of random authors like /api/RandomAuthorPosts.",
+				Args:        randomArgs,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					isMobile, _ := p.Args["isMobile"].(bool)
+					ctx := withFields(p.Context, selectedPostFields(p.Info, []string{"posts"}))
+					return Post{}.GetRandomPostsByAuthor(ctx, isMobile, randomSeed(p))
+				},
+			},
+			"preference": &graphql.Field{
+				Type:        sitePreferenceType,
+				Description: "A site preference. null if not found.",
+				Args: graphql.FieldConfigArgument{
+					"name": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					name, _ := p.Args["name"].(string)
+					return getLoaders(p.Context).preferences.Load(p.Context, name), nil
+				},
+			},
+			"preferences": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(sitePreferenceType))),
+				Description: "Site preferences by names(ad.pc.top, ...). Missing preferences are not in the list.",
+				Args: graphql.FieldConfigArgument{
+					"names": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String)))},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					names, _ := p.Args["names"].([]interface{})
+					if len(names) > MAX_PREFERENCE_NAMES {
+						return nil, NewApiError(INVALID_PARAM, fmt.Sprintf("At most %v names", MAX_PREFERENCE_NAMES))
+					}
+
+					// all names are queued before the first thunk loads them in one query
+					loads := make([]func() (interface{}, error), 0)
+					for _, name := range names {
+						loads = append(loads, getLoaders(p.Context).preferences.Load(p.Context, fmt.Sprint(name)))
+					}
+					return func() (interface{}, error) {
+						preferences := make([]SitePreference, 0)
+						for _, load := range loads {
+							value, err := load()
+							if err != nil {
+								return nil, err
+							}
+							if preference, ok := value.(SitePreference); ok {
+								preferences = append(preferences, preference)
+							}
+						}
+						return preferences, nil
+					}, nil
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: queryType})
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+	"github.com/labstack/echo"
+)
+
+// newTestSiteContext returns the context of a request of the default site on the memory storage.
+func newTestSiteContext(storage *MemoryStorage) (context.Context, func()) {
+	config := DefaultConfig().ForSite(SiteConfig{Name: DEFAULT_SITE_NAME})
+	site := &Site{Name: DEFAULT_SITE_NAME, Config: config, Storage: storage, PostIndex: &PostIdIndex{site: DEFAULT_SITE_NAME}}
+	store, closeStore := storage.Open(config, true)
+	return withStore(withSite(context.Background(), site), store), closeStore
+}
+
+// executeTestQuery executes the query on the memory storage like a GraphQL request of the default site.
+func executeTestQuery(t *testing.T, storage *MemoryStorage, query string) *graphql.Result {
+	schema, err := NewGraphQLSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, closeStore := newTestSiteContext(storage)
+	defer closeStore()
+
+	return graphql.Do(graphql.Params{Schema: schema, RequestString: query, Context: withLoaders(ctx, newLoaders())})
+}
+
+func TestGraphQLPostsOfDeletedAuthor(t *testing.T) {
+	storage, err := NewMemoryStorage("sample_data.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// posts 100 and 101 are of the deleted author 1
+	storage.data.Users = storage.data.Users[1:]
+
+	result := executeTestQuery(t, storage, `{ recentPosts(first: 10) { nodes { id author { id displayName } } } }`)
+	if result.HasErrors() {
+		t.Fatalf("unexpected errors %v", result.Errors)
+	}
+
+	data, _ := json.Marshal(result.Data)
+	expected := `{"recentPosts":{"nodes":[` +
+		`{"author":{"displayName":"글쓴이","id":2},"id":104},` +
+		`{"author":{"displayName":"글쓴이","id":2},"id":102},` +
+		`{"author":{"displayName":"","id":0},"id":101},` +
+		`{"author":{"displayName":"","id":0},"id":100}]}}`
+	if string(data) != expected {
+		t.Errorf("expected\n%v\ngot\n%v", expected, string(data))
+	}
+}
+
+func TestGraphQLRandomPostsWithoutSeedAreNotCached(t *testing.T) {
+	tests := []struct {
+		query   string
+		noStore bool
+	}{
+		{"{ randomTermPosts { term { id } } }", true},
+		{"{ randomAuthorPosts(isMobile: true) { author { id } } }", true},
+		{"{ randomTermPosts(seed: 1) { term { id } } randomAuthorPosts(seed: 1) { author { id } } }", false},
+		{"{ recentPosts { nodes { id } } }", false},
+	}
+
+	storage, err := NewMemoryStorage("sample_data.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema, err := NewGraphQLSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range tests {
+		ctx, closeStore := newTestSiteContext(storage)
+		req := httptest.NewRequest(http.MethodGet, "/graphql?query="+url.QueryEscape(test.query), nil).WithContext(ctx)
+		rec := httptest.NewRecorder()
+
+		if err := GraphQL(schema)(echo.New().NewContext(req, rec)); err != nil {
+			t.Fatal(err)
+		}
+		closeStore()
+
+		if rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), `"errors"`) {
+			t.Errorf("%v: unexpected response %v %v", test.query, rec.Code, rec.Body.String())
+		}
+		if noStore := rec.Header().Get("Cache-Control") == "no-store"; noStore != test.noStore {
+			t.Errorf("%v: expected no-store %v, got %v", test.query, test.noStore, noStore)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+// WordPress sends an event to /api/hooks/wordpress when a post, term or user is changed.
+// The body is signed by HMAC-SHA256 with the webhook secret of the site:
+//   X-Popit-Timestamp: unix seconds
+//   X-Popit-Signature: sha256=hex(hmac_sha256(secret, timestamp + "." + body))
+
+const (
+	HOOK_SIGNATURE_HEADER = "X-Popit-Signature"
+	HOOK_TIMESTAMP_HEADER = "X-Popit-Timestamp"
+	// HOOK_MAX_CLOCK_SKEW rejects replayed requests
+	HOOK_MAX_CLOCK_SKEW = 5 * time.Minute
+	HOOK_MAX_BODY_BYTES = 1 << 20
+)
+
+type WordPressEvent struct {
+	// Event is one of save_post, delete_post, edited_term and profile_update.
+	Event    string `json:"event"`
+	PostID   int64  `json:"postId"`
+	AuthorID int64  `json:"authorId"`
+	TermIDs  []int  `json:"termIds"`
+	TermID   int    `json:"termId"`
+	UserID   int64  `json:"userId"`
+}
+
+type WordPressEventResult struct {
+	Invalidated int  `json:"invalidated"`
+	Reindexing  bool `json:"reindexing"`
+}
+
+func verifyHookSignature(secret string, timestamp string, body []byte, signature string) error {
+	if len(secret) == 0 {
+		return fmt.Errorf("Webhook secret is not configured")
+	}
+
+	unixTime, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("Wrong %v header[%v]", HOOK_TIMESTAMP_HEADER, timestamp)
+	}
+	if skew := time.Since(time.Unix(unixTime, 0)); skew > HOOK_MAX_CLOCK_SKEW || skew < -HOOK_MAX_CLOCK_SKEW {
+		return fmt.Errorf("%v is too old or in the future", HOOK_TIMESTAMP_HEADER)
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	if !hmac.Equal([]byte(expected), []byte(strings.ToLower(signature))) {
+		return fmt.Errorf("Wrong %v header", HOOK_SIGNATURE_HEADER)
+	}
+
+	return nil
+}
+
+// WordPressHook drops cached responses of the changed post, author or term and queues the post for search reindexing.
+func WordPressHook(cache *ResponseCache, reindexQueue *ReindexQueue) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ctx := c.Request().Context()
+		site := GetSite(ctx)
+
+		body, err := ioutil.ReadAll(http.MaxBytesReader(c.Response(), c.Request().Body, HOOK_MAX_BODY_BYTES))
+		if err != nil {
+			return NewApiError(INVALID_PARAM, err.Error())
+		}
+
+		err = verifyHookSignature(site.Config.WebhookSecret, c.Request().Header.Get(HOOK_TIMESTAMP_HEADER), body,
+			c.Request().Header.Get(HOOK_SIGNATURE_HEADER))
+		if err != nil {
+			return NewApiError(UNAUTHORIZED, err.Error())
+		}
+
+		var event WordPressEvent
+		if err := json.Unmarshal(body, &event); err != nil {
+			return NewApiError(INVALID_PARAM, "Wrong event: "+err.Error())
+		}
+
+		result := WordPressEventResult{}
+
+		switch event.Event {
+		case "save_post", "delete_post":
+			if event.PostID <= 0 {
+				return NewApiError(INVALID_PARAM, "No postId")
+			}
+
+			authorIds := make([]int64, 0)
+			if event.AuthorID > 0 {
+				authorIds = append(authorIds, event.AuthorID)
+			}
+			termIds := event.TermIDs
+
+			// the post may be moved to other terms or authors than the event says
+			if post, terms, err := (Post{}).GetChangedPost(ctx, event.PostID); err != nil {
+				return err
+			} else if post != nil {
+				authorIds = append(authorIds, post.AuthorID)
+				for _, term := range terms {
+					termIds = append(termIds, term.ID)
+				}
+			}
+
+			result.Invalidated = cache.InvalidatePosts(site.Name, event.PostID) +
+				cache.InvalidateAuthorPosts(site.Name, authorIds...) +
+				cache.InvalidateTermPosts(site.Name, termIds...)
+			site.PostIndex.Invalidate()
+			result.Reindexing = reindexQueue.Push(site, event.PostID, event.Event == "delete_post")
+		case "edited_term":
+			if event.TermID <= 0 {
+				return NewApiError(INVALID_PARAM, "No termId")
+			}
+			result.Invalidated = cache.InvalidateTerms(site.Name, event.TermID)
+		case "profile_update":
+			if event.UserID <= 0 {
+				return NewApiError(INVALID_PARAM, "No userId")
+			}
+			result.Invalidated = cache.InvalidateAuthors(site.Name, event.UserID)
+		default:
+			return NewApiError(INVALID_PARAM, "Unknown event["+event.Event+"]")
+		}
+
+		fmt.Println("WordPress event:", event.Event, ", site=", site.Name, ", invalidated=", result.Invalidated)
+
+		return c.JSON(http.StatusOK, ApiResult{
+			Success: true,
+			Data:    result,
+			Message: "",
+		})
+	}
+}
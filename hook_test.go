@@ -0,0 +1,226 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func signHook(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "." + string(body)))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifyHookSignature(t *testing.T) {
+	body := []byte(`{"event":"save_post","postId":100}`)
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	old := strconv.FormatInt(time.Now().Add(-HOOK_MAX_CLOCK_SKEW-time.Minute).Unix(), 10)
+	future := strconv.FormatInt(time.Now().Add(HOOK_MAX_CLOCK_SKEW+time.Minute).Unix(), 10)
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		body      string
+		signature string
+		valid     bool
+	}{
+		{"valid", "secret", now, string(body), signHook("secret", now, body), true},
+		{"upper case hex", "secret", now, string(body), "SHA256=" + signHook("secret", now, body)[7:], true},
+		{"tampered body", "secret", now, `{"event":"save_post","postId":101}`, signHook("secret", now, body), false},
+		{"wrong secret", "secret", now, string(body), signHook("other", now, body), false},
+		{"empty secret", "", now, string(body), signHook("", now, body), false},
+		{"signed with another timestamp", "secret", now, string(body), signHook("secret", old, body), false},
+		{"old timestamp", "secret", old, string(body), signHook("secret", old, body), false},
+		{"future timestamp", "secret", future, string(body), signHook("secret", future, body), false},
+		{"wrong timestamp", "secret", "now", string(body), signHook("secret", "now", body), false},
+		{"no signature", "secret", now, string(body), "", false},
+	}
+
+	for _, test := range tests {
+		err := verifyHookSignature(test.secret, test.timestamp, []byte(test.body), test.signature)
+		if valid := err == nil; valid != test.valid {
+			t.Errorf("%v: expected valid %v, got %v", test.name, test.valid, err)
+		}
+	}
+}
+
+// recordingCache records invalidated tags.
+type recordingCache struct {
+	*MemoryCache
+	mutex sync.Mutex
+	tags  map[string]bool
+}
+
+func (c *recordingCache) Invalidate(tags ...string) (int, error) {
+	c.mutex.Lock()
+	for _, tag := range tags {
+		c.tags[tag] = true
+	}
+	c.mutex.Unlock()
+	return c.MemoryCache.Invalidate(tags...)
+}
+
+func (c *recordingCache) invalidated() []string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	tags := make([]string, 0)
+	for tag := range c.tags {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	c.tags = make(map[string]bool)
+	return tags
+}
+
+// testReindexer passes reindexed posts to jobs.
+type testReindexer struct {
+	jobs chan reindexJob
+}
+
+func (r testReindexer) Reindex(site *Site, postId int64, deleted bool) error {
+	r.jobs <- reindexJob{site: site, postId: postId, deleted: deleted}
+	return nil
+}
+
+func (s *testServer) postHook(secret string, body string) *testResponse {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	rec := s.request(http.MethodPost, "/api/hooks/wordpress", []byte(body), map[string]string{
+		HOOK_TIMESTAMP_HEADER: timestamp,
+		HOOK_SIGNATURE_HEADER: signHook(secret, timestamp, []byte(body)),
+	})
+	return newTestResponse(rec)
+}
+
+func TestWordPressHook(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		invalidated []string
+		reindexed   *reindexJob
+	}{
+		{
+			name: "save_post",
+			body: `{"event":"save_post","postId":100,"authorId":1,"termIds":[20]}`,
+			invalidated: []string{"default|author_posts:1", "default|post:100", "default|posts",
+				"default|term_posts:10", "default|term_posts:20", "default|term_posts:21"},
+			reindexed: &reindexJob{postId: 100},
+		},
+		{
+			// post 102 of author 2 was of author 1
+			name:        "save_post moved to another author",
+			body:        `{"event":"save_post","postId":102,"authorId":1}`,
+			invalidated: []string{"default|author_posts:1", "default|author_posts:2", "default|post:102", "default|posts", "default|term_posts:21"},
+			reindexed:   &reindexJob{postId: 102},
+		},
+		{
+			name:        "delete_post",
+			body:        `{"event":"delete_post","postId":999,"authorId":2,"termIds":[21]}`,
+			invalidated: []string{"default|author_posts:2", "default|post:999", "default|posts", "default|term_posts:21"},
+			reindexed:   &reindexJob{postId: 999, deleted: true},
+		},
+		{
+			name:        "edited_term",
+			body:        `{"event":"edited_term","termId":20}`,
+			invalidated: []string{"default|term:20"},
+		},
+		{
+			name:        "profile_update",
+			body:        `{"event":"profile_update","userId":1}`,
+			invalidated: []string{"default|author:1"},
+		},
+	}
+
+	reindexer := testReindexer{jobs: make(chan reindexJob, 10)}
+	server := newTestServer(t, reindexer)
+	defer server.Close()
+	cache := &recordingCache{MemoryCache: NewMemoryCache(100), tags: make(map[string]bool)}
+	server.cache.cache = cache
+	site := server.sites.Sites()[0]
+
+	for _, test := range tests {
+		version := server.cache.PostIndexVersion(site.Name)
+
+		res := server.postHook(TEST_WEBHOOK_SECRET, test.body)
+		if res.Code != http.StatusOK {
+			t.Fatalf("%v: unexpected response %v %v", test.name, res.Code, res.Body)
+		}
+		var result WordPressEventResult
+		json.Unmarshal(res.Result.Data, &result)
+
+		if tags := cache.invalidated(); !equalStrings(tags, test.invalidated) {
+			t.Errorf("%v: expected invalidated %v, got %v", test.name, test.invalidated, tags)
+		}
+
+		if test.reindexed == nil {
+			if result.Reindexing {
+				t.Errorf("%v: expected no reindexing", test.name)
+			}
+			if server.cache.PostIndexVersion(site.Name) != version {
+				t.Errorf("%v: expected post id indexes to be kept", test.name)
+			}
+			continue
+		}
+
+		if !result.Reindexing {
+			t.Errorf("%v: expected reindexing", test.name)
+		}
+		select {
+		case job := <-reindexer.jobs:
+			if job.site != site || job.postId != test.reindexed.postId || job.deleted != test.reindexed.deleted {
+				t.Errorf("%v: expected reindexing %+v, got %+v", test.name, *test.reindexed, job)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("%v: expected post %v to be reindexed", test.name, test.reindexed.postId)
+		}
+		if server.cache.PostIndexVersion(site.Name) == version {
+			t.Errorf("%v: expected post id indexes to be invalidated", test.name)
+		}
+	}
+
+	select {
+	case job := <-reindexer.jobs:
+		t.Errorf("unexpected reindexing %+v", job)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestWordPressHookRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret string
+		body   string
+		code   ErrorCode
+	}{
+		{"wrong secret", "other", `{"event":"profile_update","userId":1}`, UNAUTHORIZED},
+		{"unknown event", TEST_WEBHOOK_SECRET, `{"event":"publish","postId":1}`, INVALID_PARAM},
+		{"no post id", TEST_WEBHOOK_SECRET, `{"event":"save_post"}`, INVALID_PARAM},
+		{"no term id", TEST_WEBHOOK_SECRET, `{"event":"edited_term"}`, INVALID_PARAM},
+		{"no user id", TEST_WEBHOOK_SECRET, `{"event":"profile_update"}`, INVALID_PARAM},
+		{"wrong body", TEST_WEBHOOK_SECRET, `[]`, INVALID_PARAM},
+	}
+
+	server := newTestServer(t, testReindexer{jobs: make(chan reindexJob, 10)})
+	defer server.Close()
+
+	for _, test := range tests {
+		if res := server.postHook(test.secret, test.body); res.Result.Code != test.code {
+			t.Errorf("%v: expected %v, got %v %v", test.name, test.code, res.Code, res.Body)
+		}
+	}
+
+	// webhooks are rejected if the site has no secret
+	server.sites.Sites()[0].Config.WebhookSecret = ""
+	if res := server.postHook("", `{"event":"profile_update","userId":1}`); res.Code != http.StatusUnauthorized {
+		t.Errorf("expected a site without a secret to reject webhooks, got %v %v", res.Code, res.Body)
+	}
+}
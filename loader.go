@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// batchLoader batches loads of a request like a dataloader. Load only queues the key and returns a thunk.
+// GraphQL resolves thunks after all fields of a level are resolved, so the first thunk loads all queued keys
+// with one query and the others find their values loaded. Loaded values are kept until the request ends.
+// Keys are ids(int64) or names(string) of the loader.
+type batchLoader struct {
+	mutex   sync.Mutex
+	load    func(ctx context.Context, keys []interface{}) (map[interface{}]interface{}, error)
+	pending []interface{}
+	values  map[interface{}]interface{}
+	errors  map[interface{}]error
+}
+
+func newBatchLoader(load func(ctx context.Context, keys []interface{}) (map[interface{}]interface{}, error)) *batchLoader {
+	return &batchLoader{
+		load:   load,
+		values: make(map[interface{}]interface{}),
+		errors: make(map[interface{}]error),
+	}
+}
+
+// Load returns a thunk of the value of the key. The value is nil if there is no such key.
+func (l *batchLoader) Load(ctx context.Context, key interface{}) func() (interface{}, error) {
+	l.mutex.Lock()
+	if !l.loaded(key) && !l.isPending(key) {
+		l.pending = append(l.pending, key)
+	}
+	l.mutex.Unlock()
+
+	return func() (interface{}, error) {
+		l.mutex.Lock()
+		defer l.mutex.Unlock()
+
+		if !l.loaded(key) {
+			l.loadPending(ctx)
+		}
+		return l.values[key], l.errors[key]
+	}
+}
+
+func (l *batchLoader) isPending(key interface{}) bool {
+	for _, each := range l.pending {
+		if each == key {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *batchLoader) loaded(key interface{}) bool {
+	_, hasValue := l.values[key]
+	_, hasError := l.errors[key]
+	return hasValue || hasError
+}
+
+func (l *batchLoader) loadPending(ctx context.Context) {
+	keys := l.pending
+	l.pending = nil
+	if len(keys) == 0 {
+		return
+	}
+
+	values, err := l.load(ctx, keys)
+	for _, key := range keys {
+		if err != nil {
+			l.errors[key] = err
+		} else {
+			l.values[key] = values[key]
+		}
+	}
+}
+
+// postTerms are categories and tags of a post.
+type postTerms struct {
+	Categories []Term
+	Tags       []Term
+}
+
+// loaders are the batch loaders of a GraphQL request.
+type loaders struct {
+	// authors loads Author by author id.
+	authors *batchLoader
+	// terms loads postTerms by post id.
+	terms *batchLoader
+	// externalMetas loads []PostExternalMeta by post id.
+	externalMetas *batchLoader
+	// preferences loads SitePreference by name.
+	preferences *batchLoader
+}
+
+func newLoaders() *loaders {
+	return &loaders{
+		authors:       newBatchLoader(loadAuthorsByIds),
+		terms:         newBatchLoader(loadTermsByPosts),
+		externalMetas: newBatchLoader(loadExternalMetasByPosts),
+		preferences:   newBatchLoader(loadPreferencesByNames),
+	}
+}
+
+func int64Keys(keys []interface{}) []int64 {
+	ids := make([]int64, 0)
+	for _, key := range keys {
+		ids = append(ids, key.(int64))
+	}
+	return ids
+}
+
+func stringKeys(keys []interface{}) []string {
+	names := make([]string, 0)
+	for _, key := range keys {
+		names = append(names, key.(string))
+	}
+	return names
+}
+
+func loadAuthorsByIds(ctx context.Context, keys []interface{}) (map[interface{}]interface{}, error) {
+	authors, err := (Author{}).GetByIds(ctx, int64Keys(keys))
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[interface{}]interface{})
+	for id, author := range authors {
+		values[id] = author
+	}
+	return values, nil
+}
+
+func loadTermsByPosts(ctx context.Context, keys []interface{}) (map[interface{}]interface{}, error) {
+	postIds := int64Keys(keys)
+	posts := make([]Post, 0)
+	for _, id := range postIds {
+		posts = append(posts, Post{ID: id})
+	}
+	if err := loadCategoriesAndTerms(ctx, posts, postIds); err != nil {
+		return nil, err
+	}
+
+	values := make(map[interface{}]interface{})
+	for _, post := range posts {
+		values[post.ID] = postTerms{Categories: post.Categories, Tags: post.Tags}
+	}
+	return values, nil
+}
+
+func loadExternalMetasByPosts(ctx context.Context, keys []interface{}) (map[interface{}]interface{}, error) {
+	postIds := int64Keys(keys)
+	metas, err := (PostExternalMeta{}).GetByPosts(ctx, postIds)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[interface{}]interface{})
+	for _, id := range postIds {
+		if metas[id] == nil {
+			values[id] = make([]PostExternalMeta, 0)
+		} else {
+			values[id] = metas[id]
+		}
+	}
+	return values, nil
+}
+
+func loadPreferencesByNames(ctx context.Context, keys []interface{}) (map[interface{}]interface{}, error) {
+	preferences, err := (SitePreference{}).GetByNames(ctx, stringKeys(keys))
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[interface{}]interface{})
+	for name, preference := range preferences {
+		values[name] = preference
+	}
+	return values, nil
+}
+
+func withLoaders(ctx context.Context, l *loaders) context.Context {
+	return context.WithValue(ctx, "LOADERS", l)
+}
+
+// getLoaders returns the loaders of the request. A request without loaders gets new ones.
+func getLoaders(ctx context.Context) *loaders {
+	if l, ok := ctx.Value("LOADERS").(*loaders); ok {
+		return l
+	}
+	return newLoaders()
+}
@@ -2,61 +2,75 @@ package main
 
 import (
 	"github.com/labstack/echo"
-	"github.com/go-xorm/xorm"
-	_ "github.com/go-sql-driver/mysql"
 	"fmt"
 	"github.com/labstack/echo/middleware"
 	"net/http"
 	"log"
 	"os"
-	"time"
-	"context"
 	"strconv"
-	"strings"
 	"net/url"
 	"io/ioutil"
 	"encoding/json"
+	"math/rand"
 )
 
-var (
-	xormDb *xorm.Engine
-)
+type ApiResult struct {
+	Data  interface{} 	`json:"data"`
+	Success bool        `json:"success"`
+	Message string      `json:"message"`
+	Code ErrorCode      `json:"code,omitempty"`
+}
 
-func init() {
-	dbConn := os.Getenv("DB_CONN")
-	if len(dbConn) == 0 {
-		dbConn = "root:@tcp(127.0.0.1:3306)/wordpress?charset=utf8&parseTime=True"
+func main() {
+	config, err := LoadConfig(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		log.Fatalf("Config error: %s \n", err)
 	}
 
-	db, err := xorm.NewEngine("mysql", dbConn)
+	sites, err := NewSiteRegistry(config)
 	if err != nil {
-		panic(fmt.Errorf("Database open error: %s \n", err))
+		log.Fatalf("Storage open error: %s \n", err)
 	}
-	db.ShowSQL(false)
-	db.SetMaxOpenConns(100)
-	db.SetMaxIdleConns(20)
-	db.SetConnMaxLifetime(60 * time.Second)
+	defer sites.Close()
 
-	xormDb = db
+	cache, err := NewResponseCache(config.Cache)
+	if err != nil {
+		log.Fatalf("Cache error: %s \n", err)
+	}
+	defer cache.Close()
+	sites.SharePostIndexes(cache)
 
-}
+	for _, site := range sites.Sites() {
+		if len(site.Config.SearchIndexAPI) == 0 {
+			fmt.Println("No searchIndexApi, posts of the site are not reindexed: site=", site.Name)
+		}
+	}
+	reindexQueue := NewReindexQueue(REINDEX_QUEUE_SIZE, NewSearchIndexReindexer())
+	defer reindexQueue.Close()
 
-type ApiResult struct {
-	Data  interface{} 	`json:"data"`
-	Success bool        `json:"success"`
-	Message string      `json:"message"`
+	e := newServer(sites, cache, reindexQueue)
+	log.Fatal(e.Start(config.Listen))
 }
 
-func main() {
-	defer xormDb.Close()
-
+// newServer makes the server of all routes with the middlewares resolving the site, the cache and the store.
+func newServer(sites *SiteRegistry, cache *ResponseCache, reindexQueue *ReindexQueue) *echo.Echo {
 	e := echo.New()
+	e.HTTPErrorHandler = apiErrorHandler
 
 	e.Pre(middleware.RemoveTrailingSlash())
 	e.Use(middleware.Recover())
 	e.Use(middleware.CORS())
-	e.Use(setDbConnContext(xormDb))
+	e.Use(setSiteContext(sites))
+	e.Use(setFieldsContext())
+	e.Use(cacheResponse(cache))
+	e.Use(setStoreContext())
 
+	registerRoutes(e, cache, reindexQueue)
+	return e
+}
+
+// registerRoutes adds all routes of the API. Every route must be described in apiOperations(openapi.go).
+func registerRoutes(e *echo.Echo, cache *ResponseCache, reindexQueue *ReindexQueue) {
 	e.GET("/api/Search", SearchPosts)
 	e.GET("/api/RecentPosts", GetRecentPosts)
 	e.GET("/api/TagPosts", GetTagPosts)
@@ -72,50 +86,39 @@ func main() {
 	e.GET("/api/GetSlideShareEmbedLink", GetSlideShareEmbedLink)
 	e.GET("/api/GetSitePreference", GetSitePreference)
 
+	e.GET("/api/v2/posts", GetPostsV2)
+	e.GET("/api/v2/posts/:id", GetPostV2)
+	e.GET("/api/v2/posts/:id/markdown", GetPostMarkdownV2)
+	e.GET("/api/v2/posts/slug/:slug", GetPostBySlugV2)
+	e.GET("/api/v2/authors/:login", GetAuthorV2)
+	e.GET("/api/v2/authors/:login/posts", GetAuthorPostsV2)
+	e.GET("/api/v2/tags/:slug/posts", GetTermPostsV2("post_tag"))
+	e.GET("/api/v2/categories/:slug/posts", GetTermPostsV2("category"))
+	e.GET("/api/v2/preferences/:name", GetPreferenceV2)
 
-	log.Fatal(e.Start(":8000"))
-}
+	e.POST("/api/hooks/wordpress", WordPressHook(cache, reindexQueue))
 
-func setDbConnContext(xormDb *xorm.Engine) echo.MiddlewareFunc {
-	return func(next echo.HandlerFunc) echo.HandlerFunc {
-		return func(ctx echo.Context) error {
-			session := xormDb.NewSession()
-			defer session.Close()
+	e.GET("/api/openapi.json", GetOpenAPIDocument(NewOpenAPIDocument()))
+	e.GET("/api/docs", GetAPIDocs)
 
-			req := ctx.Request()
-			ctx.SetRequest(req.WithContext(context.WithValue(req.Context(), "DB", session)))
-
-			return next(ctx)
-		}
-	}
-}
-
-func GetDBConn(ctx context.Context) *xorm.Session {
-	v := ctx.Value("DB")
-	if v == nil {
-		panic("DB is not exist")
-	}
-	if db, ok := v.(*xorm.Session); ok {
-		return db
+	schema, err := NewGraphQLSchema()
+	if err != nil {
+		log.Fatalf("GraphQL schema error: %s \n", err)
 	}
-	panic("DB is not exist")
+	e.GET("/graphql", GraphQL(schema))
+	e.POST("/graphql", GraphQL(schema))
 }
 
 func GetSitePreference(c echo.Context) error {
-	name := c.QueryParam("name")
-	if len(name) == 0 {
-		return c.JSON(http.StatusBadRequest, ApiResult{
-			Success: false,
-			Message: "No name parameter",
-		})
+	params, err := parseParams(c, slugRule("name"))
+	if err != nil {
+		return err
 	}
+	name := params.String("name")
 
 	sitePref, err := (SitePreference{}).GetByName(c.Request().Context(), name)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, ApiResult{
-			Success: false,
-			Message: err.Error(),
-		})
+		return err
 	}
 
 	if sitePref == nil {
@@ -134,99 +137,136 @@ func GetSitePreference(c echo.Context) error {
 }
 
 func SearchPosts(c echo.Context) error {
-	keyword := c.QueryParam("keyword")
-	if len(keyword) == 0 {
-		return c.JSON(http.StatusBadRequest, ApiResult{
-			Success: false,
-			Message: "No keyword",
-		})
-	}
-
-	page, err := strconv.Atoi(c.QueryParam("page"))
+	params, err := parseParams(c, keywordRule, pageRule, includeTotalRule)
 	if err != nil {
-		page = 1
+		return err
 	}
+	page := params.Int("page")
 
-	posts, err := Post{}.Search(c.Request().Context(), keyword, page)
+	posts, totalHits, err := Post{}.Search(c.Request().Context(), params.String("keyword"), page)
 
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, ApiResult{
-			Success: false,
-			Message: err.Error(),
+		return err
+	}
+
+	if !wantsPostList(c, false) {
+		return c.JSON(http.StatusOK, ApiResult{
+			Success: true,
+			Data: posts,
+			Message: "",
 		})
 	}
 
+	// the search API decides the page size
+	pageSize := GetConfig(c.Request().Context()).SearchPageSize
+	postRange := PostRange{Offset: (page - 1) * pageSize, Limit: pageSize}
+
 	return c.JSON(http.StatusOK, ApiResult{
 		Success: true,
-		Data: posts,
+		Data: newPostList(c, postRange, false, &PostPage{Posts: posts}, int64(totalHits)),
 		Message: "",
 	})
 }
 
 func GetRecentPosts(c echo.Context) error {
-	page, err := strconv.Atoi(c.QueryParam("page"))
+	postRange, withCursors, err := getPostRange(c, 4)
 	if err != nil {
-		page = 1
-	}
-	size, err := strconv.Atoi(c.QueryParam("size"))
-	if err != nil {
-		size = 4
-	}
-
-	includeTotal := c.QueryParam("includeTotal")
-	if len(includeTotal) == 0 {
-		includeTotal = "false"
+		return err
 	}
 
-	posts, err := Post{}.GetRecent(c.Request().Context(), page, size)
+	page, err := Post{}.GetRecent(c.Request().Context(), postRange)
 
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, ApiResult{
-			Success: false,
-			Message: err.Error(),
-		})
+		return err
 	}
 
-	if includeTotal != "true" {
+	if !wantsPostList(c, withCursors) {
 		return c.JSON(http.StatusOK, ApiResult{
 			Success: true,
-			Data: posts,
+			Data: page.Posts,
 			Message: "",
 		})
-	} else {
-		numberOfPosts, err :=  Post{}.GetNumberOfPosts(c.Request().Context())
+	}
+
+	numberOfPosts, err :=  Post{}.GetNumberOfPosts(c.Request().Context())
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, ApiResult{
+		Success: true,
+		Data: newPostList(c, postRange, withCursors, page, numberOfPosts),
+		Message: "",
+	})
+}
+
+// getPostRange returns the range of posts selected by the cursor or page parameter.
+// The cursor parameter(FIRST_CURSOR for the first page) asks a page with cursors and the second result is true.
+// A wrong cursor is INVALID_PARAM.
+func getPostRange(c echo.Context, defaultSize int) (PostRange, bool, error) {
+	params, err := parseParams(c, sizeRule(defaultSize), pageRule, cursorRule, includeTotalRule)
+	if err != nil {
+		return PostRange{}, false, err
+	}
+	size := params.Int("size")
 
+	if params.Has("cursor") {
+		cursor, err := DecodePostCursor(params.String("cursor"))
 		if err != nil {
-			return c.JSON(http.StatusInternalServerError, ApiResult{
-				Success: false,
-				Message: err.Error(),
-			})
+			return PostRange{}, false, invalidParam("cursor", params.String("cursor"))
 		}
+		return PostRange{Limit: size, Cursor: cursor}, true, nil
+	}
 
-		data := struct {
-			Total int64 `json:"total"`
-			Posts []Post  `json:"posts"`
-		}{
-			numberOfPosts,
-			posts,
-		}
-		return c.JSON(http.StatusOK, ApiResult{
-			Success: true,
-			Data: data,
-			Message: "",
-		})
+	return PostRange{Offset: (params.Int("page") - 1) * size, Limit: size}, false, nil
+}
+
+// getExcludes returns ids of the excludes parameter.
+func getExcludes(c echo.Context) ([]int, error) {
+	params, err := parseParams(c, excludesRule)
+	if err != nil {
+		return nil, err
 	}
+	return params.IntList("excludes"), nil
+}
+
+// getSeed returns the seed parameter of random posts or a new seed if there is no seed parameter.
+// The seed is returned in X-Random-Seed header to get the same posts again.
+// Responses of new seeds are not cached not to give the same posts to every request without a seed.
+func getSeed(c echo.Context) (int64, error) {
+	params, err := parseParams(c, seedRule)
+	if err != nil {
+		return 0, err
+	}
+
+	seed := rand.Int63()
+	if params.Has("seed") {
+		seed = params.Int64("seed")
+	} else {
+		c.Response().Header().Set("Cache-Control", "no-store")
+	}
+
+	c.Response().Header().Set("X-Random-Seed", strconv.FormatInt(seed, 10))
+	return seed, nil
 }
 
 func GetTagPosts(c echo.Context) error {
-	isMobile := c.QueryParam("isMobile") == "true"
-	posts, err := Post{}.GetRandomPostsByTerm(c.Request().Context(), isMobile)
+	params, err := parseParams(c, isMobileRule)
+	if err != nil {
+		return err
+	}
+	isMobile := params.Bool("isMobile")
 
+	seed, err := getSeed(c)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, ApiResult{
-			Success: false,
-			Message: err.Error(),
-		})
+		return err
+	}
+
+	posts, err := Post{}.GetRandomPostsByTerm(c.Request().Context(), isMobile, seed)
+
+	if err != nil {
+		return err
 	}
 
 	return c.JSON(http.StatusOK, ApiResult{
@@ -237,15 +277,21 @@ func GetTagPosts(c echo.Context) error {
 }
 
 func GetRandomAuthorPosts(c echo.Context) error {
-	isMobile := c.QueryParam("isMobile") == "true"
+	params, err := parseParams(c, isMobileRule)
+	if err != nil {
+		return err
+	}
+	isMobile := params.Bool("isMobile")
 
-	posts, err := Post{}.GetRandomPostsByAuthor(c.Request().Context(), isMobile)
+	seed, err := getSeed(c)
+	if err != nil {
+		return err
+	}
+
+	posts, err := Post{}.GetRandomPostsByAuthor(c.Request().Context(), isMobile, seed)
 
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, ApiResult{
-			Success: false,
-			Message: err.Error(),
-		})
+		return err
 	}
 
 	return c.JSON(http.StatusOK, ApiResult{
@@ -256,135 +302,103 @@ func GetRandomAuthorPosts(c echo.Context) error {
 }
 
 func GetPostsByAuthorId(c echo.Context) error {
-	id, err := strconv.Atoi(c.QueryParam("id"))
+	params, err := parseParams(c, idRule("id"))
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, ApiResult{
-			Success: false,
-			Message: "Wrong id parameter[" + c.QueryParam("id") + "]",
-		})
+		return err
 	}
+	id := params.Int("id")
 
 	author, err := Author{}.GetOne(c.Request().Context(), int64(id))
-
-	if author == nil {
-		return c.JSON(http.StatusNotFound, ApiResult{
-			Success: false,
-			Message: "Author not found",
-		})
-	}
-
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, ApiResult{
-			Success: false,
-			Message: "Wrong author parameter[" + c.QueryParam("author") + "]",
-		})
+		return err
 	}
 
-	return getPostsByAuthor(c, author)
+	return getPostsByAuthor(c, author, false)
 }
 
 func GetPostsByAuthor(c echo.Context) error {
-	loginName := c.QueryParam("author")
-
-	author, err := Author{}.GetByLoginName(c.Request().Context(), loginName)
-
-	if author == nil {
-		return c.JSON(http.StatusNotFound, ApiResult{
-			Success: false,
-			Message: "Author " + loginName + " not found",
-		})
+	params, err := parseParams(c, slugRule("author"))
+	if err != nil {
+		return err
 	}
+	loginName := params.String("author")
 
+	author, err := Author{}.GetByLoginName(c.Request().Context(), loginName)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, ApiResult{
-			Success: false,
-			Message: "Wrong author parameter[" + c.QueryParam("author") + "]",
-		})
+		return err
 	}
 
-	return getPostsByAuthor(c, author)
+	return getPostsByAuthor(c, author, false)
 }
 
-func getPostsByAuthor(c echo.Context, author *Author) error {
-	excludesParam := c.QueryParam("excludes")
-
-	excludes := make([]int, 0)
-	if len(strings.TrimSpace(excludesParam)) > 0 {
-		for _, eachIdStr := range strings.Split(excludesParam, ",") {
-			id, err := strconv.Atoi(eachIdStr)
-			if err != nil {
-				return c.JSON(http.StatusBadRequest, ApiResult{
-					Success: false,
-					Message: "Wrong exclude post id: " + excludesParam,
-				})
-			}
-			excludes = append(excludes, id)
-		}
+// getPostsByAuthor responds posts of the author. alwaysList responds PostList even if the client does not ask.
+func getPostsByAuthor(c echo.Context, author *Author, alwaysList bool) error {
+	excludes, err := getExcludes(c)
+	if err != nil {
+		return err
 	}
 
-	page, err := strconv.Atoi(c.QueryParam("page"))
+	postRange, withCursors, err := getPostRange(c, 2)
 	if err != nil {
-		page = 1
+		return err
 	}
 
-	size, err := strconv.Atoi(c.QueryParam("size"))
+	page, err := Post{}.GetByAuthor(c.Request().Context(), int64(author.ID), excludes, postRange)
 	if err != nil {
-		size = 2
+		return err
 	}
 
-	posts, err := Post{}.GetByAuthor(c.Request().Context(), int64(author.ID), excludes, page, size)
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, ApiResult{
-			Success: false,
-			Message: err.Error(),
+	if !alwaysList && !wantsPostList(c, withCursors) {
+		return c.JSON(http.StatusOK, ApiResult{
+			Success: true,
+			Data: AuthorPosts {
+				Author: *author,
+				Posts: page.Posts,
+			},
+			Message: "",
 		})
 	}
 
-	type AuthorPosts struct {
-		Author Author	`json:"author"`
-		Posts []Post	`json:"posts"`
+	total, err := Post{}.CountByAuthor(c.Request().Context(), int64(author.ID), excludes)
+	if err != nil {
+		return err
 	}
 
 	return c.JSON(http.StatusOK, ApiResult{
 		Success: true,
-		Data: AuthorPosts {
+		Data: AuthorPostList{
 			Author: *author,
-			Posts: posts,
+			PostList: newPostList(c, postRange, withCursors, page, total),
 		},
 		Message: "",
 	})
 }
 
 func GetGoogleAd(c echo.Context) error {
-	mode := c.QueryParam("mode")
+	params, err := parseParams(c, modeRule)
+	if err != nil {
+		return err
+	}
+	mode := params.String("mode")
 	ads := make(map[string]SitePreference)
 
 	adKey := "ad." + mode + ".top";
 	if ad, err := (SitePreference{}).GetByName(c.Request().Context(), adKey); err != nil {
-		return c.JSON(http.StatusInternalServerError, ApiResult{
-			Success: false,
-			Message: err.Error(),
-		})
+		return err
 	} else if ad != nil {
 		ads[adKey] = *ad
 	}
 
 	adKey = "ad." + mode + ".middle";
 	if ad, err := (SitePreference{}).GetByName(c.Request().Context(), adKey); err != nil {
-		return c.JSON(http.StatusInternalServerError, ApiResult{
-			Success: false,
-			Message: err.Error(),
-		})
+		return err
 	} else if ad != nil {
 		ads[adKey] = *ad
 	}
 
 	adKey = "ad." + mode + ".bottom";
 	if ad, err := (SitePreference{}).GetByName(c.Request().Context(), adKey); err != nil {
-		return c.JSON(http.StatusInternalServerError, ApiResult{
-			Success: false,
-			Message: err.Error(),
-		})
+		return err
 	} else if ad != nil {
 		ads[adKey] = *ad
 	}
@@ -396,27 +410,19 @@ func GetGoogleAd(c echo.Context) error {
 	})
 }
 func GetPostById(c echo.Context) error {
-	id, err := strconv.Atoi(c.QueryParam("id"))
+	params, err := parseParams(c, idRule("id"), formatRule)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, ApiResult{
-			Success: false,
-			Message: "Wrong id parameter[" + c.QueryParam("id") + "]",
-		})
+		return err
 	}
-	post, err := Post{}.GetPostById(c.Request().Context(), int64(id))
-
-	if post == nil {
-		return c.JSON(http.StatusNotFound, ApiResult{
-			Success: false,
-			Message: fmt.Sprintf("Post %v Not Found", id),
-		})
+	id := params.Int("id")
+	ctx := withContentFormat(c.Request().Context(), params.String("format"))
+	post, err := Post{}.GetPostById(ctx, int64(id))
+	if err != nil {
+		return err
 	}
 
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, ApiResult{
-			Success: false,
-			Message: err.Error(),
-		})
+	if post == nil {
+		return NewApiError(POST_NOT_FOUND, fmt.Sprintf("Post %v Not Found", id))
 	}
 
 	return c.JSON(http.StatusOK, ApiResult{
@@ -427,28 +433,20 @@ func GetPostById(c echo.Context) error {
 }
 
 func GetPostByPermalink(c echo.Context) error {
-	permalink := c.QueryParam("permalink")
-	permalink = url.QueryEscape(permalink)
-	if len(permalink) == 0 {
-		return c.JSON(http.StatusBadRequest, ApiResult{
-			Success: false,
-			Message: "Wrong permalink parameter[" + c.QueryParam("permalink") + "]",
-		})
+	params, err := parseParams(c, slugRule("permalink"), formatRule)
+	if err != nil {
+		return err
 	}
-	post, err := Post{}.GetByPermalink(c.Request().Context(), permalink)
+	permalink := url.QueryEscape(params.String("permalink"))
+	ctx := withContentFormat(c.Request().Context(), params.String("format"))
 
-	if post == nil {
-		return c.JSON(http.StatusNotFound, ApiResult{
-			Success: false,
-			Message: permalink + " Not Found",
-		})
+	post, err := Post{}.GetByPermalink(ctx, permalink)
+	if err != nil {
+		return err
 	}
 
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, ApiResult{
-			Success: false,
-			Message: err.Error(),
-		})
+	if post == nil {
+		return NewApiError(POST_NOT_FOUND, permalink + " Not Found")
 	}
 
 	return c.JSON(http.StatusOK, ApiResult{
@@ -459,161 +457,116 @@ func GetPostByPermalink(c echo.Context) error {
 }
 
 func GetPostsByCategory(c echo.Context) error {
-	category := c.QueryParam("category")
-	if len(category) == 0 {
-		return c.JSON(http.StatusBadRequest, ApiResult{
-			Success: false,
-			Message: "Wrong tag parameter[" + c.QueryParam("category") + "]",
-		})
+	params, err := parseParams(c, slugRule("category"))
+	if err != nil {
+		return err
 	}
-
-	category = url.QueryEscape(category);
+	category := url.QueryEscape(params.String("category"))
 
 	term, err := Term{}.FinyBySlug(c.Request().Context(), category, "category")
-
-	if term == nil {
-		return c.JSON(http.StatusNotFound, ApiResult{
-			Success: false,
-			Message: err.Error(),
-		})
-	}
-
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, ApiResult{
-			Success: false,
-			Message: err.Error(),
-		})
+		return err
 	}
 
-	return getPostsByTagId(c, term.ID)
+	return getPostsByTagId(c, term.ID, false)
 }
 
 func GetPostsByTag(c echo.Context) error {
-	tag := c.QueryParam("tag")
-	if len(tag) == 0 {
-		return c.JSON(http.StatusBadRequest, ApiResult{
-			Success: false,
-			Message: "Wrong tag parameter[" + c.QueryParam("tag") + "]",
-		})
+	params, err := parseParams(c, slugRule("tag"))
+	if err != nil {
+		return err
 	}
-
-	tag = url.QueryEscape(tag);
+	tag := url.QueryEscape(params.String("tag"))
 
 	term, err := Term{}.FinyBySlug(c.Request().Context(), tag, "post_tag")
-
-	if term == nil {
-		return c.JSON(http.StatusNotFound, ApiResult{
-			Success: false,
-			Message: err.Error(),
-		})
-	}
-
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, ApiResult{
-			Success: false,
-			Message: err.Error(),
-		})
+		return err
 	}
 
-	return getPostsByTagId(c, term.ID)
+	return getPostsByTagId(c, term.ID, false)
 }
 
 func GetPostsByTagId(c echo.Context) error {
-	id, err := strconv.Atoi(c.QueryParam("id"))
+	params, err := parseParams(c, idRule("id"))
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, ApiResult{
-			Success: false,
-			Message: "Wrong id parameter[" + c.QueryParam("id") + "]",
-		})
+		return err
 	}
+	id := params.Int("id")
 
-	return getPostsByTagId(c, id)
+	return getPostsByTagId(c, id, false)
 }
 
-func getPostsByTagId(c echo.Context, id int) error {
-	excludesParam := c.QueryParam("excludes")
-
-	excludes := make([]int, 0)
-	if len(excludesParam) > 0 {
-		for _, eachIdStr := range strings.Split(excludesParam, ",") {
-			id, err := strconv.Atoi(eachIdStr)
-			if err != nil {
-				return c.JSON(http.StatusBadRequest, ApiResult{
-					Success: false,
-					Message: "Wrong exclude post id: " + excludesParam,
-				})
-			}
-			excludes = append(excludes, id)
-		}
+// getPostsByTagId responds posts of the term. alwaysList responds PostList even if the client does not ask.
+func getPostsByTagId(c echo.Context, id int, alwaysList bool) error {
+	excludes, err := getExcludes(c)
+	if err != nil {
+		return err
 	}
 
-	page, err := strconv.Atoi(c.QueryParam("page"))
+	postRange, withCursors, err := getPostRange(c, 2)
 	if err != nil {
-		page = 1
+		return err
 	}
 
-	size, err := strconv.Atoi(c.QueryParam("size"))
+	page, err := Post{}.GetByTag(c.Request().Context(), id, excludes, postRange)
 	if err != nil {
-		size = 2
+		return err
 	}
 
-	posts, err := Post{}.GetByTag(c.Request().Context(), id, excludes, page, size)
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, ApiResult{
-			Success: false,
-			Message: err.Error(),
+	if !alwaysList && !wantsPostList(c, withCursors) {
+		return c.JSON(http.StatusOK, ApiResult{
+			Success: true,
+			Data: page.Posts,
+			Message: "",
 		})
 	}
 
+	total, err := Post{}.CountByTag(c.Request().Context(), id, excludes)
+	if err != nil {
+		return err
+	}
+
 	return c.JSON(http.StatusOK, ApiResult{
 		Success: true,
-		Data: posts,
+		Data: newPostList(c, postRange, withCursors, page, total),
 		Message: "",
 	})
 }
 
 func GetSlideShareEmbedLink(c echo.Context) error {
-	link := c.QueryParam("link")
+	params, err := parseParams(c, linkRule)
+	if err != nil {
+		return err
+	}
+	link := params.String("link")
 
-	slideshareApi := fmt.Sprintf(`https://www.slideshare.net/api/oembed/2?url=%v&format=json`, link)
+	slideshareApi := fmt.Sprintf(`https://www.slideshare.net/api/oembed/2?url=%v&format=json`, url.QueryEscape(link))
 	req, err := http.NewRequest("GET", slideshareApi, nil)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, ApiResult{
-			Success: false,
-			Message: err.Error(),
-		})
+		return err
 	}
 	httpClient := &http.Client{}
 	resp, err := httpClient.Do(req)
 	if err != nil {
 		fmt.Println("ERROR sending request:", err.Error())
-		c.JSON(http.StatusInternalServerError, ApiResult{
-			Success: false,
-			Message: err.Error(),
-		})
+		return upstreamError(err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 300 || resp.StatusCode < 200 {
 		fmt.Println("ERROR wrong status code:", resp.StatusCode, " ==>", slideshareApi)
-		c.JSON(http.StatusInternalServerError, ApiResult{
-			Success: false,
-			Message: fmt.Sprintf("wrong http response status code: %s", resp.StatusCode),
-		})
+		return NewApiError(UPSTREAM_FAILED, fmt.Sprintf("wrong http response status code: %v", resp.StatusCode))
 	}
 	jsonResult, _ := ioutil.ReadAll(resp.Body)
 
 	jsonMap := make(map[string]interface{})
 	err = json.Unmarshal(jsonResult, &jsonMap)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, ApiResult{
-			Success: false,
-			Message: fmt.Sprintf("wrong http response status code: %s", resp.StatusCode),
-		})
+		return NewApiError(UPSTREAM_FAILED, "wrong oEmbed response: " + err.Error())
 	}
 	return c.JSON(http.StatusOK, ApiResult{
 		Success: true,
 		Data: jsonMap,
 		Message: "",
 	})
-}
\ No newline at end of file
+}
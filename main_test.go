@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/labstack/echo"
+)
+
+const TEST_WEBHOOK_SECRET = "test-secret"
+
+// testServer serves sample_data.json like main with the memory storage and the memory cache.
+type testServer struct {
+	*echo.Echo
+	sites        *SiteRegistry
+	cache        *ResponseCache
+	reindexQueue *ReindexQueue
+}
+
+func newTestServer(t *testing.T, reindexer Reindexer) *testServer {
+	config := DefaultConfig()
+	config.DB = DBConfig{Driver: "memory", Conn: "sample_data.json"}
+	config.WebhookSecret = TEST_WEBHOOK_SECRET
+	config.SearchIndexAPI = "http://127.0.0.1:8098"
+
+	sites, err := NewSiteRegistry(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache, err := NewResponseCache(config.Cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sites.SharePostIndexes(cache)
+
+	reindexQueue := NewReindexQueue(REINDEX_QUEUE_SIZE, reindexer)
+	return &testServer{
+		Echo:         newServer(sites, cache, reindexQueue),
+		sites:        sites,
+		cache:        cache,
+		reindexQueue: reindexQueue,
+	}
+}
+
+func (s *testServer) Close() {
+	s.reindexQueue.Close()
+	s.cache.Close()
+	s.sites.Close()
+}
+
+func (s *testServer) request(method string, target string, body []byte, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, bytes.NewReader(body))
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	return rec
+}
+
+func (s *testServer) get(target string) *httptest.ResponseRecorder {
+	return s.request(http.MethodGet, target, nil, nil)
+}
+
+// testResponse is a response of ApiResult with the data left encoded.
+type testResponse struct {
+	Code   int
+	Header http.Header
+	Body   string
+	Result struct {
+		Data    json.RawMessage `json:"data"`
+		Success bool            `json:"success"`
+		Message string          `json:"message"`
+		Code    ErrorCode       `json:"code"`
+	}
+}
+
+func newTestResponse(rec *httptest.ResponseRecorder) *testResponse {
+	res := &testResponse{Code: rec.Code, Header: rec.Header(), Body: rec.Body.String()}
+	json.Unmarshal(rec.Body.Bytes(), &res.Result)
+	return res
+}
+
+func equalStrings(values1 []string, values2 []string) bool {
+	if len(values1) != len(values2) {
+		return false
+	}
+	for i := range values1 {
+		if values1[i] != values2[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// testPostList is PostList or AuthorPostList with only ids of the posts.
+type testPostList struct {
+	Author *struct {
+		ID int64 `json:"id"`
+	} `json:"author"`
+	Posts []struct {
+		ID int64 `json:"id"`
+	} `json:"posts"`
+	Total      int64  `json:"total"`
+	Page       int    `json:"page"`
+	Size       int    `json:"size"`
+	HasNext    bool   `json:"hasNext"`
+	Next       string `json:"next"`
+	Prev       string `json:"prev"`
+	NextCursor string `json:"nextCursor"`
+	PrevCursor string `json:"prevCursor"`
+}
+
+func (l testPostList) ids() []int64 {
+	ids := make([]int64, 0)
+	for _, post := range l.Posts {
+		ids = append(ids, post.ID)
+	}
+	return ids
+}
+
+// getPosts requests the list of posts. Bare lists and AuthorPosts are returned as testPostList without paging.
+func (s *testServer) getPosts(t *testing.T, target string) testPostList {
+	res := newTestResponse(s.get(target))
+	if res.Code != http.StatusOK || !res.Result.Success {
+		t.Fatalf("%v: unexpected response %v %v", target, res.Code, res.Body)
+	}
+
+	var list testPostList
+	if bytes.HasPrefix(res.Result.Data, []byte("[")) {
+		err := json.Unmarshal(res.Result.Data, &list.Posts)
+		if err != nil {
+			t.Fatalf("%v: %v", target, err)
+		}
+		return list
+	}
+	if err := json.Unmarshal(res.Result.Data, &list); err != nil {
+		t.Fatalf("%v: %v", target, err)
+	}
+	return list
+}
+
+func equalIds(ids1 []int64, ids2 []int64) bool {
+	return fmt.Sprint(ids1) == fmt.Sprint(ids2)
+}
+
+func TestPostListsByPage(t *testing.T) {
+	tests := []struct {
+		target   string
+		ids      []int64
+		authorId int64
+	}{
+		{"/api/RecentPosts", []int64{104, 102, 101, 100}, 0},
+		{"/api/RecentPosts?size=3&page=2", []int64{100}, 0},
+		{"/api/RecentPosts?size=2&page=3", []int64{}, 0},
+		{"/api/PostsByTag?tag=go", []int64{104, 101}, 0},
+		{"/api/PostsByTag?tag=wordpress&size=2&page=2", []int64{100}, 0},
+		{"/api/PostsByTag?tag=wordpress&size=5&excludes=104", []int64{102, 100}, 0},
+		{"/api/PostsByTagId?id=20&size=5", []int64{104, 101, 100}, 0},
+		{"/api/PostsByAuthor?author=popit", []int64{101, 100}, 1},
+		{"/api/PostsByAuthor?author=writer&size=1&page=2", []int64{102}, 2},
+		{"/api/PostsByAuthorId?id=2&size=5", []int64{104, 102}, 2},
+	}
+
+	server := newTestServer(t, testReindexer{jobs: make(chan reindexJob, 10)})
+	defer server.Close()
+
+	for _, test := range tests {
+		list := server.getPosts(t, test.target)
+		if !equalIds(list.ids(), test.ids) {
+			t.Errorf("%v: expected posts %v, got %v", test.target, test.ids, list.ids())
+		}
+		if test.authorId > 0 && (list.Author == nil || list.Author.ID != test.authorId) {
+			t.Errorf("%v: expected author %v, got %+v", test.target, test.authorId, list.Author)
+		}
+	}
+}
+
+func TestPostListsWithTotal(t *testing.T) {
+	tests := []struct {
+		target   string
+		expected testPostList
+	}{
+		{"/api/RecentPosts?size=3&includeTotal=true",
+			testPostList{Total: 4, Page: 1, Size: 3, HasNext: true, Next: "/api/RecentPosts?includeTotal=true&page=2&size=3"}},
+		{"/api/RecentPosts?size=3&page=2&includeTotal=true",
+			testPostList{Total: 4, Page: 2, Size: 3, Prev: "/api/RecentPosts?includeTotal=true&page=1&size=3"}},
+		{"/api/PostsByTag?tag=wordpress&size=3&includeTotal=true",
+			testPostList{Total: 3, Page: 1, Size: 3}},
+		{"/api/PostsByAuthor?author=writer&size=1&includeTotal=true",
+			testPostList{Total: 2, Page: 1, Size: 1, HasNext: true, Next: "/api/PostsByAuthor?author=writer&includeTotal=true&page=2&size=1"}},
+	}
+
+	server := newTestServer(t, testReindexer{jobs: make(chan reindexJob, 10)})
+	defer server.Close()
+
+	for _, test := range tests {
+		list := server.getPosts(t, test.target)
+		list.Author, list.Posts = nil, nil
+		if !reflect.DeepEqual(list, test.expected) {
+			t.Errorf("%v: expected %+v, got %+v", test.target, test.expected, list)
+		}
+	}
+}
+
+func TestPostListsByCursor(t *testing.T) {
+	tests := []struct {
+		target string
+		pages  [][]int64
+	}{
+		{"/api/RecentPosts?size=3", [][]int64{{104, 102, 101}, {100}}},
+		{"/api/RecentPosts?size=2", [][]int64{{104, 102}, {101, 100}}},
+		{"/api/PostsByTag?tag=wordpress&size=2", [][]int64{{104, 102}, {100}}},
+		{"/api/PostsByTagId?id=20&size=1", [][]int64{{104}, {101}, {100}}},
+		{"/api/PostsByAuthor?author=popit&size=1", [][]int64{{101}, {100}}},
+		{"/api/PostsByAuthorId?id=2&size=5", [][]int64{{104, 102}}},
+	}
+
+	server := newTestServer(t, testReindexer{jobs: make(chan reindexJob, 10)})
+	defer server.Close()
+
+	for _, test := range tests {
+		// forward from the first page to the last
+		pages := make([]testPostList, 0)
+		target := test.target + "&cursor=" + FIRST_CURSOR
+		for len(target) > 0 && len(pages) <= len(test.pages) {
+			list := server.getPosts(t, target)
+			pages = append(pages, list)
+			target = list.Next
+		}
+
+		if len(pages) != len(test.pages) {
+			t.Errorf("%v: expected %v pages, got %v", test.target, len(test.pages), len(pages))
+			continue
+		}
+		for i, list := range pages {
+			if !equalIds(list.ids(), test.pages[i]) {
+				t.Errorf("%v: expected posts %v in page %v, got %v", test.target, test.pages[i], i+1, list.ids())
+			}
+			if list.Page != 0 {
+				t.Errorf("%v: expected no page number by cursor, got %v", test.target, list.Page)
+			}
+			if hasPrev := len(list.PrevCursor) > 0; hasPrev != (i > 0) {
+				t.Errorf("%v: expected prev cursor %v in page %v", test.target, i > 0, i+1)
+			}
+			if hasNext := list.HasNext && len(list.NextCursor) > 0; hasNext != (i < len(pages)-1) {
+				t.Errorf("%v: expected next cursor %v in page %v", test.target, i < len(pages)-1, i+1)
+			}
+		}
+
+		// and back to the first page
+		for i := len(pages) - 1; i > 0; i-- {
+			list := server.getPosts(t, pages[i].Prev)
+			if !equalIds(list.ids(), test.pages[i-1]) {
+				t.Errorf("%v: expected posts %v back in page %v, got %v", test.target, test.pages[i-1], i, list.ids())
+			}
+		}
+	}
+}
+
+func TestPostById(t *testing.T) {
+	server := newTestServer(t, testReindexer{jobs: make(chan reindexJob, 10)})
+	defer server.Close()
+
+	res := newTestResponse(server.get("/api/PostById?id=101"))
+	var post struct {
+		ID     int64  `json:"id"`
+		Title  string `json:"title"`
+		Author struct {
+			ID int64 `json:"id"`
+		} `json:"author"`
+	}
+	if err := json.Unmarshal(res.Result.Data, &post); err != nil || res.Code != http.StatusOK {
+		t.Fatalf("unexpected response %v %v", res.Code, res.Body)
+	}
+	if post.ID != 101 || post.Title != "두 번째 글" || post.Author.ID != 1 {
+		t.Errorf("unexpected post %+v", post)
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	tests := []struct {
+		target string
+		code   ErrorCode
+	}{
+		{"/api/PostById?id=999", POST_NOT_FOUND},
+		{"/api/PostByPermalink?permalink=no-post", POST_NOT_FOUND},
+		{"/api/PostsByTag?tag=no-tag", TERM_NOT_FOUND},
+		{"/api/PostsByCategory?category=no-category", TERM_NOT_FOUND},
+		// a category is not a tag
+		{"/api/PostsByTag?tag=dev", TERM_NOT_FOUND},
+		{"/api/PostsByAuthor?author=nobody", AUTHOR_NOT_FOUND},
+		{"/api/PostsByAuthorId?id=999", AUTHOR_NOT_FOUND},
+		{"/api/v2/posts/999", POST_NOT_FOUND},
+		{"/api/v2/tags/no-tag/posts", TERM_NOT_FOUND},
+		{"/api/v2/authors/nobody", AUTHOR_NOT_FOUND},
+		{"/api/NoSuchApi", ROUTE_NOT_FOUND},
+	}
+
+	server := newTestServer(t, testReindexer{jobs: make(chan reindexJob, 10)})
+	defer server.Close()
+
+	for _, test := range tests {
+		res := newTestResponse(server.get(test.target))
+		if res.Code != http.StatusNotFound || res.Result.Success || res.Result.Code != test.code {
+			t.Errorf("%v: expected 404 %v, got %v %v", test.target, test.code, res.Code, res.Body)
+		}
+	}
+}
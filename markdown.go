@@ -0,0 +1,425 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Posts are exported as GitHub flavored Markdown with YAML front matter.
+// The content is rendered in the html format first(see renderContent), so shortcodes are exported as their HTML.
+
+const MIME_MARKDOWN = "text/markdown; charset=UTF-8"
+
+// PostMarkdown returns the post as Markdown with front matter of title, date, author, tags, categories, image and
+// description(socialDesc). Empty fields are left out of the front matter.
+func PostMarkdown(post *Post) string {
+	var markdown strings.Builder
+	markdown.WriteString("---\n")
+	markdown.WriteString("title: " + strconv.Quote(post.Title) + "\n")
+	markdown.WriteString("date: " + post.PostDate.Format(time.RFC3339) + "\n")
+	if len(post.Author.DisplayName) > 0 {
+		markdown.WriteString("author: " + strconv.Quote(post.Author.DisplayName) + "\n")
+	}
+	writeFrontMatterList(&markdown, "tags", post.Tags)
+	writeFrontMatterList(&markdown, "categories", post.Categories)
+	if len(post.Image) > 0 {
+		markdown.WriteString("image: " + strconv.Quote(post.Image) + "\n")
+	}
+	if len(post.SocialDesc) > 0 {
+		// socialDesc is escaped for meta tags
+		markdown.WriteString("description: " + strconv.Quote(html.UnescapeString(post.SocialDesc)) + "\n")
+	}
+	markdown.WriteString("---\n\n")
+
+	markdown.WriteString(htmlToMarkdown(post.Content))
+	return markdown.String()
+}
+
+func writeFrontMatterList(markdown *strings.Builder, name string, terms []Term) {
+	if len(terms) == 0 {
+		return
+	}
+
+	names := make([]string, 0)
+	for _, term := range terms {
+		names = append(names, strconv.Quote(term.Name))
+	}
+	markdown.WriteString(name + ": [" + strings.Join(names, ", ") + "]\n")
+}
+
+// htmlToMarkdown converts HTML to Markdown. Unknown elements are written as their contents.
+func htmlToMarkdown(content string) string {
+	nodes, err := html.ParseFragment(strings.NewReader(content), &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body})
+	if err != nil {
+		fmt.Println("ERROR markdown parse fail:", err.Error())
+		return content
+	}
+
+	markdown := strings.TrimSpace(markdownBlocks(nodes))
+	if len(markdown) == 0 {
+		return ""
+	}
+	return markdown + "\n"
+}
+
+var markdownBlockElements = map[string]bool{
+	"p": true, "div": true, "section": true, "article": true, "header": true, "footer": true, "aside": true, "nav": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"ul": true, "ol": true, "blockquote": true, "pre": true, "hr": true, "table": true,
+	"figure": true, "figcaption": true, "dl": true, "dt": true, "dd": true, "script": true, "style": true,
+}
+
+func childNodes(node *html.Node) []*html.Node {
+	children := make([]*html.Node, 0)
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		children = append(children, child)
+	}
+	return children
+}
+
+func nodeAttr(node *html.Node, name string) string {
+	for _, attr := range node.Attr {
+		if attr.Key == name {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+// markdownBlocks converts nodes to blocks separated by blank lines. Inline nodes between blocks are a paragraph.
+func markdownBlocks(nodes []*html.Node) string {
+	blocks := make([]string, 0)
+	inline := ""
+	flush := func() {
+		if paragraph := markdownParagraph(inline); len(paragraph) > 0 {
+			blocks = append(blocks, paragraph)
+		}
+		inline = ""
+	}
+
+	for _, node := range nodes {
+		if node.Type == html.ElementNode && markdownBlockElements[node.Data] {
+			flush()
+			if block := markdownBlock(node); len(strings.TrimSpace(block)) > 0 {
+				blocks = append(blocks, block)
+			}
+		} else {
+			inline += markdownInline(node)
+		}
+	}
+	flush()
+	return strings.Join(blocks, "\n\n")
+}
+
+var markdownLineStartPattern = regexp.MustCompile(`^(\s*)([#>+=-]|\d+\.)`)
+
+// markdownParagraph trims lines of the inline Markdown and escapes characters starting blocks.
+func markdownParagraph(inline string) string {
+	lines := strings.Split(strings.TrimSpace(inline), "\n")
+	for i, line := range lines {
+		line = strings.TrimLeft(line, " ")
+		lines[i] = markdownLineStartPattern.ReplaceAllStringFunc(line, func(start string) string {
+			if strings.HasSuffix(start, ".") {
+				return strings.TrimSuffix(start, ".") + `\.`
+			}
+			return `\` + start
+		})
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+func markdownBlock(node *html.Node) string {
+	switch node.Data {
+	case "p", "dt":
+		return markdownParagraph(markdownInlines(childNodes(node)))
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		level := int(node.Data[1] - '0')
+		return strings.Repeat("#", level) + " " + strings.Replace(markdownParagraph(markdownInlines(childNodes(node))), "\n", " ", -1)
+	case "ul", "ol":
+		return markdownList(node)
+	case "blockquote", "dd":
+		return prefixLines(markdownBlocks(childNodes(node)), "> ", ">")
+	case "pre":
+		return markdownCodeBlock(node)
+	case "hr":
+		return "---"
+	case "table":
+		return markdownTable(node)
+	case "figcaption":
+		if caption := markdownParagraph(markdownInlines(childNodes(node))); len(caption) > 0 {
+			return "*" + caption + "*"
+		}
+		return ""
+	case "script", "style":
+		return ""
+	default:
+		return markdownBlocks(childNodes(node))
+	}
+}
+
+// prefixLines prefixes each line, or empty lines with emptyPrefix.
+func prefixLines(text string, prefix string, emptyPrefix string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if len(line) == 0 {
+			lines[i] = emptyPrefix
+		} else {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func markdownList(node *html.Node) string {
+	number := 1
+	if start, err := strconv.Atoi(nodeAttr(node, "start")); err == nil {
+		number = start
+	}
+
+	items := make([]string, 0)
+	for _, item := range childNodes(node) {
+		if item.Type != html.ElementNode || item.Data != "li" {
+			continue
+		}
+
+		marker := "- "
+		if node.Data == "ol" {
+			marker = fmt.Sprintf("%v. ", number)
+			number++
+		}
+
+		content := markdownListItem(item)
+		indented := prefixLines(content, strings.Repeat(" ", len(marker)), "")
+		items = append(items, marker+strings.TrimLeft(indented, " "))
+	}
+	return strings.Join(items, "\n")
+}
+
+// markdownListItem converts the contents of an item. Nested lists follow without a blank line to keep lists tight.
+func markdownListItem(item *html.Node) string {
+	parts := make([]string, 0)
+	separators := make([]string, 0)
+	others := make([]*html.Node, 0)
+	add := func(part string, separator string) {
+		if len(strings.TrimSpace(part)) > 0 {
+			parts = append(parts, part)
+			separators = append(separators, separator)
+		}
+	}
+
+	for _, child := range childNodes(item) {
+		if child.Type == html.ElementNode && (child.Data == "ul" || child.Data == "ol") {
+			add(markdownBlocks(others), "\n\n")
+			others = make([]*html.Node, 0)
+			add(markdownList(child), "\n")
+		} else {
+			others = append(others, child)
+		}
+	}
+	add(markdownBlocks(others), "\n\n")
+
+	var content strings.Builder
+	for i, part := range parts {
+		if i > 0 {
+			content.WriteString(separators[i])
+		}
+		content.WriteString(part)
+	}
+	return content.String()
+}
+
+var markdownBacktickPattern = regexp.MustCompile("`+")
+
+// codeFence returns a fence of at least minLength backticks longer than backticks in the code.
+func codeFence(code string, minLength int) string {
+	fence := strings.Repeat("`", minLength)
+	for _, backticks := range markdownBacktickPattern.FindAllString(code, -1) {
+		if len(backticks) >= len(fence) {
+			fence = strings.Repeat("`", len(backticks)+1)
+		}
+	}
+	return fence
+}
+
+var codeLanguagePattern = regexp.MustCompile(`(?:^|\s)(?:language-|lang-|brush:\s*)([\w+#-]+)`)
+
+func markdownCodeBlock(node *html.Node) string {
+	language := ""
+	for _, each := range append([]*html.Node{node}, childNodes(node)...) {
+		if match := codeLanguagePattern.FindStringSubmatch(nodeAttr(each, "class")); match != nil {
+			language = match[1]
+			break
+		}
+	}
+
+	code := strings.Trim(nodeText(node), "\n")
+	fence := codeFence(code, 3)
+	return fence + language + "\n" + code + "\n" + fence
+}
+
+// nodeText returns the texts of the node and its descendants as they are.
+func nodeText(node *html.Node) string {
+	if node.Type == html.TextNode {
+		return node.Data
+	}
+	if node.Type == html.ElementNode && node.Data == "br" {
+		return "\n"
+	}
+
+	var text strings.Builder
+	for _, child := range childNodes(node) {
+		text.WriteString(nodeText(child))
+	}
+	return text.String()
+}
+
+func markdownTable(node *html.Node) string {
+	rows := make([][]string, 0)
+	var collect func(node *html.Node)
+	collect = func(node *html.Node) {
+		for _, child := range childNodes(node) {
+			if child.Type != html.ElementNode {
+				continue
+			}
+			if child.Data != "tr" {
+				collect(child)
+				continue
+			}
+
+			cells := make([]string, 0)
+			for _, cell := range childNodes(child) {
+				if cell.Type == html.ElementNode && (cell.Data == "th" || cell.Data == "td") {
+					// "|" is already escaped by markdownEscaper
+					cells = append(cells, strings.Replace(markdownParagraph(markdownInlines(childNodes(cell))), "\n", " ", -1))
+				}
+			}
+			rows = append(rows, cells)
+		}
+	}
+	collect(node)
+	if len(rows) == 0 {
+		return ""
+	}
+
+	columns := 0
+	for _, row := range rows {
+		if len(row) > columns {
+			columns = len(row)
+		}
+	}
+
+	lines := make([]string, 0)
+	for i, row := range rows {
+		for len(row) < columns {
+			row = append(row, "")
+		}
+		lines = append(lines, "| "+strings.Join(row, " | ")+" |")
+		if i == 0 {
+			lines = append(lines, "|"+strings.Repeat(" --- |", columns))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func markdownInlines(nodes []*html.Node) string {
+	var inline strings.Builder
+	for _, node := range nodes {
+		inline.WriteString(markdownInline(node))
+	}
+	return inline.String()
+}
+
+var (
+	markdownSpacesPattern = regexp.MustCompile(`\s+`)
+	markdownEscaper       = strings.NewReplacer(`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`", "[", `\[`, "]", `\]`, "<", `\<`, "|", `\|`)
+)
+
+func markdownInline(node *html.Node) string {
+	switch node.Type {
+	case html.TextNode:
+		return markdownEscaper.Replace(markdownSpacesPattern.ReplaceAllString(node.Data, " "))
+	case html.ElementNode:
+	default:
+		return ""
+	}
+
+	content := func() string {
+		return markdownInlines(childNodes(node))
+	}
+	wrap := func(mark string) string {
+		text := content()
+		trimmed := strings.TrimSpace(text)
+		if len(trimmed) == 0 {
+			return text
+		}
+		// marks must touch the text, so spaces around it are moved out
+		return text[:strings.Index(text, trimmed)] + mark + trimmed + mark + text[strings.Index(text, trimmed)+len(trimmed):]
+	}
+
+	switch node.Data {
+	case "strong", "b":
+		return wrap("**")
+	case "em", "i":
+		return wrap("*")
+	case "del", "s", "strike":
+		return wrap("~~")
+	case "code", "kbd", "tt":
+		code := markdownSpacesPattern.ReplaceAllString(nodeText(node), " ")
+		fence := codeFence(code, 1)
+		if strings.HasPrefix(code, "`") || strings.HasSuffix(code, "`") {
+			code = " " + code + " "
+		}
+		return fence + code + fence
+	case "a":
+		href := nodeAttr(node, "href")
+		text := strings.TrimSpace(content())
+		if len(href) == 0 {
+			return text
+		}
+		if len(text) == 0 {
+			text = markdownEscaper.Replace(href)
+		}
+		return "[" + text + "](" + markdownURL(href) + markdownTitle(nodeAttr(node, "title")) + ")"
+	case "img":
+		src := nodeAttr(node, "src")
+		if len(src) == 0 {
+			return ""
+		}
+		return "![" + markdownEscaper.Replace(nodeAttr(node, "alt")) + "](" + markdownURL(src) + markdownTitle(nodeAttr(node, "title")) + ")"
+	case "br":
+		return "  \n"
+	case "iframe", "video", "audio":
+		src := nodeAttr(node, "src")
+		for _, child := range childNodes(node) {
+			if len(src) == 0 && child.Type == html.ElementNode && child.Data == "source" {
+				src = nodeAttr(child, "src")
+			}
+		}
+		if len(src) == 0 {
+			return ""
+		}
+		return "[" + markdownEscaper.Replace(src) + "](" + markdownURL(src) + ")"
+	case "script", "style":
+		return ""
+	default:
+		return content()
+	}
+}
+
+// markdownURL escapes spaces and parentheses of a link destination.
+func markdownURL(link string) string {
+	return strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29").Replace(link)
+}
+
+func markdownTitle(title string) string {
+	if len(title) == 0 {
+		return ""
+	}
+	return " " + strconv.Quote(title)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHTMLToMarkdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		html     string
+		markdown string
+	}{
+		{
+			name:     "headings and paragraphs",
+			html:     "<h2>설치 <em>방법</em></h2><p>첫 문단 <strong>굵게</strong><br>둘째 줄</p><p># 제목 아님</p>",
+			markdown: "## 설치 *방법*\n\n첫 문단 **굵게**  \n둘째 줄\n\n\\# 제목 아님\n",
+		},
+		{
+			name:     "links and images",
+			html:     `<p><a href="https://www.popit.kr/a_(b)" title="Popit">팝잇</a> <img src="https://a/x.png" alt="[그림]"></p>`,
+			markdown: "[팝잇](https://www.popit.kr/a_%28b%29 \"Popit\") ![\\[그림\\]](https://a/x.png)\n",
+		},
+		{
+			name:     "nested lists",
+			html:     `<ul><li>a<ul><li>a1</li><li>a2</li></ul></li><li>b</li></ul><ol start="3"><li>c</li><li>d<ol><li>d1</li></ol></li></ol>`,
+			markdown: "- a\n  - a1\n  - a2\n- b\n\n3. c\n4. d\n   1. d1\n",
+		},
+		{
+			name:     "fenced code with a language",
+			html:     "<pre><code class=\"language-go\">func main() {\n\tfmt.Println(\"```\")\n}\n</code></pre>",
+			markdown: "````go\nfunc main() {\n\tfmt.Println(\"```\")\n}\n````\n",
+		},
+		{
+			name:     "code language of brush",
+			html:     `<pre class="brush: js">a &lt; b</pre>`,
+			markdown: "```js\na < b\n```\n",
+		},
+		{
+			name:     "inline code",
+			html:     "<p><code>a`b</code> and <code>x</code></p>",
+			markdown: "``a`b`` and `x`\n",
+		},
+		{
+			name:     "tables",
+			html:     `<table><thead><tr><th>이름</th><th>값</th></tr></thead><tbody><tr><td>1</td><td>2|3</td></tr><tr><td><a href="https://a/">링크</a></td></tr></tbody></table>`,
+			markdown: "| 이름 | 값 |\n| --- | --- |\n| 1 | 2\\|3 |\n| [링크](https://a/) |  |\n",
+		},
+		{
+			name:     "blockquotes",
+			html:     "<blockquote><p>인용</p><p>둘째</p></blockquote>",
+			markdown: "> 인용\n>\n> 둘째\n",
+		},
+	}
+
+	for _, test := range tests {
+		if markdown := htmlToMarkdown(test.html); markdown != test.markdown {
+			t.Errorf("%v: expected\n%q\ngot\n%q", test.name, test.markdown, markdown)
+		}
+	}
+}
+
+func TestPostMarkdownFrontMatter(t *testing.T) {
+	post := &Post{
+		Title:      `"Go" 시작하기`,
+		PostDate:   time.Date(2018, 5, 1, 10, 0, 0, 0, time.UTC),
+		Author:     Author{DisplayName: "Popit"},
+		Tags:       []Term{{Name: "Go"}, {Name: "WordPress"}},
+		SocialDesc: "a &amp; b",
+		Content:    "<p>본문</p>",
+	}
+
+	expected := "---\n" +
+		"title: \"\\\"Go\\\" 시작하기\"\n" +
+		"date: 2018-05-01T10:00:00Z\n" +
+		"author: \"Popit\"\n" +
+		"tags: [\"Go\", \"WordPress\"]\n" +
+		"description: \"a & b\"\n" +
+		"---\n\n본문\n"
+	if markdown := PostMarkdown(post); markdown != expected {
+		t.Errorf("expected\n%v\ngot\n%v", expected, markdown)
+	}
+	if strings.Contains(PostMarkdown(post), "categories:") {
+		t.Error("empty categories must be left out")
+	}
+}
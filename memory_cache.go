@@ -0,0 +1,155 @@
+package main
+
+import (
+	"container/list"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// MemoryCache is an in-process LRU cache. It is not shared by API servers.
+type MemoryCache struct {
+	mutex      sync.Mutex
+	maxEntries int
+	lru        *list.List
+	entries    map[string]*list.Element
+	tags       map[string]map[string]bool
+	// invalidated are the times of invalidations of tags in INVALIDATION_WINDOW.
+	invalidated map[string]time.Time
+}
+
+type memoryCacheEntry struct {
+	key       string
+	value     []byte
+	tags      []string
+	expiresAt time.Time
+}
+
+// NewMemoryCache makes a cache holding at most maxEntries values. The least recently used value is dropped first.
+func NewMemoryCache(maxEntries int) *MemoryCache {
+	return &MemoryCache{
+		maxEntries:  maxEntries,
+		lru:         list.New(),
+		entries:     make(map[string]*list.Element),
+		tags:        make(map[string]map[string]bool),
+		invalidated: make(map[string]time.Time),
+	}
+}
+
+func (c *MemoryCache) Get(key string, value interface{}) (bool, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	element, has := c.entries[key]
+	if !has {
+		return false, nil
+	}
+
+	entry := element.Value.(*memoryCacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.remove(element)
+		return false, nil
+	}
+
+	c.lru.MoveToFront(element)
+	return true, json.Unmarshal(entry.value, value)
+}
+
+func (c *MemoryCache) Set(key string, value interface{}, tags []string, ttl time.Duration) error {
+	if c.maxEntries <= 0 || ttl <= 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.set(key, data, tags, ttl)
+	return nil
+}
+
+func (c *MemoryCache) SetUnlessInvalidated(key string, value interface{}, tags []string, ttl time.Duration, since time.Time) (bool, error) {
+	if c.maxEntries <= 0 || ttl <= 0 || time.Since(since) > INVALIDATION_WINDOW {
+		return false, nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return false, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, tag := range tags {
+		if invalidatedAt, has := c.invalidated[tag]; has && !invalidatedAt.Before(since) {
+			return false, nil
+		}
+	}
+
+	c.set(key, data, tags, ttl)
+	return true, nil
+}
+
+func (c *MemoryCache) set(key string, data []byte, tags []string, ttl time.Duration) {
+	if element, has := c.entries[key]; has {
+		c.remove(element)
+	}
+
+	entry := &memoryCacheEntry{key: key, value: data, tags: tags, expiresAt: time.Now().Add(ttl)}
+	for _, tag := range tags {
+		if _, has := c.tags[tag]; !has {
+			c.tags[tag] = make(map[string]bool)
+		}
+		c.tags[tag][key] = true
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+
+	for c.lru.Len() > c.maxEntries {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *MemoryCache) remove(element *list.Element) {
+	entry := c.lru.Remove(element).(*memoryCacheEntry)
+	delete(c.entries, entry.key)
+
+	for _, tag := range entry.tags {
+		delete(c.tags[tag], entry.key)
+		if len(c.tags[tag]) == 0 {
+			delete(c.tags, tag)
+		}
+	}
+}
+
+func (c *MemoryCache) Invalidate(tags ...string) (int, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+	for tag, invalidatedAt := range c.invalidated {
+		if now.Sub(invalidatedAt) > INVALIDATION_WINDOW {
+			delete(c.invalidated, tag)
+		}
+	}
+
+	removed := 0
+	for _, tag := range tags {
+		c.invalidated[tag] = now
+		for key := range c.tags[tag] {
+			if element, has := c.entries[key]; has {
+				c.remove(element)
+				removed++
+			}
+		}
+	}
+	return removed, nil
+}
+
+func (c *MemoryCache) Close() error {
+	return nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := NewMemoryCache(2)
+	cache.Set("a", "A", nil, time.Minute)
+	cache.Set("b", "B", nil, time.Minute)
+
+	// a is used after b, so b is the least recently used
+	var value string
+	if has, _ := cache.Get("a", &value); !has || value != "A" {
+		t.Fatal("expected a to be cached")
+	}
+	cache.Set("c", "C", nil, time.Minute)
+
+	for key, expected := range map[string]bool{"a": true, "b": false, "c": true} {
+		if has, _ := cache.Get(key, &value); has != expected {
+			t.Errorf("expected %v to be cached %v, got %v", key, expected, has)
+		}
+	}
+
+	// setting a cached key again does not evict another
+	cache.Set("c", "C2", nil, time.Minute)
+	if has, _ := cache.Get("a", &value); !has {
+		t.Error("expected a to be cached")
+	}
+	if has, _ := cache.Get("c", &value); !has || value != "C2" {
+		t.Errorf("expected c to be C2, got %v", value)
+	}
+}
+
+func TestMemoryCacheExpires(t *testing.T) {
+	cache := NewMemoryCache(10)
+	cache.Set("short", "S", nil, 20*time.Millisecond)
+	cache.Set("long", "L", nil, time.Minute)
+	cache.Set("none", "N", nil, 0)
+
+	var value string
+	if has, _ := cache.Get("short", &value); !has {
+		t.Fatal("expected short to be cached")
+	}
+	if has, _ := cache.Get("none", &value); has {
+		t.Fatal("expected a value without ttl not to be cached")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if has, _ := cache.Get("short", &value); has {
+		t.Error("expected short to be expired")
+	}
+	if has, _ := cache.Get("long", &value); !has {
+		t.Error("expected long to be cached")
+	}
+
+	disabled := NewMemoryCache(0)
+	disabled.Set("long", "L", nil, time.Minute)
+	if has, _ := disabled.Get("long", &value); has {
+		t.Error("expected a cache of no entries not to cache")
+	}
+}
+
+func TestMemoryCacheInvalidate(t *testing.T) {
+	cache := NewMemoryCache(10)
+	cache.Set("author:2", AuthorPosts{Author: Author{ID: 2}}, []string{authorTag(2), postTag(101), postTag(102)}, time.Minute)
+	cache.Set("post:101", Post{ID: 101}, []string{postTag(101)}, time.Minute)
+	cache.Set("post:104", Post{ID: 104}, []string{postTag(104)}, time.Minute)
+
+	if removed, _ := cache.Invalidate(postTag(101)); removed != 2 {
+		t.Fatalf("expected 2 removed values, got %v", removed)
+	}
+
+	var post Post
+	for _, key := range []string{"author:2", "post:101"} {
+		if has, _ := cache.Get(key, &post); has {
+			t.Errorf("expected %v to be invalidated", key)
+		}
+	}
+	if has, _ := cache.Get("post:104", &post); !has || post.ID != 104 {
+		t.Error("expected post:104 to be cached")
+	}
+
+	// tags of dropped values are dropped with them
+	if removed, _ := cache.Invalidate(authorTag(2), postTag(102)); removed != 0 {
+		t.Errorf("expected nothing to remove, got %v", removed)
+	}
+	if len(cache.tags) != 1 {
+		t.Errorf("expected only the tag of post:104, got %v", cache.tags)
+	}
+}
@@ -0,0 +1,526 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// In-memory implementation of the repositories.
+// It is used to run the API without MySQL(db.driver = "memory") and the data is loaded from a JSON file(db.conn).
+
+type MemoryPost struct {
+	ID       int64     `json:"id"`
+	AuthorID int64     `json:"authorId"`
+	Title    string    `json:"title"`
+	Content  string    `json:"content"`
+	Excerpt  string    `json:"excerpt"`
+	Name     string    `json:"name"`
+	Guid     string    `json:"guid"`
+	Date     time.Time `json:"date"`
+	Status   string    `json:"status"`
+	Type     string    `json:"type"`
+	TermIDs  []int     `json:"termIds"`
+}
+
+type MemoryUser struct {
+	ID          int64  `json:"id"`
+	UserLogin   string `json:"userLogin"`
+	DisplayName string `json:"displayName"`
+	UserUrl     string `json:"userUrl"`
+	Email       string `json:"email"`
+}
+
+type MemoryPostMeta struct {
+	PostID int64  `json:"postId"`
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+}
+
+type MemoryData struct {
+	Posts           []MemoryPost       `json:"posts"`
+	Users           []MemoryUser       `json:"users"`
+	Terms           []Term             `json:"terms"`
+	PostMetas       []MemoryPostMeta   `json:"postMetas"`
+	SitePreferences []SitePreference   `json:"sitePreferences"`
+	ExternalMetas   []PostExternalMeta `json:"externalMetas"`
+	FacebookLikes   []FacebookLike     `json:"facebookLikes"`
+}
+
+type MemoryStorage struct {
+	mutex sync.RWMutex
+	data  MemoryData
+}
+
+// NewMemoryStorage loads data from the JSON file. Empty path makes an empty storage.
+func NewMemoryStorage(path string) (*MemoryStorage, error) {
+	storage := &MemoryStorage{}
+	if len(path) == 0 {
+		return storage, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &storage.data); err != nil {
+		return nil, err
+	}
+
+	return storage, nil
+}
+
+func (s *MemoryStorage) Open(config *Config, readOnly bool) (*Store, func()) {
+	return &Store{
+		Posts:           &memoryPostRepository{s},
+		Terms:           &memoryTermRepository{s},
+		Authors:         &memoryAuthorRepository{s},
+		SitePreferences: &memorySitePreferenceRepository{s},
+		ExternalMetas:   &memoryExternalMetaRepository{s},
+	}, func() {}
+}
+
+func (s *MemoryStorage) Close() error {
+	return nil
+}
+
+func (p MemoryPost) toPost() Post {
+	return Post{
+		ID:          p.ID,
+		AuthorID:    p.AuthorID,
+		Content:     p.Content,
+		Title:       p.Title,
+		PostDate:    p.Date,
+		PostName:    p.Name,
+		PostExcerpt: p.Excerpt,
+		Guid:        p.Guid,
+	}
+}
+
+func (p MemoryPost) isPublished() bool {
+	return p.Status == "publish" && p.Type == "post"
+}
+
+func (p MemoryPost) hasTerm(termId int) bool {
+	for _, id := range p.TermIDs {
+		if id == termId {
+			return true
+		}
+	}
+	return false
+}
+
+func (u MemoryUser) toAuthor() Author {
+	return Author{
+		ID:          u.ID,
+		UserLogin:   u.UserLogin,
+		DisplayName: u.DisplayName,
+		UserUrl:     u.UserUrl,
+		Email:       u.Email,
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, each := range values {
+		if each == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt64(values []int64, value int64) bool {
+	for _, each := range values {
+		if each == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt(values []int, value int) bool {
+	for _, each := range values {
+		if each == value {
+			return true
+		}
+	}
+	return false
+}
+
+type memoryPostRepository struct {
+	storage *MemoryStorage
+}
+
+func (r *memoryPostRepository) FindOne(ctx context.Context, id int64, statuses ...string) (*Post, error) {
+	r.storage.mutex.RLock()
+	defer r.storage.mutex.RUnlock()
+
+	for _, each := range r.storage.data.Posts {
+		if each.ID == id && each.Type == "post" && containsString(statuses, each.Status) {
+			post := each.toPost()
+			return &post, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryPostRepository) FindByName(ctx context.Context, name string) (*Post, error) {
+	r.storage.mutex.RLock()
+	defer r.storage.mutex.RUnlock()
+
+	for _, each := range r.sortedByDate(r.storage.data.Posts) {
+		if each.Name == name && each.isPublished() {
+			post := each.toPost()
+			return &post, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryPostRepository) FindByIds(ctx context.Context, ids []int64, postType string, postStatus string) ([]Post, error) {
+	r.storage.mutex.RLock()
+	defer r.storage.mutex.RUnlock()
+
+	idSet := make(map[int64]bool)
+	for _, id := range ids {
+		idSet[id] = true
+	}
+
+	posts := make([]Post, 0)
+	for _, each := range r.storage.data.Posts {
+		if idSet[each.ID] && each.Type == postType && each.Status == postStatus {
+			posts = append(posts, each.toPost())
+		}
+	}
+	return posts, nil
+}
+
+func (r *memoryPostRepository) FindRecent(ctx context.Context, postRange PostRange) ([]Post, error) {
+	return r.find(func(p MemoryPost) bool { return true }, ORDER_BY_DATE_DESC, postRange), nil
+}
+
+func (r *memoryPostRepository) CountPublished(ctx context.Context) (int64, error) {
+	r.storage.mutex.RLock()
+	defer r.storage.mutex.RUnlock()
+
+	count := int64(0)
+	for _, each := range r.storage.data.Posts {
+		if each.isPublished() {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *memoryPostRepository) FindByTerm(ctx context.Context, termId int, excludes []int, order PostOrder, postRange PostRange) ([]Post, error) {
+	return r.find(func(p MemoryPost) bool {
+		return p.hasTerm(termId) && !containsInt(excludes, int(p.ID))
+	}, order, postRange), nil
+}
+
+func (r *memoryPostRepository) FindByAuthor(ctx context.Context, authorId int64, excludes []int, order PostOrder, postRange PostRange) ([]Post, error) {
+	return r.find(func(p MemoryPost) bool {
+		return p.AuthorID == authorId && !containsInt(excludes, int(p.ID))
+	}, order, postRange), nil
+}
+
+func (r *memoryPostRepository) CountByTerm(ctx context.Context, termId int, excludes []int) (int64, error) {
+	return r.count(func(p MemoryPost) bool {
+		return p.hasTerm(termId) && !containsInt(excludes, int(p.ID))
+	}), nil
+}
+
+func (r *memoryPostRepository) CountByAuthor(ctx context.Context, authorId int64, excludes []int) (int64, error) {
+	return r.count(func(p MemoryPost) bool {
+		return p.AuthorID == authorId && !containsInt(excludes, int(p.ID))
+	}), nil
+}
+
+func (r *memoryPostRepository) FindIdsByTerms(ctx context.Context, taxonomy string) (map[int][]int64, error) {
+	r.storage.mutex.RLock()
+	defer r.storage.mutex.RUnlock()
+
+	idsByTerm := make(map[int][]int64)
+	for _, term := range r.storage.data.Terms {
+		if term.Taxonomy != taxonomy {
+			continue
+		}
+		for _, post := range r.sortedById(r.storage.data.Posts) {
+			if post.isPublished() && post.hasTerm(term.ID) {
+				idsByTerm[term.ID] = append(idsByTerm[term.ID], post.ID)
+			}
+		}
+	}
+	return idsByTerm, nil
+}
+
+func (r *memoryPostRepository) FindIdsByAuthors(ctx context.Context) (map[int64][]int64, error) {
+	r.storage.mutex.RLock()
+	defer r.storage.mutex.RUnlock()
+
+	idsByAuthor := make(map[int64][]int64)
+	for _, post := range r.sortedById(r.storage.data.Posts) {
+		if post.isPublished() {
+			idsByAuthor[post.AuthorID] = append(idsByAuthor[post.AuthorID], post.ID)
+		}
+	}
+	return idsByAuthor, nil
+}
+
+// find returns published posts matched by filter.
+func (r *memoryPostRepository) find(filter func(MemoryPost) bool, order PostOrder, postRange PostRange) []Post {
+	r.storage.mutex.RLock()
+	defer r.storage.mutex.RUnlock()
+
+	matched := make([]MemoryPost, 0)
+	for _, each := range r.storage.data.Posts {
+		if each.isPublished() && filter(each) {
+			matched = append(matched, each)
+		}
+	}
+
+	if order == ORDER_BY_RANDOM {
+		rand.Shuffle(len(matched), func(i, j int) {
+			matched[i], matched[j] = matched[j], matched[i]
+		})
+	} else {
+		matched = r.sortedByDate(matched)
+	}
+
+	cursor := postRange.Cursor
+	if cursor == nil || order == ORDER_BY_RANDOM {
+		posts := make([]Post, 0)
+		for i := postRange.Offset; i >= 0 && i < len(matched) && len(posts) < postRange.Limit; i++ {
+			posts = append(posts, matched[i].toPost())
+		}
+		return posts
+	}
+
+	posts := make([]Post, 0)
+	if cursor.Prev {
+		// the nearest posts before the cursor
+		for i := len(matched) - 1; i >= 0 && len(posts) < postRange.Limit; i-- {
+			if post := matched[i].toPost(); cursor.Before(post) {
+				posts = append([]Post{post}, posts...)
+			}
+		}
+	} else {
+		for i := 0; i < len(matched) && len(posts) < postRange.Limit; i++ {
+			if post := matched[i].toPost(); cursor.After(post) {
+				posts = append(posts, post)
+			}
+		}
+	}
+	return posts
+}
+
+// count returns the number of published posts matched by filter.
+func (r *memoryPostRepository) count(filter func(MemoryPost) bool) int64 {
+	r.storage.mutex.RLock()
+	defer r.storage.mutex.RUnlock()
+
+	count := int64(0)
+	for _, each := range r.storage.data.Posts {
+		if each.isPublished() && filter(each) {
+			count++
+		}
+	}
+	return count
+}
+
+func (r *memoryPostRepository) sortedById(posts []MemoryPost) []MemoryPost {
+	sorted := make([]MemoryPost, len(posts))
+	copy(sorted, posts)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].ID < sorted[j].ID
+	})
+	return sorted
+}
+
+func (r *memoryPostRepository) sortedByDate(posts []MemoryPost) []MemoryPost {
+	sorted := make([]MemoryPost, len(posts))
+	copy(sorted, posts)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if !sorted[i].Date.Equal(sorted[j].Date) {
+			return sorted[i].Date.After(sorted[j].Date)
+		}
+		return sorted[i].ID > sorted[j].ID
+	})
+	return sorted
+}
+
+func (r *memoryPostRepository) FindMetasByPosts(ctx context.Context, postIds []int64, keys ...string) (map[int64][]PostMeta, error) {
+	r.storage.mutex.RLock()
+	defer r.storage.mutex.RUnlock()
+
+	metasByPost := make(map[int64][]PostMeta)
+	for _, each := range r.storage.data.PostMetas {
+		if containsInt64(postIds, each.PostID) && containsString(keys, each.Key) {
+			metasByPost[each.PostID] = append(metasByPost[each.PostID], PostMeta{PostID: each.PostID, Key: each.Key, Value: each.Value})
+		}
+	}
+	return metasByPost, nil
+}
+
+func (r *memoryPostRepository) UpdateFacebookLike(ctx context.Context, postId int64, likes int) error {
+	r.storage.mutex.Lock()
+	defer r.storage.mutex.Unlock()
+
+	facebookLikes := r.storage.data.FacebookLikes
+	for i := range facebookLikes {
+		if facebookLikes[i].PostId == postId {
+			facebookLikes[i].Likes = likes
+			return nil
+		}
+	}
+
+	r.storage.data.FacebookLikes = append(facebookLikes, FacebookLike{
+		Id:     int64(len(facebookLikes) + 1),
+		PostId: postId,
+		Likes:  likes,
+	})
+	return nil
+}
+
+type memoryTermRepository struct {
+	storage *MemoryStorage
+}
+
+func (r *memoryTermRepository) FindByPosts(ctx context.Context, postIds []int64) (map[int64][]Term, error) {
+	r.storage.mutex.RLock()
+	defer r.storage.mutex.RUnlock()
+
+	termsByPost := make(map[int64][]Term)
+	for _, post := range r.storage.data.Posts {
+		if !containsInt64(postIds, post.ID) {
+			continue
+		}
+		for _, term := range r.storage.data.Terms {
+			if post.hasTerm(term.ID) {
+				termsByPost[post.ID] = append(termsByPost[post.ID], term)
+			}
+		}
+	}
+	return termsByPost, nil
+}
+
+func (r *memoryTermRepository) FindBySlug(ctx context.Context, slug string, taxonomy string) (*Term, error) {
+	r.storage.mutex.RLock()
+	defer r.storage.mutex.RUnlock()
+
+	for _, each := range r.storage.data.Terms {
+		if each.Slug == slug && each.Taxonomy == taxonomy {
+			term := each
+			return &term, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryTermRepository) FindByIds(ctx context.Context, ids []int, taxonomy string) ([]Term, error) {
+	r.storage.mutex.RLock()
+	defer r.storage.mutex.RUnlock()
+
+	terms := make([]Term, 0)
+	for _, each := range r.storage.data.Terms {
+		if each.Taxonomy == taxonomy && containsInt(ids, each.ID) {
+			terms = append(terms, each)
+		}
+	}
+	return terms, nil
+}
+
+type memoryAuthorRepository struct {
+	storage *MemoryStorage
+}
+
+func (r *memoryAuthorRepository) FindOne(ctx context.Context, id int64) (*Author, error) {
+	r.storage.mutex.RLock()
+	defer r.storage.mutex.RUnlock()
+
+	for _, each := range r.storage.data.Users {
+		if each.ID == id {
+			author := each.toAuthor()
+			return &author, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryAuthorRepository) FindByIds(ctx context.Context, ids []int64) ([]Author, error) {
+	r.storage.mutex.RLock()
+	defer r.storage.mutex.RUnlock()
+
+	authors := make([]Author, 0)
+	for _, each := range r.storage.data.Users {
+		if containsInt64(ids, each.ID) {
+			authors = append(authors, each.toAuthor())
+		}
+	}
+	return authors, nil
+}
+
+func (r *memoryAuthorRepository) FindByLoginName(ctx context.Context, loginName string) (*Author, error) {
+	r.storage.mutex.RLock()
+	defer r.storage.mutex.RUnlock()
+
+	for _, each := range r.storage.data.Users {
+		if each.UserLogin == loginName {
+			author := each.toAuthor()
+			return &author, nil
+		}
+	}
+	return nil, nil
+}
+
+type memorySitePreferenceRepository struct {
+	storage *MemoryStorage
+}
+
+func (r *memorySitePreferenceRepository) FindByName(ctx context.Context, name string) (*SitePreference, error) {
+	r.storage.mutex.RLock()
+	defer r.storage.mutex.RUnlock()
+
+	for _, each := range r.storage.data.SitePreferences {
+		if each.Name == name {
+			sitePref := each
+			return &sitePref, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memorySitePreferenceRepository) FindByNames(ctx context.Context, names []string) ([]SitePreference, error) {
+	r.storage.mutex.RLock()
+	defer r.storage.mutex.RUnlock()
+
+	sitePrefs := make([]SitePreference, 0)
+	for _, each := range r.storage.data.SitePreferences {
+		if containsString(names, each.Name) {
+			sitePrefs = append(sitePrefs, each)
+		}
+	}
+	return sitePrefs, nil
+}
+
+type memoryExternalMetaRepository struct {
+	storage *MemoryStorage
+}
+
+func (r *memoryExternalMetaRepository) FindByPosts(ctx context.Context, postIds []int64) (map[int64][]PostExternalMeta, error) {
+	r.storage.mutex.RLock()
+	defer r.storage.mutex.RUnlock()
+
+	metasByPost := make(map[int64][]PostExternalMeta)
+	for _, each := range r.storage.data.ExternalMetas {
+		if containsInt64(postIds, each.PostId) {
+			metasByPost[each.PostId] = append(metasByPost[each.PostId], each)
+		}
+	}
+	return metasByPost, nil
+}
@@ -0,0 +1,588 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+// OpenAPI 3 document of the routes in registerRoutes.
+// Schemas of responses are made from the JSON fields of the Go types, so they follow the code.
+
+const OPENAPI_VERSION = "3.0.3"
+
+// apiParam is a query, path or header parameter of an operation.
+type apiParam struct {
+	Name        string
+	In          string
+	Type        string
+	Description string
+	Required    bool
+	Default     interface{}
+	// Minimum and Maximum bound integers, MaxLength strings.
+	Minimum   interface{}
+	Maximum   interface{}
+	MaxLength interface{}
+	Enum      []string
+}
+
+// apiOperation describes a route.
+type apiOperation struct {
+	Method      string
+	Path        string
+	Summary     string
+	Description string
+	Params      []apiParam
+	// Body is a value of the request body type.
+	Body interface{}
+	// Data are values of the ApiResult.Data types. More than one are described as oneOf.
+	Data []interface{}
+	// ContentType is set if the response is not ApiResult.
+	ContentType string
+	Errors      []int
+	// Headers are response headers with descriptions.
+	Headers map[string]string
+}
+
+func queryParam(name string, typ string, description string, defaultValue interface{}) apiParam {
+	return apiParam{Name: name, In: "query", Type: typ, Description: description, Default: defaultValue}
+}
+
+func requiredParam(in string, name string, typ string, description string) apiParam {
+	return apiParam{Name: name, In: in, Type: typ, Description: description, Required: true}
+}
+
+// ruleParam describes a parameter parsed by the rule(params.go).
+func ruleParam(rule ParamRule, description string) apiParam {
+	param := apiParam{Name: rule.Name, In: "query", Description: description, Required: rule.Required, Default: rule.Default}
+	if rule.Path {
+		param.In = "path"
+	}
+
+	switch rule.Type {
+	case INT_PARAM:
+		param.Type = "integer"
+		param.Minimum = rule.Min
+		if rule.Max != 0 {
+			param.Maximum = rule.Max
+		}
+	case INT_LIST_PARAM:
+		param.Type = "string"
+		param.Description += fmt.Sprintf(" At most %v ids.", rule.MaxItems)
+	case BOOL_PARAM:
+		param.Type = "boolean"
+	default:
+		param.Type = "string"
+		if rule.Max != 0 {
+			param.MaxLength = rule.Max
+		}
+		param.Enum = rule.Values
+	}
+	return param
+}
+
+// pageParams are parameters of post lists.
+func pageParams(defaultSize int) []apiParam {
+	return []apiParam{
+		ruleParam(pageRule, "Page number. Ignored if cursor is given."),
+		ruleParam(sizeRule(defaultSize), "Number of posts in a page."),
+		ruleParam(cursorRule, "'*' for the first page, or nextCursor/prevCursor of the previous response. The response is always PostList."),
+		ruleParam(includeTotalRule, "Responds PostList with total, page, size, hasNext and links."),
+	}
+}
+
+var (
+	fieldsParam   = queryParam("fields", "string", "Comma separated JSON fields of posts(id,title,author.displayName, ...). All fields if empty.", nil)
+	formatParam   = ruleParam(formatRule, "Format of content. raw is post_content as before, with only [gallery] lines given image URLs. html is rendered with paragraphs and all shortcodes as HTML, and its h2 to h4 headings have id anchors of toc. text is html without tags.")
+	excludesParam = ruleParam(excludesRule, "Comma separated ids of posts not to be listed.")
+	randomParams  = []apiParam{
+		ruleParam(isMobileRule, "Picks less posts for mobile."),
+		ruleParam(seedRule, "Seed of the random picks. The same seed picks the same posts."),
+	}
+	randomHeaders = map[string]string{"X-Random-Seed": "Seed used to pick the posts."}
+)
+
+func params(groups ...[]apiParam) []apiParam {
+	all := make([]apiParam, 0)
+	for _, group := range groups {
+		all = append(all, group...)
+	}
+	return all
+}
+
+var apiOperations = []apiOperation{
+	{
+		Method: http.MethodGet, Path: "/api/Search", Summary: "Search posts",
+		Params: []apiParam{
+			ruleParam(keywordRule, "Search keyword."),
+			ruleParam(pageRule, "Page number of the search result."),
+			ruleParam(includeTotalRule, "Responds PostList with totalHits of the search API as total."),
+			fieldsParam,
+		},
+		Data:   []interface{}{[]Post{}, PostList{}},
+		Errors: []int{http.StatusBadRequest, http.StatusInternalServerError, http.StatusBadGateway},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/RecentPosts", Summary: "Recent posts",
+		Params: params(pageParams(4), []apiParam{fieldsParam}),
+		Data:   []interface{}{[]Post{}, PostList{}},
+		Errors: []int{http.StatusBadRequest, http.StatusInternalServerError},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/TagPosts", Summary: "Random posts of random tags",
+		Params:  params(randomParams, []apiParam{fieldsParam}),
+		Data:    []interface{}{[]TermPosts{}},
+		Errors:  []int{http.StatusBadRequest, http.StatusInternalServerError},
+		Headers: randomHeaders,
+	},
+	{
+		Method: http.MethodGet, Path: "/api/RandomAuthorPosts", Summary: "Random posts of random authors",
+		Params:  params(randomParams, []apiParam{fieldsParam}),
+		Data:    []interface{}{[]AuthorPosts{}},
+		Errors:  []int{http.StatusBadRequest, http.StatusInternalServerError},
+		Headers: randomHeaders,
+	},
+	{
+		Method: http.MethodGet, Path: "/api/PostsByTagId", Summary: "Posts of a tag or category by term id",
+		Params: params([]apiParam{ruleParam(idRule("id"), "Term id.")}, pageParams(2), []apiParam{excludesParam, fieldsParam}),
+		Data:   []interface{}{[]Post{}, PostList{}},
+		Errors: []int{http.StatusBadRequest, http.StatusInternalServerError},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/PostsByTag", Summary: "Posts of a tag",
+		Params: params([]apiParam{ruleParam(slugRule("tag"), "Tag slug.")}, pageParams(2), []apiParam{excludesParam, fieldsParam}),
+		Data:   []interface{}{[]Post{}, PostList{}},
+		Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/PostsByCategory", Summary: "Posts of a category",
+		Params: params([]apiParam{ruleParam(slugRule("category"), "Category slug.")}, pageParams(2), []apiParam{excludesParam, fieldsParam}),
+		Data:   []interface{}{[]Post{}, PostList{}},
+		Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/PostsByAuthor", Summary: "Posts of an author by login name",
+		Params: params([]apiParam{ruleParam(slugRule("author"), "Login name of the author.")}, pageParams(2), []apiParam{excludesParam, fieldsParam}),
+		Data:   []interface{}{AuthorPosts{}, AuthorPostList{}},
+		Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/PostsByAuthorId", Summary: "Posts of an author by id",
+		Params: params([]apiParam{ruleParam(idRule("id"), "Author id.")}, pageParams(2), []apiParam{excludesParam, fieldsParam}),
+		Data:   []interface{}{AuthorPosts{}, AuthorPostList{}},
+		Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/PostByPermalink", Summary: "A published post by post_name",
+		Params: []apiParam{ruleParam(slugRule("permalink"), "post_name of the post."), formatParam, fieldsParam},
+		Data:   []interface{}{Post{}},
+		Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/PostById", Summary: "A published, future or draft post by id",
+		Params: []apiParam{ruleParam(idRule("id"), "Post id."), formatParam, fieldsParam},
+		Data:   []interface{}{Post{}},
+		Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/GetGoogleAd", Summary: "Ads(ad.{mode}.top, middle and bottom preferences)",
+		Params: []apiParam{ruleParam(modeRule, "pc or mobile.")},
+		Data:   []interface{}{map[string]SitePreference{}},
+		Errors: []int{http.StatusInternalServerError},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/GetSlideShareEmbedLink", Summary: "oEmbed of a SlideShare link",
+		Params: []apiParam{ruleParam(linkRule, "SlideShare URL.")},
+		Data:   []interface{}{map[string]interface{}{}},
+		Errors: []int{http.StatusBadRequest, http.StatusInternalServerError, http.StatusBadGateway},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/GetSitePreference", Summary: "A site preference",
+		Description: "data is an empty string if there is no such preference.",
+		Params:      []apiParam{ruleParam(slugRule("name"), "Preference name.")},
+		Data:        []interface{}{SitePreference{}, ""},
+		Errors:      []int{http.StatusBadRequest, http.StatusInternalServerError},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/v2/posts", Summary: "Published posts by ids",
+		Description: "Posts are in the order of ids without content. Ids of posts not found or not published are in missingIds.",
+		Params:      []apiParam{ruleParam(idsRule, "Comma separated ids of posts."), fieldsParam},
+		Data:        []interface{}{PostBatch{}},
+		Errors:      []int{http.StatusBadRequest, http.StatusInternalServerError},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/v2/posts/:id", Summary: "A published, future or draft post by id",
+		Params: []apiParam{ruleParam(pathRule(idRule("id")), "Post id."), formatParam, fieldsParam},
+		Data:   []interface{}{Post{}},
+		Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/v2/posts/:id/markdown", Summary: "A published, future or draft post as Markdown",
+		Description: "GitHub flavored Markdown of the html format content with YAML front matter of title, date, author, tags, categories, image and description(socialDesc).",
+		Params:      []apiParam{ruleParam(pathRule(idRule("id")), "Post id.")},
+		ContentType: MIME_MARKDOWN,
+		Errors:      []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/v2/posts/slug/:slug", Summary: "A published post by post_name",
+		Params: []apiParam{ruleParam(pathRule(slugRule("slug")), "post_name of the post."), formatParam, fieldsParam},
+		Data:   []interface{}{Post{}},
+		Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/v2/authors/:login", Summary: "An author by login name",
+		Params: []apiParam{ruleParam(pathRule(slugRule("login")), "Login name of the author.")},
+		Data:   []interface{}{Author{}},
+		Errors: []int{http.StatusNotFound, http.StatusInternalServerError},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/v2/authors/:login/posts", Summary: "Posts of an author",
+		Params: params([]apiParam{ruleParam(pathRule(slugRule("login")), "Login name of the author.")}, pageParams(2), []apiParam{excludesParam, fieldsParam}),
+		Data:   []interface{}{AuthorPostList{}},
+		Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/v2/tags/:slug/posts", Summary: "Posts of a tag",
+		Params: params([]apiParam{ruleParam(pathRule(slugRule("slug")), "Tag slug.")}, pageParams(2), []apiParam{excludesParam, fieldsParam}),
+		Data:   []interface{}{PostList{}},
+		Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/v2/categories/:slug/posts", Summary: "Posts of a category",
+		Params: params([]apiParam{ruleParam(pathRule(slugRule("slug")), "Category slug.")}, pageParams(2), []apiParam{excludesParam, fieldsParam}),
+		Data:   []interface{}{PostList{}},
+		Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/v2/preferences/:name", Summary: "A site preference",
+		Params: []apiParam{ruleParam(pathRule(slugRule("name")), "Preference name.")},
+		Data:   []interface{}{SitePreference{}},
+		Errors: []int{http.StatusNotFound, http.StatusInternalServerError},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/hooks/wordpress", Summary: "WordPress change events",
+		Description: "Drops cached responses of the changed post, term or user and queues the post for search reindexing.",
+		Params: []apiParam{
+			requiredParam("header", HOOK_TIMESTAMP_HEADER, "integer", "Unix seconds. Rejected if it differs more than 5 minutes."),
+			requiredParam("header", HOOK_SIGNATURE_HEADER, "string", "sha256=hex(HMAC-SHA256(webhookSecret, timestamp + \".\" + body))"),
+		},
+		Body:   WordPressEvent{},
+		Data:   []interface{}{WordPressEventResult{}},
+		Errors: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusInternalServerError},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/openapi.json", Summary: "This document",
+		ContentType: echo.MIMEApplicationJSON,
+	},
+	{
+		Method: http.MethodGet, Path: "/api/docs", Summary: "Browsable docs of this document",
+		ContentType: echo.MIMETextHTML,
+	},
+	{
+		Method: http.MethodGet, Path: "/graphql", Summary: "GraphQL query",
+		Description: graphQLDescription,
+		Params: []apiParam{
+			requiredParam("query", "query", "string", "GraphQL query."),
+			queryParam("variables", "string", "JSON object of the variables.", nil),
+			queryParam("operationName", "string", "Operation to execute if the query has more than one.", nil),
+		},
+		ContentType: echo.MIMEApplicationJSON,
+	},
+	{
+		Method: http.MethodPost, Path: "/graphql", Summary: "GraphQL query",
+		Description: graphQLDescription,
+		Body:        GraphQLRequest{},
+		ContentType: echo.MIMEApplicationJSON,
+	},
+}
+
+var graphQLDescription = fmt.Sprintf("Responds a GraphQL result({data, errors}) instead of ApiResult, and 400 with errors if the query is wrong. "+
+	"Error codes are in errors[].extensions.code. Queries deeper than %v or more complex than %v are QUERY_TOO_COMPLEX. "+
+	"The schema can be queried by introspection.", MAX_QUERY_DEPTH, MAX_QUERY_COMPLEXITY)
+
+var echoParamPattern = regexp.MustCompile(`:([A-Za-z0-9_]+)`)
+
+// openAPIPath converts an echo path(/posts/:id) to an OpenAPI path(/posts/{id}).
+func openAPIPath(path string) string {
+	return echoParamPattern.ReplaceAllString(path, "{$1}")
+}
+
+// NewOpenAPIDocument describes apiOperations.
+func NewOpenAPIDocument() map[string]interface{} {
+	builder := &schemaBuilder{schemas: make(map[string]interface{})}
+
+	paths := make(map[string]interface{})
+	for _, operation := range apiOperations {
+		path := openAPIPath(operation.Path)
+		item, has := paths[path].(map[string]interface{})
+		if !has {
+			item = make(map[string]interface{})
+			paths[path] = item
+		}
+		item[strings.ToLower(operation.Method)] = builder.operation(operation)
+	}
+
+	builder.schemas["ErrorResult"] = builder.errorResult()
+
+	return map[string]interface{}{
+		"openapi": OPENAPI_VERSION,
+		"info": map[string]interface{}{
+			"title":       "Popit API",
+			"version":     "2",
+			"description": "WordPress post API of https://www.popit.kr. GET responses are cached and X-Cache header tells HIT or MISS. Failures have a code in ErrorResult.",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": builder.schemas,
+		},
+	}
+}
+
+// schemaBuilder makes schemas of Go types. Named structs are added to components.
+type schemaBuilder struct {
+	schemas map[string]interface{}
+}
+
+func (b *schemaBuilder) operation(operation apiOperation) map[string]interface{} {
+	parameters := []interface{}{
+		map[string]interface{}{
+			"name":        "site",
+			"in":          "query",
+			"description": "Site name. The site is found by Host header if empty.",
+			"schema":      map[string]interface{}{"type": "string"},
+		},
+	}
+	for _, param := range operation.Params {
+		schema := map[string]interface{}{"type": param.Type}
+		if param.Default != nil {
+			schema["default"] = param.Default
+		}
+		if param.Minimum != nil {
+			schema["minimum"] = param.Minimum
+		}
+		if param.Maximum != nil {
+			schema["maximum"] = param.Maximum
+		}
+		if param.MaxLength != nil {
+			schema["maxLength"] = param.MaxLength
+		}
+		if len(param.Enum) > 0 {
+			schema["enum"] = param.Enum
+		}
+		parameters = append(parameters, map[string]interface{}{
+			"name":        param.Name,
+			"in":          param.In,
+			"description": param.Description,
+			"required":    param.Required,
+			"schema":      schema,
+		})
+	}
+
+	responses := map[string]interface{}{
+		"200": b.successResponse(operation),
+	}
+	for _, status := range operation.Errors {
+		responses[strconv.Itoa(status)] = map[string]interface{}{
+			"description": http.StatusText(status),
+			"content": map[string]interface{}{
+				echo.MIMEApplicationJSON: map[string]interface{}{
+					"schema": map[string]interface{}{"$ref": "#/components/schemas/ErrorResult"},
+				},
+			},
+		}
+	}
+
+	result := map[string]interface{}{
+		"summary":    operation.Summary,
+		"parameters": parameters,
+		"responses":  responses,
+	}
+	if len(operation.Description) > 0 {
+		result["description"] = operation.Description
+	}
+	if operation.Body != nil {
+		result["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{
+				echo.MIMEApplicationJSON: map[string]interface{}{
+					"schema": b.schema(reflect.TypeOf(operation.Body)),
+				},
+			},
+		}
+	}
+	return result
+}
+
+func (b *schemaBuilder) successResponse(operation apiOperation) map[string]interface{} {
+	var schema map[string]interface{}
+	switch operation.ContentType {
+	case "":
+		schemas := make([]interface{}, 0)
+		for _, data := range operation.Data {
+			schemas = append(schemas, b.schema(reflect.TypeOf(data)))
+		}
+		if len(schemas) == 1 {
+			schema = b.apiResult(schemas[0])
+		} else {
+			schema = b.apiResult(map[string]interface{}{"oneOf": schemas})
+		}
+	case echo.MIMETextHTML, MIME_MARKDOWN:
+		schema = map[string]interface{}{"type": "string"}
+	default:
+		schema = map[string]interface{}{"type": "object"}
+	}
+
+	contentType := operation.ContentType
+	if len(contentType) == 0 {
+		contentType = echo.MIMEApplicationJSON
+	}
+
+	response := map[string]interface{}{
+		"description": "OK",
+		"content": map[string]interface{}{
+			contentType: map[string]interface{}{"schema": schema},
+		},
+	}
+	if len(operation.Headers) > 0 {
+		headers := make(map[string]interface{})
+		for name, description := range operation.Headers {
+			headers[name] = map[string]interface{}{
+				"description": description,
+				"schema":      map[string]interface{}{"type": "string"},
+			}
+		}
+		response["headers"] = headers
+	}
+	return response
+}
+
+// apiResult wraps the schema of data in ApiResult.
+func (b *schemaBuilder) apiResult(data interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"data":    data,
+			"success": map[string]interface{}{"type": "boolean"},
+			"message": map[string]interface{}{"type": "string"},
+		},
+	}
+}
+
+// errorResult is ApiResult of failures with the code.
+func (b *schemaBuilder) errorResult() map[string]interface{} {
+	codes := make([]string, 0)
+	for code := range errorStatuses {
+		codes = append(codes, string(code))
+	}
+	sort.Strings(codes)
+
+	result := b.apiResult(map[string]interface{}{"nullable": true})
+	result["properties"].(map[string]interface{})["code"] = map[string]interface{}{
+		"type": "string",
+		"enum": codes,
+	}
+	return result
+}
+
+func (b *schemaBuilder) schema(typ reflect.Type) map[string]interface{} {
+	switch typ.Kind() {
+	case reflect.Ptr:
+		return b.schema(typ.Elem())
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if typ.Elem().Kind() == reflect.Uint8 {
+			// json.RawMessage
+			return map[string]interface{}{}
+		}
+		return map[string]interface{}{"type": "array", "items": b.schema(typ.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": b.schema(typ.Elem())}
+	case reflect.Struct:
+		if typ == reflect.TypeOf(time.Time{}) {
+			return map[string]interface{}{"type": "string", "format": "date-time"}
+		}
+		if len(typ.Name()) == 0 {
+			return b.object(typ)
+		}
+		if _, has := b.schemas[typ.Name()]; !has {
+			// added before its fields for recursive types
+			b.schemas[typ.Name()] = nil
+			b.schemas[typ.Name()] = b.object(typ)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + typ.Name()}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+func (b *schemaBuilder) object(typ reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+	b.addProperties(typ, properties)
+	return map[string]interface{}{"type": "object", "properties": properties}
+}
+
+// addProperties adds JSON fields of the struct. Fields of embedded structs are added as its own.
+func (b *schemaBuilder) addProperties(typ reflect.Type, properties map[string]interface{}) {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+
+		if field.Anonymous && len(name) == 0 {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			b.addProperties(embedded, properties)
+			continue
+		}
+		if len(field.PkgPath) > 0 || name == "-" {
+			continue
+		}
+		if len(name) == 0 {
+			name = field.Name
+		}
+		properties[name] = b.schema(field.Type)
+	}
+}
+
+// GetOpenAPIDocument responds the document made at start.
+func GetOpenAPIDocument(document map[string]interface{}) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		return c.JSON(http.StatusOK, document)
+	}
+}
+
+const API_DOCS_HTML = `<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="utf-8">
+	<title>Popit API</title>
+	<meta name="viewport" content="width=device-width, initial-scale=1">
+</head>
+<body>
+	<redoc spec-url="openapi.json"></redoc>
+	<script src="https://cdn.redoc.ly/redoc/latest/bundles/redoc.standalone.js"></script>
+</body>
+</html>
+`
+
+// GetAPIDocs responds a page rendering /api/openapi.json.
+func GetAPIDocs(c echo.Context) error {
+	return c.HTML(http.StatusOK, API_DOCS_HTML)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo"
+)
+
+func TestOpenAPIDescribesAllRoutes(t *testing.T) {
+	e := echo.New()
+	registerRoutes(e, nil, nil)
+
+	paths := NewOpenAPIDocument()["paths"].(map[string]interface{})
+
+	registered := make(map[string]bool)
+	for _, route := range e.Routes() {
+		path := openAPIPath(route.Path)
+		method := strings.ToLower(route.Method)
+		registered[method+" "+path] = true
+
+		item, has := paths[path].(map[string]interface{})
+		if !has {
+			t.Errorf("%v %v is not in the OpenAPI document", route.Method, route.Path)
+			continue
+		}
+		if _, has := item[method]; !has {
+			t.Errorf("%v %v is not in the OpenAPI document", route.Method, route.Path)
+		}
+	}
+
+	for path, item := range paths {
+		for method := range item.(map[string]interface{}) {
+			if !registered[method+" "+path] {
+				t.Errorf("%v %v is in the OpenAPI document but not registered", method, path)
+			}
+		}
+	}
+}
+
+func TestOpenAPISchemas(t *testing.T) {
+	document := NewOpenAPIDocument()
+	if _, err := json.Marshal(document); err != nil {
+		t.Fatal(err)
+	}
+
+	schemas := document["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	for _, name := range []string{"Post", "Author", "Term", "TermPosts", "AuthorPosts", "PostList", "AuthorPostList", "ErrorResult"} {
+		if schemas[name] == nil {
+			t.Errorf("schema %v is missing", name)
+		}
+	}
+
+	properties := schemas["Author"].(map[string]interface{})["properties"].(map[string]interface{})
+	if _, has := properties["email"]; has {
+		t.Error("email of authors must not be described")
+	}
+	if _, has := properties["displayName"]; !has {
+		t.Error("displayName of authors is missing")
+	}
+
+	properties = schemas["AuthorPostList"].(map[string]interface{})["properties"].(map[string]interface{})
+	for _, name := range []string{"author", "posts", "total", "hasNext", "next"} {
+		if _, has := properties[name]; !has {
+			t.Errorf("%v of AuthorPostList is missing", name)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+// Lists of posts are ordered by post_date desc and ID desc, so (post_date, ID) is a position in a list.
+// A cursor is the position of the first or the last post of a page and is opaque to clients.
+
+const (
+	CURSOR_DATE_FORMAT = "2006-01-02 15:04:05"
+	// FIRST_CURSOR asks the first page of a list with cursors.
+	FIRST_CURSOR = "*"
+)
+
+var ErrWrongCursor = errors.New("Wrong cursor")
+
+type PostCursor struct {
+	Date time.Time
+	ID   int64
+	// Prev selects posts before(newer than) the position instead of posts after it.
+	Prev bool
+}
+
+// PostRange selects posts of a list.
+// Posts next to Cursor are selected if Cursor is not nil, otherwise posts from Offset.
+type PostRange struct {
+	Offset int
+	Limit  int
+	Cursor *PostCursor
+}
+
+func newPostCursor(post Post, prev bool) *PostCursor {
+	return &PostCursor{Date: post.PostDate, ID: post.ID, Prev: prev}
+}
+
+// Encode returns the cursor as "next|<post_date>|<ID>" or "prev|<post_date>|<ID>" in URL safe base64.
+func (c PostCursor) Encode() string {
+	direction := "next"
+	if c.Prev {
+		direction = "prev"
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%v|%v|%v", direction, c.Date.Format(CURSOR_DATE_FORMAT), c.ID)))
+}
+
+// DecodePostCursor returns nil for FIRST_CURSOR.
+func DecodePostCursor(encoded string) (*PostCursor, error) {
+	if encoded == FIRST_CURSOR {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrWrongCursor
+	}
+
+	tokens := strings.Split(string(data), "|")
+	if len(tokens) != 3 || (tokens[0] != "next" && tokens[0] != "prev") {
+		return nil, ErrWrongCursor
+	}
+
+	date, err := time.ParseInLocation(CURSOR_DATE_FORMAT, tokens[1], time.Local)
+	if err != nil {
+		return nil, ErrWrongCursor
+	}
+
+	var id int64
+	if _, err := fmt.Sscan(tokens[2], &id); err != nil || id <= 0 {
+		return nil, ErrWrongCursor
+	}
+
+	return &PostCursor{Date: date, ID: id, Prev: tokens[0] == "prev"}, nil
+}
+
+// After reports whether the post comes after the position in a list.
+func (c PostCursor) After(post Post) bool {
+	date := post.PostDate.Format(CURSOR_DATE_FORMAT)
+	cursorDate := c.Date.Format(CURSOR_DATE_FORMAT)
+	return date < cursorDate || (date == cursorDate && post.ID < c.ID)
+}
+
+// Before reports whether the post comes before the position in a list.
+func (c PostCursor) Before(post Post) bool {
+	date := post.PostDate.Format(CURSOR_DATE_FORMAT)
+	cursorDate := c.Date.Format(CURSOR_DATE_FORMAT)
+	return date > cursorDate || (date == cursorDate && post.ID > c.ID)
+}
+
+// PostPage is a page of a list with cursors of the next and the previous pages.
+// A cursor is empty if there is no page in the direction.
+type PostPage struct {
+	Posts      []Post `json:"posts"`
+	NextCursor string `json:"nextCursor"`
+	PrevCursor string `json:"prevCursor"`
+}
+
+// pageRange returns the range finding one more post than postRange to know whether there is one more page.
+func pageRange(postRange PostRange) PostRange {
+	postRange.Limit++
+	return postRange
+}
+
+// newPostPage makes a page of posts found by pageRange(postRange).
+func newPostPage(posts []Post, postRange PostRange) *PostPage {
+	cursor := postRange.Cursor
+	backward := cursor != nil && cursor.Prev
+
+	hasMore := len(posts) > postRange.Limit
+	if hasMore && backward {
+		posts = posts[len(posts)-postRange.Limit:]
+	} else if hasMore {
+		posts = posts[:postRange.Limit]
+	}
+
+	page := &PostPage{Posts: posts}
+	if len(posts) == 0 {
+		// back to where the client came from
+		if backward {
+			page.NextCursor = PostCursor{Date: cursor.Date, ID: cursor.ID}.Encode()
+		} else if cursor != nil {
+			page.PrevCursor = PostCursor{Date: cursor.Date, ID: cursor.ID, Prev: true}.Encode()
+		}
+		return page
+	}
+
+	hasNext, hasPrev := hasMore, cursor != nil || postRange.Offset > 0
+	if backward {
+		hasNext, hasPrev = true, hasMore
+	}
+
+	if hasNext {
+		page.NextCursor = newPostCursor(posts[len(posts)-1], false).Encode()
+	}
+	if hasPrev {
+		page.PrevCursor = newPostCursor(posts[0], true).Encode()
+	}
+	return page
+}
+
+// PostList is the paginated response of a post list. It is returned when a client asks
+// includeTotal=true or paginates by cursor; bare arrays are kept for old clients otherwise.
+// Next and Prev are URLs of the next and the previous pages, empty if there is no such page.
+type PostList struct {
+	Posts []Post `json:"posts"`
+	Total int64  `json:"total"`
+	// Page is omitted when the list is paginated by cursor.
+	Page       int    `json:"page,omitempty"`
+	Size       int    `json:"size"`
+	HasNext    bool   `json:"hasNext"`
+	Next       string `json:"next"`
+	Prev       string `json:"prev"`
+	NextCursor string `json:"nextCursor,omitempty"`
+	PrevCursor string `json:"prevCursor,omitempty"`
+}
+
+// AuthorPostList is PostList of an author.
+type AuthorPostList struct {
+	Author Author `json:"author"`
+	*PostList
+}
+
+// wantsPostList reports whether the client asks PostList instead of a bare list.
+// includeTotal is validated by getPostRange or the handler.
+func wantsPostList(c echo.Context, withCursors bool) bool {
+	params, _ := parseParams(c, includeTotalRule)
+	return withCursors || params.Bool("includeTotal")
+}
+
+// newPostList makes the response of a page found by postRange. total is the number of all posts in the list.
+func newPostList(c echo.Context, postRange PostRange, withCursors bool, page *PostPage, total int64) *PostList {
+	list := &PostList{
+		Posts:   page.Posts,
+		Total:   total,
+		Size:    postRange.Limit,
+		HasNext: len(page.NextCursor) > 0 || (!withCursors && int64(postRange.Offset+len(page.Posts)) < total),
+	}
+
+	if withCursors {
+		list.NextCursor, list.PrevCursor = page.NextCursor, page.PrevCursor
+		if len(page.NextCursor) > 0 {
+			list.Next = pageURL(c, "cursor", page.NextCursor)
+		}
+		if len(page.PrevCursor) > 0 {
+			list.Prev = pageURL(c, "cursor", page.PrevCursor)
+		}
+		return list
+	}
+
+	list.Page = 1
+	if postRange.Limit > 0 {
+		list.Page = postRange.Offset/postRange.Limit + 1
+	}
+	if list.HasNext {
+		list.Next = pageURL(c, "page", strconv.Itoa(list.Page+1))
+	}
+	if list.Page > 1 {
+		list.Prev = pageURL(c, "page", strconv.Itoa(list.Page-1))
+	}
+	return list
+}
+
+// pageURL returns the request URL with the page(or cursor) parameter replaced.
+// The URL does not depend on how the site was resolved because responses are cached regardless of it.
+func pageURL(c echo.Context, name string, value string) string {
+	query := url.Values{}
+	for key, values := range c.QueryParams() {
+		if key == "site" || key == "page" || key == "cursor" {
+			continue
+		}
+		for _, each := range values {
+			if len(each) > 0 {
+				query.Add(key, each)
+			}
+		}
+	}
+
+	if site := GetSite(c.Request().Context()); site.Name != DEFAULT_SITE_NAME {
+		query.Set("site", site.Name)
+	}
+	query.Set(name, value)
+
+	return c.Request().URL.Path + "?" + query.Encode()
+}
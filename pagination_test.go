@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+func TestPostCursor(t *testing.T) {
+	date := time.Date(2018, 5, 1, 10, 0, 0, 0, time.Local)
+	tests := []PostCursor{
+		{Date: date, ID: 100},
+		{Date: date, ID: 100, Prev: true},
+		{Date: date.Add(-time.Hour * 24 * 365 * 10), ID: 1},
+	}
+
+	for _, cursor := range tests {
+		decoded, err := DecodePostCursor(cursor.Encode())
+		if err != nil {
+			t.Errorf("%+v: unexpected error %v", cursor, err)
+			continue
+		}
+		if !decoded.Date.Equal(cursor.Date) || decoded.ID != cursor.ID || decoded.Prev != cursor.Prev {
+			t.Errorf("expected %+v, got %+v", cursor, *decoded)
+		}
+	}
+
+	if cursor, err := DecodePostCursor(FIRST_CURSOR); cursor != nil || err != nil {
+		t.Errorf("expected no cursor for FIRST_CURSOR, got %+v %v", cursor, err)
+	}
+}
+
+func TestDecodeWrongPostCursor(t *testing.T) {
+	encode := func(value string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(value))
+	}
+	tests := []string{
+		"",
+		"not base64!",
+		// padded standard base64
+		base64.StdEncoding.EncodeToString([]byte("next|2018-05-01 10:00:00|100")),
+		encode("next|2018-05-01 10:00:00"),
+		encode("next|2018-05-01 10:00:00|100|1"),
+		encode("down|2018-05-01 10:00:00|100"),
+		encode("next|2018-05-01T10:00:00Z|100"),
+		encode("next|2018-13-01 10:00:00|100"),
+		encode("next|2018-05-01 10:00:00|abc"),
+		encode("next|2018-05-01 10:00:00|0"),
+		encode("prev|2018-05-01 10:00:00|-100"),
+	}
+
+	for _, encoded := range tests {
+		if cursor, err := DecodePostCursor(encoded); err != ErrWrongCursor {
+			t.Errorf("%v: expected ErrWrongCursor, got %+v %v", encoded, cursor, err)
+		}
+	}
+}
+
+func TestWrongCursorParam(t *testing.T) {
+	server := newTestServer(t, testReindexer{jobs: make(chan reindexJob, 10)})
+	defer server.Close()
+
+	// a forged cursor of a post that does not exist is a position in the list like any other
+	forged := PostCursor{Date: time.Date(2018, 5, 4, 0, 0, 0, 0, time.Local), ID: 999}.Encode()
+	if list := server.getPosts(t, "/api/RecentPosts?cursor="+forged); !equalIds(list.ids(), []int64{102, 101, 100}) {
+		t.Errorf("expected posts after the forged cursor, got %v", list.ids())
+	}
+
+	targets := []string{
+		"/api/RecentPosts?cursor=wrong",
+		"/api/PostsByTag?tag=go&cursor=" + base64.RawURLEncoding.EncodeToString([]byte("next|2018|100")),
+		"/api/PostsByAuthor?author=popit&cursor=" + base64.RawURLEncoding.EncodeToString([]byte("next|2018-05-01 10:00:00|0")),
+		"/api/v2/posts?cursor=**",
+	}
+	for _, target := range targets {
+		res := newTestResponse(server.get(target))
+		if res.Code != http.StatusBadRequest || res.Result.Code != INVALID_PARAM {
+			t.Errorf("%v: expected 400 INVALID_PARAM, got %v %v", target, res.Code, res.Body)
+		}
+	}
+}
+
+func TestNewPostPage(t *testing.T) {
+	posts := make([]Post, 0)
+	for i := 0; i < 4; i++ {
+		posts = append(posts, Post{ID: int64(104 - i), PostDate: time.Date(2018, 5, 5-i, 10, 0, 0, 0, time.Local)})
+	}
+	cursor := &PostCursor{Date: time.Date(2018, 5, 6, 10, 0, 0, 0, time.Local), ID: 105}
+	prevCursor := &PostCursor{Date: cursor.Date, ID: cursor.ID, Prev: true}
+
+	tests := []struct {
+		name      string
+		postRange PostRange
+		// found is the number of posts found by pageRange(postRange)
+		found   int
+		ids     []int64
+		hasNext bool
+		hasPrev bool
+	}{
+		{"first page less than a page", PostRange{Limit: 3}, 2, []int64{104, 103}, false, false},
+		{"first page of a full page", PostRange{Limit: 3}, 3, []int64{104, 103, 102}, false, false},
+		{"first page and more", PostRange{Limit: 3}, 4, []int64{104, 103, 102}, true, false},
+		{"second page by offset", PostRange{Offset: 3, Limit: 3}, 1, []int64{104}, false, true},
+		{"after cursor of a full page", PostRange{Limit: 3, Cursor: cursor}, 3, []int64{104, 103, 102}, false, true},
+		{"after cursor and more", PostRange{Limit: 3, Cursor: cursor}, 4, []int64{104, 103, 102}, true, true},
+		{"nothing after cursor", PostRange{Limit: 3, Cursor: cursor}, 0, []int64{}, false, true},
+		// posts before a cursor are also ordered newest first, so the one more post to drop is the first
+		{"before cursor of a full page", PostRange{Limit: 3, Cursor: prevCursor}, 3, []int64{104, 103, 102}, true, false},
+		{"before cursor and more", PostRange{Limit: 3, Cursor: prevCursor}, 4, []int64{103, 102, 101}, true, true},
+		{"nothing before cursor", PostRange{Limit: 3, Cursor: prevCursor}, 0, []int64{}, true, false},
+	}
+
+	for _, test := range tests {
+		if limit := pageRange(test.postRange).Limit; limit != test.postRange.Limit+1 {
+			t.Errorf("%v: expected limit %v, got %v", test.name, test.postRange.Limit+1, limit)
+		}
+
+		page := newPostPage(posts[:test.found], test.postRange)
+
+		ids := make([]int64, 0)
+		for _, post := range page.Posts {
+			ids = append(ids, post.ID)
+		}
+		if !equalIds(ids, test.ids) {
+			t.Errorf("%v: expected posts %v, got %v", test.name, test.ids, ids)
+		}
+		if hasNext := len(page.NextCursor) > 0; hasNext != test.hasNext {
+			t.Errorf("%v: expected next %v, got %v", test.name, test.hasNext, hasNext)
+		}
+		if hasPrev := len(page.PrevCursor) > 0; hasPrev != test.hasPrev {
+			t.Errorf("%v: expected prev %v, got %v", test.name, test.hasPrev, hasPrev)
+		}
+	}
+}
+
+func TestWantsPostList(t *testing.T) {
+	tests := []struct {
+		query       string
+		withCursors bool
+		expected    bool
+	}{
+		{"", false, false},
+		{"includeTotal=false", false, false},
+		{"includeTotal=true", false, true},
+		{"includeTotal=1", false, true},
+		{"", true, true},
+		{"includeTotal=false", true, true},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/RecentPosts?"+test.query, nil)
+		c := echo.New().NewContext(req, httptest.NewRecorder())
+		if wants := wantsPostList(c, test.withCursors); wants != test.expected {
+			t.Errorf("%v(cursors %v): expected %v, got %v", test.query, test.withCursors, test.expected, wants)
+		}
+	}
+}
+
+func TestNewPostList(t *testing.T) {
+	posts := func(n int) []Post {
+		return make([]Post, n)
+	}
+	tests := []struct {
+		target    string
+		postRange PostRange
+		page      *PostPage
+		total     int64
+		expected  PostList
+	}{
+		{"/api/RecentPosts?includeTotal=true&size=2", PostRange{Limit: 2}, &PostPage{Posts: posts(2)}, 5,
+			PostList{Total: 5, Page: 1, Size: 2, HasNext: true, Next: "/api/RecentPosts?includeTotal=true&page=2&size=2"}},
+		{"/api/RecentPosts?includeTotal=true&size=2&page=2", PostRange{Offset: 2, Limit: 2}, &PostPage{Posts: posts(2)}, 4,
+			PostList{Total: 4, Page: 2, Size: 2, Prev: "/api/RecentPosts?includeTotal=true&page=1&size=2"}},
+		{"/api/RecentPosts?includeTotal=true&size=2&page=3", PostRange{Offset: 4, Limit: 2}, &PostPage{Posts: posts(1)}, 5,
+			PostList{Total: 5, Page: 3, Size: 2, Prev: "/api/RecentPosts?includeTotal=true&page=2&size=2"}},
+		// past the last page
+		{"/api/RecentPosts?includeTotal=true&size=2&page=9", PostRange{Offset: 16, Limit: 2}, &PostPage{Posts: posts(0)}, 5,
+			PostList{Total: 5, Page: 9, Size: 2, Prev: "/api/RecentPosts?includeTotal=true&page=8&size=2"}},
+		{"/api/PostsByTag?tag=go&excludes=&size=2&page=1", PostRange{Limit: 2}, &PostPage{Posts: posts(2)}, 3,
+			PostList{Total: 3, Page: 1, Size: 2, HasNext: true, Next: "/api/PostsByTag?page=2&size=2&tag=go"}},
+	}
+
+	for _, test := range tests {
+		ctx, closeStore := newTestSiteContext(&MemoryStorage{})
+		req := httptest.NewRequest(http.MethodGet, test.target, nil).WithContext(ctx)
+		c := echo.New().NewContext(req, httptest.NewRecorder())
+
+		list := newPostList(c, test.postRange, false, test.page, test.total)
+		closeStore()
+
+		list.Posts = nil
+		if !reflect.DeepEqual(*list, test.expected) {
+			t.Errorf("%v: expected %+v, got %+v", test.target, test.expected, *list)
+		}
+	}
+}
+
+func TestNewPostListWithCursors(t *testing.T) {
+	ctx, closeStore := newTestSiteContext(&MemoryStorage{})
+	defer closeStore()
+	req := httptest.NewRequest(http.MethodGet, "/api/RecentPosts?size=2&cursor=abc", nil).WithContext(ctx)
+	c := echo.New().NewContext(req, httptest.NewRecorder())
+
+	list := newPostList(c, PostRange{Limit: 2}, true, &PostPage{Posts: make([]Post, 2), NextCursor: "next", PrevCursor: "prev"}, 5)
+	list.Posts = nil
+	expected := PostList{Total: 5, Size: 2, HasNext: true, Next: "/api/RecentPosts?cursor=next&size=2", Prev: "/api/RecentPosts?cursor=prev&size=2", NextCursor: "next", PrevCursor: "prev"}
+	if !reflect.DeepEqual(*list, expected) {
+		t.Errorf("expected %+v, got %+v", expected, *list)
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/labstack/echo"
+)
+
+// Query and path parameters of handlers are declared as ParamRules and parsed by parseParams.
+// A parameter breaking its rule is INVALID_PARAM. Empty parameters are missing parameters.
+
+const (
+	MAX_PAGE_SIZE    = 100
+	MAX_PAGE         = 10000
+	MAX_EXCLUDES     = 100
+	MAX_BATCH_POSTS  = 50
+	MAX_SLUG_LENGTH  = 200
+	MAX_QUERY_LENGTH = 100
+	MAX_LINK_LENGTH  = 2000
+)
+
+type ParamType int
+
+const (
+	INT_PARAM ParamType = iota
+	INT_LIST_PARAM
+	STRING_PARAM
+	BOOL_PARAM
+)
+
+// ParamRule declares a parameter. Min and Max bound integers, the ids of a list and the length of a string.
+// A zero Max does not bound. MaxItems bounds the number of ids of a list. Values are the allowed strings if not empty.
+type ParamRule struct {
+	Name     string
+	Type     ParamType
+	Path     bool
+	Required bool
+	Min      int64
+	Max      int64
+	MaxItems int
+	Values   []string
+	Default  interface{}
+}
+
+var (
+	pageRule         = ParamRule{Name: "page", Type: INT_PARAM, Min: 1, Max: MAX_PAGE, Default: int64(1)}
+	cursorRule       = ParamRule{Name: "cursor", Type: STRING_PARAM, Max: MAX_SLUG_LENGTH}
+	excludesRule     = ParamRule{Name: "excludes", Type: INT_LIST_PARAM, Min: 1, Max: math.MaxInt32, MaxItems: MAX_EXCLUDES}
+	idsRule          = ParamRule{Name: "ids", Type: INT_LIST_PARAM, Required: true, Min: 1, Max: math.MaxInt32, MaxItems: MAX_BATCH_POSTS}
+	seedRule         = ParamRule{Name: "seed", Type: INT_PARAM, Min: 0, Max: math.MaxInt64}
+	isMobileRule     = ParamRule{Name: "isMobile", Type: BOOL_PARAM, Default: false}
+	includeTotalRule = ParamRule{Name: "includeTotal", Type: BOOL_PARAM, Default: false}
+	keywordRule      = ParamRule{Name: "keyword", Type: STRING_PARAM, Required: true, Min: 1, Max: MAX_QUERY_LENGTH}
+	modeRule         = ParamRule{Name: "mode", Type: STRING_PARAM, Max: MAX_QUERY_LENGTH}
+	linkRule         = ParamRule{Name: "link", Type: STRING_PARAM, Required: true, Min: 1, Max: MAX_LINK_LENGTH}
+	formatRule       = ParamRule{Name: "format", Type: STRING_PARAM, Values: []string{RAW_FORMAT, HTML_FORMAT, TEXT_FORMAT}, Default: RAW_FORMAT}
+)
+
+// sizeRule is the number of posts in a page.
+func sizeRule(defaultSize int) ParamRule {
+	return ParamRule{Name: "size", Type: INT_PARAM, Min: 1, Max: MAX_PAGE_SIZE, Default: int64(defaultSize)}
+}
+
+// idRule is a required positive id.
+func idRule(name string) ParamRule {
+	return ParamRule{Name: name, Type: INT_PARAM, Required: true, Min: 1, Max: math.MaxInt32}
+}
+
+// slugRule is a required slug, post_name, login name or preference name.
+func slugRule(name string) ParamRule {
+	return ParamRule{Name: name, Type: STRING_PARAM, Required: true, Min: 1, Max: MAX_SLUG_LENGTH}
+}
+
+// pathRule reads the parameter from the path of the route.
+func pathRule(rule ParamRule) ParamRule {
+	rule.Path = true
+	return rule
+}
+
+// Params are parsed parameters by name. Missing parameters without Default are not in Params.
+type Params map[string]interface{}
+
+func (p Params) Has(name string) bool {
+	_, has := p[name]
+	return has
+}
+
+func (p Params) Int(name string) int {
+	return int(p.Int64(name))
+}
+
+func (p Params) Int64(name string) int64 {
+	value, _ := p[name].(int64)
+	return value
+}
+
+func (p Params) IntList(name string) []int {
+	if value, ok := p[name].([]int); ok {
+		return value
+	}
+	return make([]int, 0)
+}
+
+func (p Params) String(name string) string {
+	value, _ := p[name].(string)
+	return value
+}
+
+func (p Params) Bool(name string) bool {
+	value, _ := p[name].(bool)
+	return value
+}
+
+// parseParams parses the parameters of the request by the rules.
+func parseParams(c echo.Context, rules ...ParamRule) (Params, error) {
+	params := make(Params)
+	for _, rule := range rules {
+		value := c.QueryParam(rule.Name)
+		if rule.Path {
+			value = c.Param(rule.Name)
+		}
+
+		if len(strings.TrimSpace(value)) == 0 {
+			if rule.Required {
+				return nil, NewApiError(INVALID_PARAM, "No "+rule.Name+" parameter")
+			}
+			if rule.Default != nil {
+				params[rule.Name] = rule.Default
+			}
+			continue
+		}
+
+		parsed, err := rule.parse(value)
+		if err != nil {
+			return nil, NewApiError(INVALID_PARAM, fmt.Sprintf("Wrong %v parameter[%v]: %v", rule.Name, value, err))
+		}
+		params[rule.Name] = parsed
+	}
+	return params, nil
+}
+
+func (rule ParamRule) parse(value string) (interface{}, error) {
+	switch rule.Type {
+	case INT_PARAM:
+		return rule.parseInt(value)
+	case INT_LIST_PARAM:
+		items := strings.Split(value, ",")
+		if rule.MaxItems > 0 && len(items) > rule.MaxItems {
+			return nil, fmt.Errorf("must have at most %v ids", rule.MaxItems)
+		}
+		ids := make([]int, 0, len(items))
+		for _, item := range items {
+			id, err := rule.parseInt(strings.TrimSpace(item))
+			if err != nil {
+				return nil, err
+			}
+			ids = append(ids, int(id))
+		}
+		return ids, nil
+	case BOOL_PARAM:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("must be true or false")
+		}
+		return parsed, nil
+	default:
+		if len(rule.Values) > 0 && !containsString(rule.Values, value) {
+			return nil, fmt.Errorf("must be one of %v", strings.Join(rule.Values, ", "))
+		}
+		length := int64(utf8.RuneCountInString(value))
+		if length < rule.Min || (rule.Max > 0 && length > rule.Max) {
+			return nil, fmt.Errorf("length must be between %v and %v", rule.Min, rule.Max)
+		}
+		return value, nil
+	}
+}
+
+func (rule ParamRule) parseInt(value string) (int64, error) {
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("not an integer")
+	}
+	if parsed < rule.Min || (rule.Max != 0 && parsed > rule.Max) {
+		return 0, fmt.Errorf("must be between %v and %v", rule.Min, rule.Max)
+	}
+	return parsed, nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo"
+)
+
+func TestParseParams(t *testing.T) {
+	tests := []struct {
+		rule     ParamRule
+		query    string
+		expected interface{}
+		wrong    bool
+	}{
+		{sizeRule(4), "", int64(4), false},
+		{sizeRule(4), "size=1", int64(1), false},
+		{sizeRule(4), "size=100", int64(MAX_PAGE_SIZE), false},
+		{sizeRule(4), "size=0", nil, true},
+		{sizeRule(4), "size=101", nil, true},
+		{sizeRule(4), "size=-1", nil, true},
+		{sizeRule(4), "size=2.5", nil, true},
+		{sizeRule(4), "size=abc", nil, true},
+		{pageRule, "page=10000", int64(MAX_PAGE), false},
+		{pageRule, "page=10001", nil, true},
+		{idRule("id"), "id=100", int64(100), false},
+		{idRule("id"), "", nil, true},
+		{idRule("id"), "id=%20", nil, true},
+		{idRule("id"), "id=2147483648", nil, true},
+		{seedRule, "", nil, false},
+		{seedRule, "seed=0", int64(0), false},
+		{seedRule, "seed=9223372036854775807", int64(9223372036854775807), false},
+		{seedRule, "seed=9223372036854775808", nil, true},
+		{excludesRule, "", nil, false},
+		{excludesRule, "excludes=1,%202,3", []int{1, 2, 3}, false},
+		{excludesRule, "excludes=1,,3", nil, true},
+		{excludesRule, "excludes=1,0", nil, true},
+		{excludesRule, "excludes=1,a", nil, true},
+		{idsRule, "ids=" + joinIds(MAX_BATCH_POSTS), nil, false},
+		{idsRule, "ids=" + joinIds(MAX_BATCH_POSTS+1), nil, true},
+		{includeTotalRule, "", false, false},
+		{includeTotalRule, "includeTotal=true", true, false},
+		{includeTotalRule, "includeTotal=maybe", nil, true},
+		{formatRule, "", RAW_FORMAT, false},
+		{formatRule, "format=text", TEXT_FORMAT, false},
+		{formatRule, "format=markdown", nil, true},
+		{keywordRule, "keyword=go", "go", false},
+		// the length of a string is counted in characters
+		{keywordRule, "keyword=" + strings.Repeat("%EA%B0%80", MAX_QUERY_LENGTH), strings.Repeat("가", MAX_QUERY_LENGTH), false},
+		{keywordRule, "keyword=" + strings.Repeat("%EA%B0%80", MAX_QUERY_LENGTH+1), nil, true},
+		{slugRule("tag"), "tag=%20%20", nil, true},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/test?"+test.query, nil)
+		c := echo.New().NewContext(req, httptest.NewRecorder())
+
+		params, err := parseParams(c, test.rule)
+		if test.wrong {
+			if apiError, ok := err.(*ApiError); !ok || apiError.Code != INVALID_PARAM {
+				t.Errorf("%v: expected INVALID_PARAM, got %v", test.query, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: unexpected error %v", test.query, err)
+			continue
+		}
+		// lists of ids too long to write are checked only by the error
+		if test.rule.Name == "ids" {
+			continue
+		}
+		if value := params[test.rule.Name]; !reflect.DeepEqual(value, test.expected) {
+			t.Errorf("%v: expected %#v, got %#v", test.query, test.expected, value)
+		}
+	}
+}
+
+func TestParsePathParams(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/api/v2/posts/100?id=200", nil)
+	c := e.NewContext(req, httptest.NewRecorder())
+	c.SetParamNames("id")
+	c.SetParamValues("100")
+
+	params, err := parseParams(c, pathRule(idRule("id")))
+	if err != nil || params.Int("id") != 100 {
+		t.Errorf("expected the id in the path, got %v %v", params, err)
+	}
+}
+
+func joinIds(n int) string {
+	ids := ""
+	for i := 1; i <= n; i++ {
+		if i > 1 {
+			ids += ","
+		}
+		ids += fmt.Sprint(i)
+	}
+	return ids
+}
@@ -13,7 +13,6 @@ import (
 	"encoding/json"
 	"net/url"
 	"net/http"
-	"errors"
 	"github.com/wulijun/go-php-serialize/phpserialize"
 	"path/filepath"
 )
@@ -42,6 +41,12 @@ type Post struct {
 	Tags []Term              `json:"tags"          xorm:"-"`
 	Metas []PostExternalMeta `json:"metas"         xorm:"-"`
 	HighlightedText string   `json:"highlightedText" xorm:"-"`
+	WordCount int            `json:"wordCount"     xorm:"-"`
+	ReadingMinutes int       `json:"readingMinutes" xorm:"-"`
+	// Toc is the table of contents of a single post(see anchorHeadings).
+	Toc []TocEntry           `json:"toc,omitempty" xorm:"-"`
+	// fields are the JSON fields selected by the request.
+	fields FieldSet          `xorm:"-"`
 }
 
 type SearchResult struct {
@@ -52,10 +57,6 @@ type SearchResult struct {
 	} `json:"posts"`
 }
 
-func (Post) TableName() (string) {
-	return "wprdh0703_posts"
-}
-
 type TermPosts struct {
 	Term Term    `json:"term"`
 	Posts []Post `json:"posts"`
@@ -66,36 +67,51 @@ type AuthorPosts struct {
 	Posts []Post     `json:"posts"`
 }
 
+// PostBatch is posts found by ids in the requested order. MissingIds are ids of posts not found or not published.
+type PostBatch struct {
+	Posts      []Post  `json:"posts"`
+	MissingIds []int64 `json:"missingIds"`
+}
+
 type PostMeta struct {
+	PostID int64 `xorm:"post_id"`
 	Key string `xorm:"meta_key"`
 	Value string `xorm:"meta_value"`
 }
 
-func (PostMeta) TableName() (string) {
-	return "wprdh0703_postmeta"
+// MarshalJSON writes only the selected fields of the post.
+func (p Post) MarshalJSON() ([]byte, error) {
+	type post Post
+	data, err := json.Marshal(post(p))
+	if err != nil {
+		return nil, err
+	}
+
+	return p.fields.trim(data)
 }
 
-func (p Post)Search(ctx context.Context, keyword string, page int) ([]Post, error) {
+// Search returns posts in the page of the search result and the number of all posts found.
+// Failures of the search API are UPSTREAM_FAILED.
+func (p Post)Search(ctx context.Context, keyword string, page int) ([]Post, int, error) {
 	encodedKeyword := &url.URL{Path: fmt.Sprintf(`%v`, keyword)}
-	searchAPI := fmt.Sprintf(`http://127.0.0.1:8099/api/search/%v?page=%v`, encodedKeyword.String(), page)
+	searchAPI := fmt.Sprintf(`%v/api/search/%v?page=%v`, GetConfig(ctx).SearchAPI, encodedKeyword.String(), page)
 
 	req, err := http.NewRequest("GET", searchAPI, nil)
 	if err != nil {
 		fmt.Println("ERROR:", err.Error(), " ==>", searchAPI)
-		return nil, err
+		return nil, 0, upstreamError(err)
 	}
 	httpClient := &http.Client{}
 	resp, err := httpClient.Do(req)
 	if err != nil {
 		fmt.Println("ERROR sending request:", err.Error())
-		return nil, err
+		return nil, 0, upstreamError(err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 300 || resp.StatusCode < 200 {
 		fmt.Println("ERROR wrong status code:", resp.StatusCode, " ==>", searchAPI)
-		//return 0, errors.New("wrong status code:" + resp.StatusCode")
-		return nil, errors.New("wrong http response status code")
+		return nil, 0, NewApiError(UPSTREAM_FAILED, fmt.Sprintf("wrong http response status code: %v", resp.StatusCode))
 	}
 	jsonResult, _ := ioutil.ReadAll(resp.Body)
 
@@ -104,12 +120,12 @@ func (p Post)Search(ctx context.Context, keyword string, page int) ([]Post, erro
 	if err != nil {
 		fmt.Println("ERROR parsing result:", err.Error())
 		fmt.Println("Result:", string(jsonResult))
-		return nil, err
+		return nil, 0, upstreamError(err)
 	}
 
 	if len(searchResult.Posts) == 0 {
 		emptyPosts := make([]Post, 0)
-		return emptyPosts, err
+		return emptyPosts, searchResult.TotalHits, err
 	}
 
 	postIds := make([]int64, 0)
@@ -120,7 +136,7 @@ func (p Post)Search(ctx context.Context, keyword string, page int) ([]Post, erro
 
 	posts, err := p.GetPostsByIds(ctx, postIds, "post")
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	// order by search result
@@ -141,52 +157,68 @@ func (p Post)Search(ctx context.Context, keyword string, page int) ([]Post, erro
 		}
 	}
 
-	return orderedPosts, nil
+	return orderedPosts, searchResult.TotalHits, nil
 }
 
 func (Post)GetPostById(ctx context.Context, postId int64) (*Post, error) {
-	post := &Post{}
-
-	has, err := GetDBConn(ctx).
-		Select("ID, post_author, post_content, post_title, post_date, post_name, guid, post_excerpt").
-		Where("post_status in ('draft', 'future', 'publish')").
-		And("post_type = 'post'").
-		And("ID = ?", postId).
-		OrderBy("post_date desc").
-		Get(post)
-
-	if !has {
-		return nil, nil
+	store, err := GetStore(ctx)
+	if err != nil {
+		return nil, err
 	}
 
+	post, err := store.Posts.FindOne(ctx, postId, "draft", "future", "publish")
 	if err != nil {
 		return nil, err
 	}
 
+	if post == nil {
+		return nil, nil
+	}
+
 	err = post.loadAssociations(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	post.processSpecialElement(ctx)
+	if GetFields(ctx).HasAny("content", "toc") {
+		post.renderContent(ctx)
+	}
 	return post, nil
 }
 
+// GetChangedPost finds a post in any status and its terms to find cached responses changed by the post.
+// It returns nil if the post does not exist.
+func (Post)GetChangedPost(ctx context.Context, postId int64) (*Post, []Term, error) {
+	store, err := GetStore(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	post, err := store.Posts.FindOne(ctx, postId, "publish", "future", "draft", "pending", "private", "trash")
+	if err != nil || post == nil {
+		return nil, nil, err
+	}
+
+	termsByPost, err := (Term{}).FindByPosts(ctx, []int64{postId})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return post, termsByPost[postId], nil
+}
+
 func (Post)GetPostsByIds(ctx context.Context, postIds []int64, postType string) ([]Post, error) {
-	var posts []Post
+	store, err := GetStore(ctx)
+	if err != nil {
+		return nil, err
+	}
 
 	postStatus := "publish"
 	if postType == "attachment" {
 		postStatus = "inherit"
 	}
 
-	err := GetDBConn(ctx).
-		Select("ID, post_author, post_content, post_title, post_date, post_name, guid, post_excerpt").
-		Where("post_status = ?", postStatus).
-		And("post_type = ?", postType).
-		In("ID", postIds).
-		Find(&posts)
-
+	posts, err := store.Posts.FindByIds(ctx, postIds, postType, postStatus)
 	if err != nil {
 		return nil, err
 	}
@@ -194,148 +226,133 @@ func (Post)GetPostsByIds(ctx context.Context, postIds []int64, postType string)
 	return loadPostAssoications(ctx, posts)
 }
 
-func (Post)GetByPermalink(ctx context.Context, permalink string) (*Post, error) {
-	post := &Post{}
+// GetBatch finds published posts by ids with the associations of lists. Duplicated ids are found once.
+func (p Post)GetBatch(ctx context.Context, postIds []int64) (*PostBatch, error) {
+	uniqueIds := make([]int64, 0)
+	for _, id := range postIds {
+		if !containsInt64(uniqueIds, id) {
+			uniqueIds = append(uniqueIds, id)
+		}
+		// a missing post can be published later
+		addCacheTags(ctx, postTag(id))
+	}
 
-	has, err := GetDBConn(ctx).
-		Select("ID, post_author, post_content, post_title, post_date, post_name, guid, post_excerpt").
-		Where("post_status = 'publish'").
-		And("post_type = 'post'").
-		And("post_name = ?", permalink).
-		OrderBy("post_date desc").
-		Get(post)
+	postMap, err := p.getPostMap(ctx, uniqueIds)
+	if err != nil {
+		return nil, err
+	}
 
-	if !has {
-		return nil, nil
+	batch := &PostBatch{Posts: make([]Post, 0), MissingIds: make([]int64, 0)}
+	for _, id := range uniqueIds {
+		if post, has := postMap[id]; has {
+			batch.Posts = append(batch.Posts, post)
+		} else {
+			batch.MissingIds = append(batch.MissingIds, id)
+		}
 	}
+	return batch, nil
+}
 
+func (Post)GetByPermalink(ctx context.Context, permalink string) (*Post, error) {
+	store, err := GetStore(ctx)
 	if err != nil {
 		return nil, err
 	}
 
+	post, err := store.Posts.FindByName(ctx, permalink)
+	if err != nil {
+		return nil, err
+	}
+
+	if post == nil {
+		return nil, nil
+	}
+
 	err = post.loadAssociations(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	post.processSpecialElement(ctx)
+	if GetFields(ctx).HasAny("content", "toc") {
+		post.renderContent(ctx)
+	}
 	return post, nil
 }
 
-func (p *Post) processSpecialElement(ctx context.Context) {
-	if !strings.Contains(p.Content, "[gallery") {
-		return
+// renderContent renders the content in the format of the request(see GetContentFormat) and sets the table of contents.
+// Headings of the html format get anchors. The raw and text formats have no anchors, and their toc is of the html format.
+func (p *Post) renderContent(ctx context.Context) {
+	switch GetContentFormat(ctx) {
+	case HTML_FORMAT:
+		p.Content, p.Toc = anchorHeadings(renderContent(ctx, p.Content, HTML_FORMAT))
+	case TEXT_FORMAT:
+		content, toc := anchorHeadings(renderContent(ctx, p.Content, HTML_FORMAT))
+		p.Content, p.Toc = htmlToText(content), toc
+	default:
+		if GetFields(ctx).Has("toc") {
+			_, p.Toc = anchorHeadings(renderContent(ctx, p.Content, HTML_FORMAT))
+		}
+		p.Content = renderContent(ctx, p.Content, RAW_FORMAT)
 	}
+}
 
-	prefix := ""
-	processedContent := ""
-	lines := strings.Split(p.Content, "\n")
-	for _, line := range lines {
-		if strings.HasPrefix(strings.TrimSpace(line), "[gallery") {
-			//[gallery columns="2" size="medium" ids="17068,17069"]
-
-			columns := ""
-			size := ""
-			ids := make([]int64, 0)
-			tokens := strings.Split(strings.Replace(strings.Replace(line, "[gallery ", "", -1), "]", "", -1), " ")
-			for _, eachToken := range tokens {
-				keyVal := strings.Split(eachToken, "=")
-				if len(keyVal) != 2 {
-					continue
-				}
-				key := keyVal[0]
-				val := keyVal[1]
-
-				if key == "ids" {
-					idStr, err := strconv.Unquote(strings.TrimSpace(val))
-					if err != nil {
-						fmt.Println("gallery id Unquote error:", val, "==>", err.Error())
-						return
-					}
-					for _, id := range strings.Split(idStr, ",") {
-						i64, err := strconv.ParseInt(id, 10, 32)
-						if err != nil {
-							fmt.Println("gallery id error:", err.Error())
-							return
-						}
-						ids = append(ids, i64)
-					}
-				} else if key == "columns" {
-					columns = val
-				} else if key == "size" {
-					size = val
-				}
-			}
-
-			childPosts, err := p.GetPostsByIds(ctx, ids, "attachment")
-			if err != nil {
-				fmt.Println("error while getting child post:", err.Error())
-				return
-			}
-
-			images := make([]string, 0)
-			postExcerpts := make([]string, 0)
-
-			for _, eachId := range ids {
-				for _, eachChildPost := range childPosts {
-					if eachId == eachChildPost.ID {
-						images = append(images, eachChildPost.Guid)
-						parameters := url.Values{}
-						parameters.Add("", eachChildPost.PostExcerpt)
-						encodedPostExcerpt := parameters.Encode()
+func (Post)GetRecent(ctx context.Context, postRange PostRange) (*PostPage, error) {
+	store, err := GetStore(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-						postExcerpts = append(postExcerpts, encodedPostExcerpt[1:])
-						break
-					}
-				}
-			}
-			processedContent += prefix + fmt.Sprintf("[gallery columns=%v size=%v images=\"%v\" captions=\"%v\"]",
-				columns, size, strings.Join(images, ","), strings.Join(postExcerpts, ","))
-		}	else {
-			processedContent += prefix + line
-		}
-		prefix = "\n"
+	posts, err := store.Posts.FindRecent(ctx, pageRange(postRange))
+	if err != nil {
+		return nil, err
 	}
+	addCacheTags(ctx, POST_LIST_TAG)
 
-	p.Content = processedContent
+	page := newPostPage(posts, postRange)
+	if page.Posts, err = loadPostAssoications(ctx, page.Posts); err != nil {
+		return nil, err
+	}
+	return page, nil
 }
 
-func (Post)GetRecent(ctx context.Context, page int, pageSize int) ([]Post, error) {
-	var posts []Post
-
-	offset := (page - 1) * pageSize
+func (Post)GetNumberOfPosts(ctx context.Context) (int64, error) {
+	store, err := GetStore(ctx)
+	if err != nil {
+		return 0, err
+	}
 
-	err := GetDBConn(ctx).
-		Select("ID, post_author, post_content, post_title, post_date, post_name").
-		Where("post_status = 'publish'").
-		And("post_type = 'post'").
-		OrderBy("post_date desc").
-		Limit(pageSize, offset).
-		Find(&posts)
+	addCacheTags(ctx, POST_LIST_TAG)
+	return store.Posts.CountPublished(ctx)
+}
 
+func (Post)CountByTag(ctx context.Context, tagId int, excludeIds []int) (int64, error) {
+	store, err := GetStore(ctx)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
 
-	return loadPostAssoications(ctx, posts)
+	addCacheTags(ctx, termPostsTag(tagId))
+	return store.Posts.CountByTerm(ctx, tagId, excludeIds)
 }
 
-func (Post)GetNumberOfPosts(ctx context.Context) (int64, error) {
-	var post Post
-	return GetDBConn(ctx).
-		Where("post_status = 'publish'").
-		And("post_type = 'post'").
-		Count(&post)
+func (Post)CountByAuthor(ctx context.Context, authorId int64, excludes []int) (int64, error) {
+	store, err := GetStore(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	addCacheTags(ctx, authorPostsTag(authorId))
+	return store.Posts.CountByAuthor(ctx, authorId, excludes)
 }
 
 func loadPostAssoications(ctx context.Context, posts []Post) ([]Post, error) {
+	if err := loadAssociations(ctx, posts); err != nil {
+		return nil, err
+	}
+
 	loadedPosts := make([]Post, 0)
 
 	for _, eachPost := range posts {
-		if err := (&eachPost).loadAssociations(ctx); err != nil {
-			return nil, err
-		}
-
 		eachPost.Content = "";	//truncate to reduce size
 
 		loadedPosts = append(loadedPosts, eachPost)
@@ -344,72 +361,132 @@ func loadPostAssoications(ctx context.Context, posts []Post) ([]Post, error) {
 }
 
 func (p *Post) loadAssociations(ctx context.Context) error {
-	if err := p.loadAuthor(ctx); err != nil {
+	posts := []Post{*p}
+	if err := loadAssociations(ctx, posts); err != nil {
 		return err
 	}
 
-	if err := p.loadMeta(ctx); err != nil {
-		return err
+	*p = posts[0]
+	return nil
+}
+
+// loadAssociations loads authors, metas, terms and external metas of all posts at once.
+// Each association costs one query regardless of the number of posts.
+// Associations of fields not selected by the request are not loaded.
+func loadAssociations(ctx context.Context, posts []Post) error {
+	if len(posts) == 0 {
+		return nil
 	}
 
-	if err := p.loadCategoriesAndTerms(ctx); err != nil {
-		return err;
+	fields := GetFields(ctx)
+
+	postIds := make([]int64, 0)
+	for i := range posts {
+		postIds = append(postIds, posts[i].ID)
+		posts[i].fields = fields
+		addCacheTags(ctx, postTag(posts[i].ID))
 	}
 
-	if extraMetas, err := (PostExternalMeta{}).GetByPost(ctx, p.ID); err != nil {
-		return err
-	} else {
-		p.Metas = extraMetas
+	// before lists drop contents
+	if fields.HasAny("wordCount", "readingMinutes") {
+		for i := range posts {
+			posts[i].setReadingStats(ctx)
+		}
+	}
+
+	if fields.Has("author") {
+		if err := loadAuthors(ctx, posts); err != nil {
+			return err
+		}
+	}
+
+	if fields.HasAny("image", "mediumImage", "thumbnailImage", "socialTitle", "socialDesc") {
+		if err := loadMetas(ctx, posts, postIds, fields.HasAny("mediumImage", "thumbnailImage")); err != nil {
+			return err
+		}
+	}
+
+	if fields.HasAny("categories", "tags") {
+		if err := loadCategoriesAndTerms(ctx, posts, postIds); err != nil {
+			return err;
+		}
+	}
+
+	if fields.Has("metas") {
+		extraMetas, err := (PostExternalMeta{}).GetByPosts(ctx, postIds)
+		if err != nil {
+			return err
+		}
+		for i := range posts {
+			posts[i].Metas = extraMetas[posts[i].ID]
+			if posts[i].Metas == nil {
+				posts[i].Metas = make([]PostExternalMeta, 0)
+			}
+		}
 	}
 
 	return nil
 }
 
-func (p Post)GetRandomPostsByTerm(ctx context.Context, isMobile bool) ([]TermPosts, error) {
-	terms, err := Term{}.CountTerm(ctx)
+// GetRandomPostsByTerm picks random tags and random posts of each tag.
+// The same seed picks the same posts unless posts are changed.
+func (p Post)GetRandomPostsByTerm(ctx context.Context, isMobile bool, seed int64) ([]TermPosts, error) {
+	idsByTag, _, err := GetSite(ctx).PostIndex.Get(ctx)
 	if err != nil {
 		return nil, err
 	}
+	addCacheTags(ctx, POST_LIST_TAG)
 
-	numTerms := len(terms)
+	termIds := popularTermIds(idsByTag)
 
 	numResults := MAX_AUTHORS
 	if isMobile {
 		numResults = 3
 	}
 
-	if numTerms < MAX_AUTHORS {
-		numResults = numTerms;
+	pageSize := 5
+	if isMobile {
+		pageSize = 2
 	}
 
-	termPostsArray := make([]TermPosts, 0)
+	rng := rand.New(rand.NewSource(seed))
 
-	selectedIndexes := make(map[int]bool)
-	for i := 0; i < numResults; i++ {
-		// find not selected random value
-		for {
-			termIndex := rand.Intn(numTerms)
-			if _, has := selectedIndexes[termIndex]; !has {
-				selectedIndexes[termIndex] = true
-				break
-			}
-		}
+	selectedTermIds := make([]int, 0)
+	sampledPostIds := make(map[int][]int64)
+	postIds := make([]int64, 0)
+	for _, index := range sampleIndexes(rng, len(termIds), numResults) {
+		termId := termIds[index]
+		selectedTermIds = append(selectedTermIds, termId)
+		sampledPostIds[termId] = samplePostIds(rng, idsByTag[termId], pageSize)
+		postIds = append(postIds, sampledPostIds[termId]...)
+		addCacheTags(ctx, termPostsTag(termId))
 	}
 
-	pageSize := 5
-	if isMobile {
-		pageSize = 2
+	terms, err := Term{}.FindByIds(ctx, selectedTermIds, "post_tag")
+	if err != nil {
+		return nil, err
 	}
 
-	for index, _ := range selectedIndexes {
-		posts, err := p.getTermPosts(ctx, terms[index].Term.ID, "", "RAND()", true,1, pageSize)
-		if err != nil {
-			return nil, err
+	posts, err := p.getPostMap(ctx, postIds)
+	if err != nil {
+		return nil, err
+	}
+
+	termPostsArray := make([]TermPosts, 0)
+	for _, termId := range selectedTermIds {
+		term, has := terms[termId]
+		if !has {
+			continue
 		}
 
 		termPosts := 	TermPosts{
-			Term: terms[index].Term,
-			Posts: posts,
+			Term: term,
+			Posts: make([]Post, 0),
+		}
+		for _, postId := range sampledPostIds[termId] {
+			if post, has := posts[postId]; has {
+				termPosts.Posts = append(termPosts.Posts, post)
+			}
 		}
 		termPostsArray = append(termPostsArray, termPosts)
 	}
@@ -418,102 +495,91 @@ func (p Post)GetRandomPostsByTerm(ctx context.Context, isMobile bool) ([]TermPos
 }
 
 func (Post)getTermPosts(ctx context.Context, termId int,
-	where string, orderBy string, loadAssociation bool, page int, pageSize int) ([]Post, error) {
-	var posts []Post
-
-	offset := (page - 1) * pageSize
-
-	query := GetDBConn(ctx).Table("wprdh0703_posts").
-		//Select("wprdh0703_posts.*").
-		Select("wprdh0703_posts.ID, wprdh0703_posts.post_author, wprdh0703_posts.post_content, wprdh0703_posts.post_title, wprdh0703_posts.post_date, wprdh0703_posts.post_name").
-		Join("INNER", "wprdh0703_term_relationships", "wprdh0703_posts.ID = wprdh0703_term_relationships.object_id").
-		Join("INNER", "wprdh0703_term_taxonomy", "wprdh0703_term_taxonomy.term_taxonomy_id = wprdh0703_term_relationships.term_taxonomy_id").
-		Where("wprdh0703_posts.post_status = 'publish'").
-		And("wprdh0703_posts.post_type = 'post'").
-		And("wprdh0703_term_taxonomy.term_id = ?", termId)
-
-	if len(where) > 0 {
-		query = query.Where(where)
+	excludes []int, order PostOrder, loadAssociation bool, postRange PostRange) (*PostPage, error) {
+	store, err := GetStore(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	query = query.OrderBy(orderBy).Limit(pageSize, offset)
-	// Run query
-	err := query.Find(&posts)
+	posts, err := store.Posts.FindByTerm(ctx, termId, excludes, order, pageRange(postRange))
 	if err != nil {
 		return nil, err
 	}
+	addCacheTags(ctx, termPostsTag(termId))
 
+	page := newPostPage(posts, postRange)
 	if loadAssociation {
-		loadedPosts, err := loadPostAssoications(ctx, posts)
-		if err != nil {
+		if page.Posts, err = loadPostAssoications(ctx, page.Posts); err != nil {
 			return nil, err
 		}
-
-		return loadedPosts, nil
-	} else {
-		return posts, nil
 	}
+	return page, nil
 }
 
-func (p Post)GetByAuthor(ctx context.Context, authorId int64, excludes []int, page int, pageSize int) ([]Post, error) {
-	idList := ""
-	prefix := ""
-	for _, eachId := range excludes {
-		idList += prefix + strconv.Itoa(eachId)
-		prefix = ","
+func (p Post)GetByAuthor(ctx context.Context, authorId int64, excludes []int, postRange PostRange) (*PostPage, error) {
+	page, err := p.getAuthorPosts(ctx, authorId, excludes, ORDER_BY_DATE_DESC, postRange)
+	if err != nil {
+		return nil, err
 	}
 
-	where := ""
-	if len(excludes) > 0 {
-		where = "wprdh0703_users.ID not in (" + idList + ")"
-	}
+	return page, nil
+}
 
-	posts, err := p.getAuthorPosts(ctx, authorId, where, "wprdh0703_posts.post_date desc", page, pageSize)
+// GetRandomPostsByAuthor picks random authors and random posts of each author.
+// The same seed picks the same posts unless posts are changed.
+func (p Post)GetRandomPostsByAuthor(ctx context.Context, isMobile bool, seed int64) ([]AuthorPosts, error) {
+	_, idsByAuthor, err := GetSite(ctx).PostIndex.Get(ctx)
 	if err != nil {
 		return nil, err
 	}
+	addCacheTags(ctx, POST_LIST_TAG)
 
-	return posts, nil
-}
-
-func (p Post)GetRandomPostsByAuthor(ctx context.Context, isMobile bool) ([]AuthorPosts, error) {
-	authors, err := Author{}.FindAuthorByPostCount(ctx, 2)
+	authors, err := Author{}.GetByIds(ctx, activeAuthorIds(idsByAuthor))
 	if err != nil {
 		return nil, err
 	}
 
-	numAuthors := len(authors)
+	// posts of deleted users are not shown
+	authorIds := make([]int64, 0)
+	for _, authorId := range activeAuthorIds(idsByAuthor) {
+		if _, has := authors[authorId]; has {
+			authorIds = append(authorIds, authorId)
+		}
+	}
 
 	numResults := MAX_AUTHORS
 	if isMobile {
 		numResults = 3
 	}
-	if numAuthors < MAX_AUTHORS {
-		numResults = numAuthors;
-	}
 
-	authorPostsArray := make([]AuthorPosts, 0)
+	rng := rand.New(rand.NewSource(seed))
 
-	selectedIndexes := make(map[int]bool)
-	for i := 0; i < numResults; i++ {
-		// find not selected random value
-		for {
-			authorIndex := rand.Intn(numAuthors)
-			if _, has := selectedIndexes[authorIndex]; !has {
-				selectedIndexes[authorIndex] = true
-				break
-			}
-		}
+	selectedAuthorIds := make([]int64, 0)
+	sampledPostIds := make(map[int64][]int64)
+	postIds := make([]int64, 0)
+	for _, index := range sampleIndexes(rng, len(authorIds), numResults) {
+		authorId := authorIds[index]
+		selectedAuthorIds = append(selectedAuthorIds, authorId)
+		sampledPostIds[authorId] = samplePostIds(rng, idsByAuthor[authorId], 2)
+		postIds = append(postIds, sampledPostIds[authorId]...)
+		addCacheTags(ctx, authorPostsTag(authorId))
 	}
 
-	for index, _ := range selectedIndexes {
-		posts, err := p.getAuthorPosts(ctx, authors[index].ID, "", "RAND()", 1, 2)
-		if err != nil {
-			return nil, err
-		}
+	posts, err := p.getPostMap(ctx, postIds)
+	if err != nil {
+		return nil, err
+	}
+
+	authorPostsArray := make([]AuthorPosts, 0)
+	for _, authorId := range selectedAuthorIds {
 		authorPosts := AuthorPosts{
-			Author: authors[index],
-			Posts: posts,
+			Author: authors[authorId],
+			Posts: make([]Post, 0),
+		}
+		for _, postId := range sampledPostIds[authorId] {
+			if post, has := posts[postId]; has {
+				authorPosts.Posts = append(authorPosts.Posts, post)
+			}
 		}
 
 		authorPostsArray = append(authorPostsArray, authorPosts)
@@ -522,77 +588,105 @@ func (p Post)GetRandomPostsByAuthor(ctx context.Context, isMobile bool) ([]Autho
 	return authorPostsArray, nil
 }
 
-func (Post)getAuthorPosts(ctx context.Context, authorId int64, where string, orderBy string, page int, pageSize int) ([]Post, error) {
-	var posts []Post
-
-	offset := (page - 1) * pageSize
-
-	query := GetDBConn(ctx).Table("wprdh0703_posts").
-		Select("wprdh0703_posts.ID, wprdh0703_posts.post_author, wprdh0703_posts.post_content, wprdh0703_posts.post_title, wprdh0703_posts.post_date, wprdh0703_posts.post_name").
-		Join("INNER", "wprdh0703_users", "wprdh0703_posts.post_author = wprdh0703_users.ID").
-		Where("wprdh0703_posts.post_status = 'publish'").
-		And("wprdh0703_posts.post_type = 'post'").
-		And("wprdh0703_posts.post_author = ?", authorId)
+// getPostMap finds published posts with associations by id.
+func (p Post)getPostMap(ctx context.Context, postIds []int64) (map[int64]Post, error) {
+	postMap := make(map[int64]Post)
+	if len(postIds) == 0 {
+		return postMap, nil
+	}
 
-	if len(where) > 0 {
-		query = query.Where(where)
+	posts, err := p.GetPostsByIds(ctx, postIds, "post")
+	if err != nil {
+		return nil, err
 	}
 
-	err := query.OrderBy(orderBy).Limit(pageSize, offset).Find(&posts)
+	for _, post := range posts {
+		postMap[post.ID] = post
+	}
+	return postMap, nil
+}
 
+func (Post)getAuthorPosts(ctx context.Context, authorId int64, excludes []int, order PostOrder, postRange PostRange) (*PostPage, error) {
+	store, err := GetStore(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	loadedPosts, err := loadPostAssoications(ctx, posts)
+	posts, err := store.Posts.FindByAuthor(ctx, authorId, excludes, order, pageRange(postRange))
 	if err != nil {
 		return nil, err
 	}
+	addCacheTags(ctx, authorPostsTag(authorId))
 
-	return loadedPosts, nil
-}
-
-func (p Post)GetByTag(ctx context.Context, tagId int, excludeIds []int, page int, pageSize int) ([]Post, error) {
-	idList := ""
-	prefix := ""
-	for _, eachId := range excludeIds {
-		idList += prefix + strconv.Itoa(eachId)
-		prefix = ","
-	}
-	where := ""
-	if len(excludeIds) > 0 {
-		where = "wprdh0703_posts.ID not in (" + idList + ")"
+	page := newPostPage(posts, postRange)
+	if page.Posts, err = loadPostAssoications(ctx, page.Posts); err != nil {
+		return nil, err
 	}
+	return page, nil
+}
 
-	posts, err := p.getTermPosts(ctx, tagId, where, "wprdh0703_posts.post_date desc", true, page, pageSize)
+func (p Post)GetByTag(ctx context.Context, tagId int, excludeIds []int, postRange PostRange) (*PostPage, error) {
+	page, err := p.getTermPosts(ctx, tagId, excludeIds, ORDER_BY_DATE_DESC, true, postRange)
 	if err != nil {
 		return nil, err
 	}
 
-	return posts, nil
+	return page, nil
 }
 
-func (p *Post)loadAuthor(ctx context.Context) error {
-	if author, err := (Author{}).GetOne(ctx, p.AuthorID); err != nil {
+func loadAuthors(ctx context.Context, posts []Post) error {
+	authorIds := make([]int64, 0)
+	for _, eachPost := range posts {
+		if !containsInt64(authorIds, eachPost.AuthorID) {
+			authorIds = append(authorIds, eachPost.AuthorID)
+		}
+	}
+
+	authors, err := (Author{}).GetByIds(ctx, authorIds)
+	if err != nil {
 		return err
-	} else {
-		p.Author = *author
+	}
+
+	// posts of deleted authors have an empty author
+	for i := range posts {
+		posts[i].Author = authors[posts[i].AuthorID]
 	}
 
 	return nil
 }
 
-func (p *Post)loadMeta(ctx context.Context) error {
-	var postMetas []PostMeta
-	err := GetDBConn(ctx).Table("wprdh0703_postmeta").
-			Where("post_id = ?", p.ID).
-			In("meta_key", "post_image", "_aioseop_description", "_aioseop_title", "_thumbnail_id").
-			Find(&postMetas)
+// loadMetas sets images and social fields of posts. Thumbnails need one more query for attachments.
+func loadMetas(ctx context.Context, posts []Post, postIds []int64, withThumbnails bool) error {
+	store, err := GetStore(ctx)
+	if err != nil {
+		return err
+	}
 
+	postMetas, err := store.Posts.FindMetasByPosts(ctx, postIds, "post_image", "_aioseop_description", "_aioseop_title", "_thumbnail_id")
 	if err != nil {
 		return err
 	}
 
+	thumbnailIds := make(map[int64]int64)
+	for i := range posts {
+		for _, eachMeta := range postMetas[posts[i].ID] {
+			if eachMeta.Key == "_thumbnail_id" {
+				if attachmentId, err := strconv.ParseInt(eachMeta.Value, 10, 64); err == nil {
+					thumbnailIds[posts[i].ID] = attachmentId
+				}
+			}
+		}
+		posts[i].setMetas(postMetas[posts[i].ID])
+	}
+
+	if withThumbnails {
+		setThumbnailImages(ctx, posts, thumbnailIds)
+	}
+
+	return nil
+}
+
+func (p *Post)setMetas(postMetas []PostMeta) {
 	for _, eachMeta := range postMetas {
 		if eachMeta.Key == "post_image" {
 			p.Image = eachMeta.Value
@@ -600,8 +694,6 @@ func (p *Post)loadMeta(ctx context.Context) error {
 			p.SocialDesc = eachMeta.Value
 		} else if eachMeta.Key == "_aioseop_title" {
 			p.SocialTitle = eachMeta.Value
-		} else if eachMeta.Key == "_thumbnail_id" {
-			p.setThumbnailImage(ctx, eachMeta.Value)
 		}
 	}
 
@@ -625,29 +717,45 @@ func (p *Post)loadMeta(ctx context.Context) error {
 	}
 	p.SocialDesc = strings.Replace(p.SocialDesc, "\n", " ", -1)
 	p.SocialDesc = html.EscapeString(p.SocialDesc)
-
-	return nil
 }
 
-func (p *Post)setThumbnailImage(ctx context.Context, thumbnailId string) {
-	if len(thumbnailId) == 0 {
+// setThumbnailImages sets thumbnail images of posts from the metadata of their attachments(thumbnailIds by post id).
+func setThumbnailImages(ctx context.Context, posts []Post, thumbnailIds map[int64]int64) {
+	if len(thumbnailIds) == 0 {
+		return
+	}
+
+	store, err := GetStore(ctx)
+	if err != nil {
 		return
 	}
 
-	var postMeta PostMeta
-	has, err := GetDBConn(ctx).Table("wprdh0703_postmeta").
-		Where("post_id = ?", thumbnailId).
-		And("meta_key = ?", "_wp_attachment_metadata").
-		Get(&postMeta)
+	attachmentIds := make([]int64, 0)
+	for _, attachmentId := range thumbnailIds {
+		attachmentIds = append(attachmentIds, attachmentId)
+	}
 
-	if !has || err != nil {
+	attachmentMetas, err := store.Posts.FindMetasByPosts(ctx, attachmentIds, "_wp_attachment_metadata")
+	if err != nil {
 		return
 	}
 
+	siteURL := GetConfig(ctx).SiteURL
+	for i := range posts {
+		attachmentId, has := thumbnailIds[posts[i].ID]
+		if !has || len(attachmentMetas[attachmentId]) == 0 {
+			continue
+		}
+		posts[i].setThumbnailImage(siteURL, attachmentMetas[attachmentId][0])
+	}
+}
+
+func (p *Post)setThumbnailImage(siteURL string, postMeta PostMeta) {
 	var  decodeRes interface{}
+	var err error
 
 	if decodeRes, err = phpserialize.Decode(postMeta.Value); err != nil {
-		fmt.Errorf("decode data fail %v, %v", err, postMeta.Value)
+		fmt.Println("ERROR decode data fail", err, postMeta.Value)
 		return
 	}
 
@@ -667,13 +775,13 @@ func (p *Post)setThumbnailImage(ctx context.Context, thumbnailId string) {
 	if !ok {
 		return
 	}
-	p.ThumbnailImage = "https://www.popit.kr/" + imagePath + thumbnailMap["file"].(string)
+	p.ThumbnailImage = siteURL + imagePath + thumbnailMap["file"].(string)
 
 	mediumMap, ok := sizesMap["medium"].(map[interface{}]interface{})
 	if !ok {
 		return
 	}
-	p.MediumImage = "https://www.popit.kr/" + imagePath + mediumMap["file"].(string)
+	p.MediumImage = siteURL + imagePath + mediumMap["file"].(string)
 }
 
 func (p *Post)getDescriptionFromContents() string {
@@ -723,54 +831,37 @@ func (p *Post)getDescriptionFromContents() string {
 	return strings.TrimSpace(socialDescText)
 }
 
-func (p *Post)loadCategoriesAndTerms(ctx context.Context) error {
-	var terms []Term
-
-	terms, err := (&Term{}).FindByPost(ctx, p.ID)
+func loadCategoriesAndTerms(ctx context.Context, posts []Post, postIds []int64) error {
+	termsByPost, err := (Term{}).FindByPosts(ctx, postIds)
 
 	if err != nil {
 		return err
 	}
 
-	categories := make([]Term, 0)
-	tags := make([]Term, 0)
-	for _, eachTerm := range terms {
-		if eachTerm.Taxonomy == "category" {
-			categories = append(categories, eachTerm)
-		} else if eachTerm.Taxonomy == "post_tag" {
-			tags = append(tags, eachTerm)
+	for i := range posts {
+		categories := make([]Term, 0)
+		tags := make([]Term, 0)
+		for _, eachTerm := range termsByPost[posts[i].ID] {
+			addCacheTags(ctx, termTag(eachTerm.ID))
+			if eachTerm.Taxonomy == "category" {
+				categories = append(categories, eachTerm)
+			} else if eachTerm.Taxonomy == "post_tag" {
+				tags = append(tags, eachTerm)
+			}
 		}
-	}
 
-	p.Categories = categories
-	p.Tags = tags
+		posts[i].Categories = categories
+		posts[i].Tags = tags
+	}
 
 	return nil
 }
 
 func (p *Post)UpdateFacebookLike(ctx context.Context, likes int) error {
-	var facebookLike FacebookLike
-	has, err := GetDBConn(ctx).Where("post_id = ?", p.ID).Get(&facebookLike)
+	store, err := GetStore(ctx)
 	if err != nil {
 		return err
 	}
 
-	if has {
-		fmt.Println("Update likes: id=", facebookLike.Id, ", likes=", likes)
-		facebookLike.Likes = likes
-		_, err := GetDBConn(ctx).Where("id = ?", facebookLike.Id).Update(facebookLike)
-		if err != nil {
-			return err
-		}
-	} else {
-		fmt.Println("Insert likes: post_id=", p.ID, ", likes=", likes)
-		facebookLike.PostId = p.ID
-		facebookLike.Likes = likes
-		_, err := GetDBConn(ctx).Insert(facebookLike)
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+	return store.Posts.UpdateFacebookLike(ctx, p.ID, likes)
 }
-
@@ -21,16 +21,13 @@ func (PostExternalMeta) TableName() (string) {
 	return "post_external_metas"
 }
 
-func (PostExternalMeta) GetByPost(ctx context.Context, postId int64) ([]PostExternalMeta, error) {
-	var metas []PostExternalMeta
-
-	err := GetDBConn(ctx).Where("post_id = ?", postId).Find(&metas)
-
+func (PostExternalMeta) GetByPosts(ctx context.Context, postIds []int64) (map[int64][]PostExternalMeta, error) {
+	store, err := GetStore(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	return metas, nil
+	return store.ExternalMetas.FindByPosts(ctx, postIds)
 }
 
 // Getting Facebook Like
@@ -56,59 +53,92 @@ func dumpMap(space string, m map[string]interface{}) {
 	}
 }
 
-func StartGetFacebookLike() {
+func StartGetFacebookLike(site *Site) {
 	go func() {
 		for {
 			time.Sleep(2 * time.Second)
 
-			session := xormDb.NewSession()
-			defer session.Close()
+			updateFacebookLikes(site)
 
-			ctx := context.WithValue(context.Background(), "DB", session)
+			time.Sleep(10 * time.Hour)
+		}
+	}()
+}
 
-			posts, err := Post{}.GetRecent(ctx, 1, 100000)
+// updateFacebookLikes walks all published posts by pages of MAX_PAGE_SIZE and updates their likes.
+// Stores are opened for each page and each update, not to hold a database connection while waiting for Facebook.
+func updateFacebookLikes(site *Site) {
+	ctx := withSite(context.Background(), site)
+
+	postRange := PostRange{Limit: MAX_PAGE_SIZE}
+	for {
+		page, err := getFacebookLikePage(site, postRange)
+		if err != nil {
+			fmt.Println("ERROR:", err.Error())
+			return
+		}
+		for _, post := range page.Posts {
+			time.Sleep(20 * time.Second)
+			httpShareCount, err := getFacebookLike(ctx, post, "http")
 			if err != nil {
-				fmt.Println("ERROR:", err.Error())
 				continue
 			}
-			for _, post := range posts {
-				time.Sleep(20 * time.Second)
-				httpShareCount, err := getFacebookLike(ctx, post, "http")
-				if err != nil {
-					continue
-				}
-				httpsShareCount, err := getFacebookLike(ctx, post, "https")
-				if err != nil {
-					continue
-				}
-
-				shareCount := httpShareCount
-				if httpsShareCount  > shareCount {
-					shareCount = httpsShareCount
-				}
-
-				if shareCount == 0 {
-					fmt.Println("==> Like is zero:", post.PostName)
-					continue;
-				}
-
-				err = post.UpdateFacebookLike(ctx, shareCount)
-				if err != nil {
-					fmt.Println("ERROR:", err.Error())
-				}
+			httpsShareCount, err := getFacebookLike(ctx, post, "https")
+			if err != nil {
+				continue
 			}
 
-			time.Sleep(10 * time.Hour)
+			shareCount := httpShareCount
+			if httpsShareCount  > shareCount {
+				shareCount = httpsShareCount
+			}
+
+			if shareCount == 0 {
+				fmt.Println("==> Like is zero:", post.PostName)
+				continue;
+			}
+
+			err = updateFacebookLike(site, post, shareCount)
+			if err != nil {
+				fmt.Println("ERROR:", err.Error())
+			}
 		}
-	}()
+
+		if len(page.NextCursor) == 0 {
+			return
+		}
+		if postRange.Cursor, err = DecodePostCursor(page.NextCursor); err != nil {
+			fmt.Println("ERROR:", err.Error())
+			return
+		}
+	}
 }
+
+// getFacebookLikePage finds a page of posts with a store closed before returning.
+func getFacebookLikePage(site *Site, postRange PostRange) (*PostPage, error) {
+	store, closeStore := site.Storage.Open(site.Config, true)
+	defer closeStore()
+
+	// likes need only id and permalink of posts
+	ctx := withStore(withSite(context.Background(), site), store)
+	ctx = withFields(ctx, FieldSet{"id": nil, "postName": nil})
+	return Post{}.GetRecent(ctx, postRange)
+}
+
+func updateFacebookLike(site *Site, post Post, likes int) error {
+	store, closeStore := site.Storage.Open(site.Config, false)
+	defer closeStore()
+
+	return post.UpdateFacebookLike(withStore(withSite(context.Background(), site), store), likes)
+}
+
 func getFacebookLike(ctx context.Context, post Post, protocol string) (int, error){
 	facebookAPI := "https://graph.facebook.com/?ids="
 	postLink := post.PostName
 	if postLink[len(postLink) - 1] != '/' {
 		postLink = postLink + "/"
 	}
-	postAPI := fmt.Sprintf(`%v%v://www.popit.kr/%v`, facebookAPI, protocol, postLink)
+	postAPI := fmt.Sprintf(`%v%v://%v/%v`, facebookAPI, protocol, GetConfig(ctx).SiteHost(), postLink)
 	req, err := http.NewRequest("GET", postAPI, nil)
 	if err != nil {
 		fmt.Println("ERROR:", err.Error(), " ==>", postAPI)
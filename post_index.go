@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	POST_ID_INDEX_TTL = 10 * time.Minute
+	// terms and authors having less posts are not shown in random posts
+	RANDOM_MIN_POSTS = 2
+	RANDOM_MAX_TERMS = 500
+)
+
+// PostIdIndex holds ids of published posts by tag and by author of a site.
+// Random posts are sampled from it instead of ORDER BY RAND() on the posts table.
+// It is loaded again after POST_ID_INDEX_TTL or when a post is changed on any API server(see PostIndexVersions).
+// The current index is served without waiting while another one is loaded, and only one load runs at a time.
+type PostIdIndex struct {
+	mutex    sync.Mutex
+	site     string
+	versions PostIndexVersions
+	// generation is changed by Invalidate of this server not to keep an index loaded before it.
+	generation int64
+	index      *postIdIndexData
+	loading    *postIdIndexLoad
+}
+
+// PostIndexVersions shares versions of post id indexes between API servers. An index is loaded again
+// when the version of its site is changed by another server. Versions are times of changes, so an index of
+// a newer version than asked is new enough.
+type PostIndexVersions interface {
+	PostIndexVersion(site string) int64
+	NewPostIndexVersion(site string)
+}
+
+// postIdIndexData is an index loaded at the version and the generation.
+type postIdIndexData struct {
+	version    int64
+	generation int64
+	loadedAt   time.Time
+	byTag      map[int][]int64
+	byAuthor   map[int64][]int64
+}
+
+func (d *postIdIndexData) isValid(version int64, generation int64) bool {
+	return d.version >= version && d.generation == generation && time.Since(d.loadedAt) < POST_ID_INDEX_TTL
+}
+
+// postIdIndexLoad is an index being loaded. Gets needing a new index wait for it.
+type postIdIndexLoad struct {
+	wg    sync.WaitGroup
+	index *postIdIndexData
+	err   error
+}
+
+// Get returns ids of posts by tag and by author. The returned maps must not be modified.
+func (i *PostIdIndex) Get(ctx context.Context) (map[int][]int64, map[int64][]int64, error) {
+	// the version is read before loading so that a change while loading is loaded next time
+	version := int64(0)
+	if i.versions != nil {
+		version = i.versions.PostIndexVersion(i.site)
+	}
+
+	for {
+		i.mutex.Lock()
+		generation := i.generation
+		if i.index != nil && i.index.isValid(version, generation) {
+			index := i.index
+			i.mutex.Unlock()
+			return index.byTag, index.byAuthor, nil
+		}
+
+		load := i.loading
+		if load == nil {
+			load = &postIdIndexLoad{index: &postIdIndexData{version: version, generation: generation}}
+			load.wg.Add(1)
+			i.loading = load
+			i.mutex.Unlock()
+			i.load(ctx, load)
+		} else {
+			i.mutex.Unlock()
+			load.wg.Wait()
+		}
+
+		if load.err != nil {
+			return nil, nil, load.err
+		}
+		// an index started loading before the change asked is not used, and another one is loaded
+		if load.index.version >= version && load.index.generation == generation {
+			return load.index.byTag, load.index.byAuthor, nil
+		}
+	}
+}
+
+// load loads the index and makes it the current index unless the index is invalidated while loading.
+func (i *PostIdIndex) load(ctx context.Context, load *postIdIndexLoad) {
+	defer load.wg.Done()
+
+	byTag, byAuthor, err := loadPostIds(ctx)
+
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	i.loading = nil
+	if err != nil {
+		load.err = err
+		return
+	}
+
+	load.index.byTag, load.index.byAuthor, load.index.loadedAt = byTag, byAuthor, time.Now()
+	if load.index.generation == i.generation {
+		i.index = load.index
+	}
+}
+
+func loadPostIds(ctx context.Context) (map[int][]int64, map[int64][]int64, error) {
+	store, err := GetStore(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	byTag, err := store.Posts.FindIdsByTerms(ctx, "post_tag")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	byAuthor, err := store.Posts.FindIdsByAuthors(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return byTag, byAuthor, nil
+}
+
+// Invalidate drops the index of this server and changes the shared version to drop the indexes of the others.
+func (i *PostIdIndex) Invalidate() {
+	i.mutex.Lock()
+	i.index = nil
+	i.generation++
+	i.mutex.Unlock()
+
+	if i.versions != nil {
+		i.versions.NewPostIndexVersion(i.site)
+	}
+}
+
+// popularTermIds returns terms having at least RANDOM_MIN_POSTS posts, ordered by number of posts and id.
+func popularTermIds(idsByTerm map[int][]int64) []int {
+	termIds := make([]int, 0)
+	for termId, postIds := range idsByTerm {
+		if len(postIds) >= RANDOM_MIN_POSTS {
+			termIds = append(termIds, termId)
+		}
+	}
+
+	sort.Slice(termIds, func(i, j int) bool {
+		numPosts1, numPosts2 := len(idsByTerm[termIds[i]]), len(idsByTerm[termIds[j]])
+		if numPosts1 != numPosts2 {
+			return numPosts1 > numPosts2
+		}
+		return termIds[i] < termIds[j]
+	})
+
+	if len(termIds) > RANDOM_MAX_TERMS {
+		termIds = termIds[:RANDOM_MAX_TERMS]
+	}
+	return termIds
+}
+
+// activeAuthorIds returns authors having at least RANDOM_MIN_POSTS posts, ordered by id.
+func activeAuthorIds(idsByAuthor map[int64][]int64) []int64 {
+	authorIds := make([]int64, 0)
+	for authorId, postIds := range idsByAuthor {
+		if len(postIds) >= RANDOM_MIN_POSTS {
+			authorIds = append(authorIds, authorId)
+		}
+	}
+
+	sort.Slice(authorIds, func(i, j int) bool {
+		return authorIds[i] < authorIds[j]
+	})
+	return authorIds
+}
+
+// sampleIndexes picks n indexes of [0, size) at random without duplicates.
+// The same rng state always picks the same indexes.
+func sampleIndexes(rng *rand.Rand, size int, n int) []int {
+	if n > size {
+		n = size
+	}
+
+	// partial Fisher-Yates shuffle
+	indexes := make([]int, size)
+	for i := range indexes {
+		indexes[i] = i
+	}
+	for i := 0; i < n; i++ {
+		j := i + rng.Intn(size-i)
+		indexes[i], indexes[j] = indexes[j], indexes[i]
+	}
+	return indexes[:n]
+}
+
+func samplePostIds(rng *rand.Rand, postIds []int64, n int) []int64 {
+	sampled := make([]int64, 0)
+	for _, index := range sampleIndexes(rng, len(postIds), n) {
+		sampled = append(sampled, postIds[index])
+	}
+	return sampled
+}
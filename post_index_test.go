@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// blockingPostRepository counts loads of post id indexes. A load waits for release if it is not nil.
+type blockingPostRepository struct {
+	PostRepository
+	loads   int32
+	release chan bool
+}
+
+func (r *blockingPostRepository) FindIdsByTerms(ctx context.Context, taxonomy string) (map[int][]int64, error) {
+	atomic.AddInt32(&r.loads, 1)
+	if r.release != nil {
+		<-r.release
+	}
+	return r.PostRepository.FindIdsByTerms(ctx, taxonomy)
+}
+
+func newTestIndexContext(t *testing.T) (context.Context, *MemoryStorage, *blockingPostRepository) {
+	storage, err := NewMemoryStorage("sample_data.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repository := &blockingPostRepository{PostRepository: &memoryPostRepository{storage}}
+	return withStore(context.Background(), &Store{Posts: repository}), storage, repository
+}
+
+func waitLoads(repository *blockingPostRepository, loads int32) {
+	for atomic.LoadInt32(&repository.loads) < loads {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPostIdIndexLoadsConcurrentGetsOnce(t *testing.T) {
+	ctx, _, repository := newTestIndexContext(t)
+	repository.release = make(chan bool)
+	index := &PostIdIndex{site: DEFAULT_SITE_NAME}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			byTag, byAuthor, err := index.Get(ctx)
+			if err != nil || len(byTag[20]) != 3 || len(byAuthor[2]) != 2 {
+				t.Errorf("unexpected index %v %v %v", byTag, byAuthor, err)
+			}
+		}()
+	}
+
+	waitLoads(repository, 1)
+	time.Sleep(10 * time.Millisecond)
+	close(repository.release)
+	wg.Wait()
+
+	if repository.loads != 1 {
+		t.Errorf("expected one load, got %v", repository.loads)
+	}
+	if _, _, err := index.Get(ctx); err != nil || repository.loads != 1 {
+		t.Errorf("expected the loaded index to be used, got %v loads", repository.loads)
+	}
+}
+
+func TestPostIdIndexReloadsVersionsOfOtherServers(t *testing.T) {
+	ctx, storage, repository := newTestIndexContext(t)
+	versions := newTestResponseCache(NewMemoryCache(100))
+	server1 := &PostIdIndex{site: DEFAULT_SITE_NAME, versions: versions}
+	server2 := &PostIdIndex{site: DEFAULT_SITE_NAME, versions: versions}
+
+	server1.Get(ctx)
+	server2.Get(ctx)
+	if repository.loads != 2 {
+		t.Fatalf("expected each server to load its index, got %v loads", repository.loads)
+	}
+
+	// the draft post 103 is published on server 1
+	storage.data.Posts[3].Status = "publish"
+	server1.Invalidate()
+
+	byTag, _, err := server2.Get(ctx)
+	if err != nil || len(byTag[21]) != 4 || repository.loads != 3 {
+		t.Fatalf("expected server 2 to load the published post, got %v with %v loads", byTag[21], repository.loads)
+	}
+	server2.Get(ctx)
+	server1.Get(ctx)
+	if repository.loads != 4 {
+		t.Errorf("expected each server to load the new version once, got %v loads", repository.loads)
+	}
+}
+
+func TestPostIdIndexInvalidatedWhileLoading(t *testing.T) {
+	ctx, _, repository := newTestIndexContext(t)
+	repository.release = make(chan bool, 2)
+	index := &PostIdIndex{site: DEFAULT_SITE_NAME}
+
+	done := make(chan bool)
+	go func() {
+		index.Get(ctx)
+		close(done)
+	}()
+
+	waitLoads(repository, 1)
+	index.Invalidate()
+	repository.release <- true
+	<-done
+
+	// the index loaded before the invalidation is not kept
+	repository.release <- true
+	if _, _, err := index.Get(ctx); err != nil || repository.loads != 2 {
+		t.Errorf("expected the index to be loaded again, got %v loads", repository.loads)
+	}
+}
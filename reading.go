@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"golang.org/x/net/html"
+)
+
+// Posts have their word count and reading time for "n min read". Korean is read by characters and other languages
+// by words, so wordCount is Hangul characters plus words of the others. Code in <pre> is not counted like
+// getDescriptionFromContents.
+
+const (
+	KOREAN_CHARS_PER_MINUTE = 500
+	WORDS_PER_MINUTE        = 200
+	// stats are cached by the hash of the content, so they need not be dropped when posts change.
+	READING_STATS_CACHE_ENTRIES = 10000
+	READING_STATS_CACHE_TTL     = 24 * time.Hour
+)
+
+type readingStats struct {
+	WordCount      int `json:"wordCount"`
+	ReadingMinutes int `json:"readingMinutes"`
+}
+
+var readingStatsCache = NewMemoryCache(READING_STATS_CACHE_ENTRIES)
+
+// readingShortcodes leave texts of shortcodes to be read. [code] is a <pre> block and media are dropped.
+var readingShortcodes = newReadingShortcodes()
+
+func newReadingShortcodes() *ShortcodeRegistry {
+	registry := NewShortcodeRegistry()
+	registry.Register("code", ShortcodeHandler{Render: renderCode, RawContent: true})
+	registry.Register("sourcecode", ShortcodeHandler{Render: renderCode, RawContent: true})
+	registry.Register("caption", ShortcodeHandler{Render: func(ctx context.Context, shortcode *Shortcode) (string, error) {
+		return shortcode.Content + " " + shortcode.Attr("caption"), nil
+	}})
+	for _, name := range []string{"gallery", "embed", "video", "audio"} {
+		registry.Register(name, ShortcodeHandler{Render: func(ctx context.Context, shortcode *Shortcode) (string, error) {
+			return "", nil
+		}})
+	}
+	return registry
+}
+
+// setReadingStats sets wordCount and readingMinutes of the content.
+func (p *Post) setReadingStats(ctx context.Context) {
+	hash := sha256.Sum256([]byte(p.Content))
+	key := hex.EncodeToString(hash[:])
+
+	var stats readingStats
+	has, err := readingStatsCache.Get(key, &stats)
+	if err != nil {
+		fmt.Println("ERROR reading stats cache get:", err.Error())
+	}
+	if !has {
+		stats = countReadingStats(readingShortcodes.Process(ctx, p.Content))
+		if err := readingStatsCache.Set(key, stats, nil, READING_STATS_CACHE_TTL); err != nil {
+			fmt.Println("ERROR reading stats cache set:", err.Error())
+		}
+	}
+
+	p.WordCount = stats.WordCount
+	p.ReadingMinutes = stats.ReadingMinutes
+}
+
+// countReadingStats counts texts of the HTML out of <pre>. Reading time is rounded up, and at least a minute
+// if there is anything to read.
+func countReadingStats(content string) readingStats {
+	htmlToken := html.NewTokenizer(strings.NewReader(content))
+	koreanChars := 0
+	words := 0
+	isPreTag := false
+	for {
+		tokenType := htmlToken.Next()
+		if tokenType == html.ErrorToken {
+			break
+		}
+		token := htmlToken.Token()
+		switch tokenType {
+		case html.StartTagToken:
+			if token.Data == "pre" {
+				isPreTag = true
+			}
+		case html.TextToken:
+			if !isPreTag {
+				chars, textWords := countText(token.Data)
+				koreanChars += chars
+				words += textWords
+			}
+		case html.EndTagToken:
+			if token.Data == "pre" {
+				isPreTag = false
+			}
+		}
+	}
+
+	// minutes of characters and words are added in characters to be rounded up once
+	readingChars := koreanChars*WORDS_PER_MINUTE + words*KOREAN_CHARS_PER_MINUTE
+	charsPerMinute := KOREAN_CHARS_PER_MINUTE * WORDS_PER_MINUTE
+	return readingStats{
+		WordCount:      koreanChars + words,
+		ReadingMinutes: (readingChars + charsPerMinute - 1) / charsPerMinute,
+	}
+}
+
+// countText counts Hangul characters and words of letters and digits of the others, e.g. "Go언어 1.10 설치" is
+// 4 characters and 3 words(Go, 1, 10).
+func countText(text string) (int, int) {
+	koreanChars := 0
+	words := 0
+	inWord := false
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Hangul, r):
+			koreanChars++
+			inWord = false
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r) || r == '\'' || r == '’':
+			if !inWord && r != '\'' && r != '’' {
+				words++
+				inWord = true
+			}
+		default:
+			inWord = false
+		}
+	}
+	return koreanChars, words
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// RedisCache is a cache shared by API servers through Redis(or a server speaking the Redis protocol).
+// A value is stored at <prefix>key:<key> and the keys having a tag are stored in the set <prefix>tag:<tag>.
+// The time of the last invalidation of a tag(unix nanoseconds) is stored at <prefix>invalidated:<tag>.
+type RedisCache struct {
+	client    *redis.Client
+	keyPrefix string
+	// tagTTL keeps a tag set at least as long as the values in it.
+	tagTTL time.Duration
+}
+
+func NewRedisCache(config RedisConfig, maxTTL time.Duration) (*RedisCache, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     config.Addr,
+		Password: config.Password,
+		DB:       config.DB,
+	})
+
+	if err := client.Ping().Err(); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return &RedisCache{client: client, keyPrefix: config.KeyPrefix, tagTTL: maxTTL}, nil
+}
+
+func (c *RedisCache) valueKey(key string) string {
+	return c.keyPrefix + "key:" + key
+}
+
+func (c *RedisCache) tagKey(tag string) string {
+	return c.keyPrefix + "tag:" + tag
+}
+
+func (c *RedisCache) invalidatedKey(tag string) string {
+	return c.keyPrefix + "invalidated:" + tag
+}
+
+func (c *RedisCache) Get(key string, value interface{}) (bool, error) {
+	data, err := c.client.Get(c.valueKey(key)).Bytes()
+	if err == redis.Nil {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, json.Unmarshal(data, value)
+}
+
+func (c *RedisCache) Set(key string, value interface{}, tags []string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.client.TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.Set(c.valueKey(key), data, ttl)
+		for _, tag := range tags {
+			pipe.SAdd(c.tagKey(tag), key)
+			pipe.Expire(c.tagKey(tag), c.tagSetTTL(ttl))
+		}
+		return nil
+	})
+	return err
+}
+
+// tagSetTTL keeps tag sets at least as long as the value.
+func (c *RedisCache) tagSetTTL(ttl time.Duration) time.Duration {
+	if ttl > c.tagTTL {
+		return ttl
+	}
+	return c.tagTTL
+}
+
+// invalidateScript drops values of tags atomically not to miss values added while dropping.
+// KEYS are pairs of a tag set and its invalidated key. ARGV are the prefix of value keys, the time of invalidation
+// and INVALIDATION_WINDOW in milliseconds. It returns the number of dropped values.
+var invalidateScript = redis.NewScript(`
+local removed = 0
+for i = 1, #KEYS, 2 do
+	redis.call("SET", KEYS[i + 1], ARGV[2], "PX", ARGV[3])
+	for _, key in ipairs(redis.call("SMEMBERS", KEYS[i])) do
+		removed = removed + redis.call("DEL", ARGV[1] .. key)
+	end
+	redis.call("DEL", KEYS[i])
+end
+return removed
+`)
+
+func (c *RedisCache) Invalidate(tags ...string) (int, error) {
+	if len(tags) == 0 {
+		return 0, nil
+	}
+
+	keys := make([]string, 0)
+	for _, tag := range tags {
+		keys = append(keys, c.tagKey(tag), c.invalidatedKey(tag))
+	}
+
+	removed, err := invalidateScript.Run(c.client, keys, c.valueKey(""), time.Now().UnixNano(),
+		int64(INVALIDATION_WINDOW/time.Millisecond)).Int()
+	if err != nil {
+		return 0, err
+	}
+	return removed, nil
+}
+
+// setUnlessInvalidatedScript checks invalidations of tags and sets the value atomically not to set a value
+// invalidated between them. KEYS are the value key and pairs of a tag set and its invalidated key.
+// ARGV are the value, its TTL and the TTL of tag sets in milliseconds, the key and since(unix nanoseconds).
+// It returns 1 if the value is set.
+var setUnlessInvalidatedScript = redis.NewScript(`
+for i = 2, #KEYS, 2 do
+	local invalidatedAt = redis.call("GET", KEYS[i + 1])
+	if invalidatedAt and tonumber(invalidatedAt) >= tonumber(ARGV[5]) then
+		return 0
+	end
+end
+redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+for i = 2, #KEYS, 2 do
+	redis.call("SADD", KEYS[i], ARGV[4])
+	redis.call("PEXPIRE", KEYS[i], ARGV[3])
+end
+return 1
+`)
+
+func (c *RedisCache) SetUnlessInvalidated(key string, value interface{}, tags []string, ttl time.Duration, since time.Time) (bool, error) {
+	if ttl <= 0 || time.Since(since) > INVALIDATION_WINDOW {
+		return false, nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return false, err
+	}
+
+	keys := []string{c.valueKey(key)}
+	for _, tag := range tags {
+		keys = append(keys, c.tagKey(tag), c.invalidatedKey(tag))
+	}
+
+	set, err := setUnlessInvalidatedScript.Run(c.client, keys, data, int64(ttl/time.Millisecond),
+		int64(c.tagSetTTL(ttl)/time.Millisecond), key, since.UnixNano()).Int()
+	if err != nil {
+		return false, err
+	}
+	return set == 1, nil
+}
+
+func (c *RedisCache) Close() error {
+	return c.client.Close()
+}
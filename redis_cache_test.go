@@ -0,0 +1,97 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+)
+
+func newTestRedisCache(t *testing.T) (*RedisCache, *miniredis.Miniredis) {
+	server, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cache, err := NewRedisCache(RedisConfig{Addr: server.Addr(), KeyPrefix: "test:"}, time.Minute)
+	if err != nil {
+		server.Close()
+		t.Fatal(err)
+	}
+
+	return cache, server
+}
+
+func TestRedisCacheGetSet(t *testing.T) {
+	cache, server := newTestRedisCache(t)
+	defer server.Close()
+	defer cache.Close()
+
+	var posts []Post
+	if has, err := cache.Get("recent", &posts); err != nil || has {
+		t.Fatalf("expected a miss, got has=%v err=%v", has, err)
+	}
+
+	termPosts := []TermPosts{{
+		Term:  Term{ID: 20, Taxonomy: "post_tag", Name: "Go", Slug: "go"},
+		Posts: []Post{{ID: 100, Title: "첫 번째 글", Author: Author{ID: 1, DisplayName: "Popit"}}},
+	}}
+	if err := cache.Set("tagPosts", termPosts, []string{termTag(20)}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	var cached []TermPosts
+	if has, err := cache.Get("tagPosts", &cached); err != nil || !has {
+		t.Fatalf("expected a hit, got has=%v err=%v", has, err)
+	}
+	if len(cached) != 1 || cached[0].Term.Slug != "go" || cached[0].Posts[0].Title != "첫 번째 글" || cached[0].Posts[0].Author.DisplayName != "Popit" {
+		t.Fatalf("unexpected value %+v", cached)
+	}
+
+	server.FastForward(2 * time.Minute)
+	if has, _ := cache.Get("tagPosts", &cached); has {
+		t.Fatal("expected the value to be expired")
+	}
+}
+
+func TestRedisCacheInvalidate(t *testing.T) {
+	cache, server := newTestRedisCache(t)
+	defer server.Close()
+	defer cache.Close()
+
+	authorPosts := AuthorPosts{Author: Author{ID: 2}, Posts: []Post{{ID: 101, AuthorID: 2}, {ID: 102, AuthorID: 2}}}
+	cache.Set("author:2", authorPosts, []string{authorTag(2), postTag(101), postTag(102)}, time.Minute)
+	cache.Set("post:101", Post{ID: 101}, []string{postTag(101)}, time.Minute)
+	cache.Set("post:104", Post{ID: 104}, []string{postTag(104)}, time.Minute)
+
+	removed, err := cache.Invalidate(postTag(101))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 2 {
+		t.Fatalf("expected 2 removed values, got %v", removed)
+	}
+
+	var post Post
+	for _, key := range []string{"author:2", "post:101"} {
+		if has, _ := cache.Get(key, &post); has {
+			t.Fatalf("expected %v to be invalidated", key)
+		}
+	}
+	if has, _ := cache.Get("post:104", &post); !has || post.ID != 104 {
+		t.Fatal("expected post:104 to be cached")
+	}
+
+	if removed, _ := cache.Invalidate(postTag(101), authorTag(3)); removed != 0 {
+		t.Fatalf("expected nothing to remove, got %v", removed)
+	}
+}
+
+// miniredis runs commands of a script one by one, so sets while invalidating are tested only for MemoryCache.
+func TestRedisCacheSetUnlessInvalidated(t *testing.T) {
+	cache, server := newTestRedisCache(t)
+	defer server.Close()
+	defer cache.Close()
+
+	testSetUnlessInvalidated(t, cache)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	REINDEX_QUEUE_SIZE  = 1000
+	REINDEX_RETRIES     = 3
+	REINDEX_RETRY_DELAY = 5 * time.Second
+	REINDEX_TIMEOUT     = 10 * time.Second
+)
+
+// Reindexer updates the search index of the site for a changed post: indexes the post again, or removes it if deleted.
+type Reindexer interface {
+	Reindex(site *Site, postId int64, deleted bool) error
+}
+
+// SearchIndexReindexer calls the index API of the search service(searchIndexApi) of the site:
+// PUT <searchIndexApi>/api/index/<postId> to index the post again, DELETE to remove it.
+// The search service reads the post from the database by itself.
+type SearchIndexReindexer struct {
+	client *http.Client
+}
+
+func NewSearchIndexReindexer() *SearchIndexReindexer {
+	return &SearchIndexReindexer{client: &http.Client{Timeout: REINDEX_TIMEOUT}}
+}
+
+func (r *SearchIndexReindexer) Reindex(site *Site, postId int64, deleted bool) error {
+	if len(site.Config.SearchIndexAPI) == 0 {
+		return nil
+	}
+
+	method := http.MethodPut
+	if deleted {
+		method = http.MethodDelete
+	}
+	indexAPI := fmt.Sprintf(`%v/api/index/%v`, site.Config.SearchIndexAPI, postId)
+
+	req, err := http.NewRequest(method, indexAPI, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// deleting a post not indexed is not a failure
+	if deleted && resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	if resp.StatusCode >= 300 || resp.StatusCode < 200 {
+		return fmt.Errorf("wrong http response status code: %v ==> %v %v", resp.StatusCode, method, indexAPI)
+	}
+	return nil
+}
+
+// reindexJob is a changed post to be reindexed.
+type reindexJob struct {
+	site    *Site
+	postId  int64
+	deleted bool
+}
+
+func (j reindexJob) key() string {
+	return fmt.Sprintf("%v|%v|%v", j.site.Name, j.postId, j.deleted)
+}
+
+// ReindexQueue passes changed posts to the Reindexer in background. Failed posts are retried REINDEX_RETRIES times.
+// A post waiting in the queue for the same change is not queued again.
+type ReindexQueue struct {
+	jobs      chan reindexJob
+	mutex     sync.Mutex
+	pending   map[string]bool
+	reindexer Reindexer
+}
+
+func NewReindexQueue(size int, reindexer Reindexer) *ReindexQueue {
+	queue := &ReindexQueue{
+		jobs:      make(chan reindexJob, size),
+		pending:   make(map[string]bool),
+		reindexer: reindexer,
+	}
+	go queue.run()
+	return queue
+}
+
+// Push queues the post. It returns false if the queue is full or the site has no searchIndexApi.
+func (q *ReindexQueue) Push(site *Site, postId int64, deleted bool) bool {
+	if len(site.Config.SearchIndexAPI) == 0 {
+		return false
+	}
+
+	job := reindexJob{site: site, postId: postId, deleted: deleted}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.pending[job.key()] {
+		return true
+	}
+
+	select {
+	case q.jobs <- job:
+		q.pending[job.key()] = true
+		return true
+	default:
+		return false
+	}
+}
+
+func (q *ReindexQueue) run() {
+	for job := range q.jobs {
+		q.mutex.Lock()
+		delete(q.pending, job.key())
+		q.mutex.Unlock()
+
+		for i := 1; i <= REINDEX_RETRIES; i++ {
+			err := q.reindexer.Reindex(job.site, job.postId, job.deleted)
+			if err == nil {
+				break
+			}
+
+			fmt.Println("ERROR reindex:", err.Error(), "site=", job.site.Name, ", post_id=", job.postId, ", try=", i)
+			if i < REINDEX_RETRIES {
+				time.Sleep(REINDEX_RETRY_DELAY)
+			}
+		}
+	}
+}
+
+func (q *ReindexQueue) Close() {
+	close(q.jobs)
+}
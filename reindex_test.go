@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSearchIndexReindexer(t *testing.T) {
+	tests := []struct {
+		postId   int64
+		deleted  bool
+		status   int
+		expected string
+		fails    bool
+	}{
+		{100, false, http.StatusOK, "PUT /api/index/100", false},
+		{100, true, http.StatusNoContent, "DELETE /api/index/100", false},
+		{999, true, http.StatusNotFound, "DELETE /api/index/999", false},
+		{999, false, http.StatusNotFound, "PUT /api/index/999", true},
+		{100, false, http.StatusInternalServerError, "PUT /api/index/100", true},
+	}
+
+	for _, test := range tests {
+		var requested string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requested = r.Method + " " + r.URL.Path
+			w.WriteHeader(test.status)
+		}))
+
+		site := &Site{Name: DEFAULT_SITE_NAME, Config: &Config{SearchIndexAPI: server.URL}}
+		err := NewSearchIndexReindexer().Reindex(site, test.postId, test.deleted)
+		server.Close()
+
+		if requested != test.expected {
+			t.Errorf("expected request %v, got %v", test.expected, requested)
+		}
+		if fails := err != nil; fails != test.fails {
+			t.Errorf("%v %v: expected failure %v, got %v", test.expected, test.status, test.fails, err)
+		}
+	}
+}
+
+func TestReindexQueueSkipsSitesWithoutSearchIndexApi(t *testing.T) {
+	reindexer := testReindexer{jobs: make(chan reindexJob, 10)}
+	queue := NewReindexQueue(REINDEX_QUEUE_SIZE, reindexer)
+	defer queue.Close()
+
+	if queue.Push(&Site{Name: DEFAULT_SITE_NAME, Config: &Config{}}, 100, false) {
+		t.Error("expected the post not to be queued")
+	}
+	if err := NewSearchIndexReindexer().Reindex(&Site{Name: DEFAULT_SITE_NAME, Config: &Config{}}, 100, false); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-xorm/xorm"
+)
+
+const REPLICA_PING_TIMEOUT = 3 * time.Second
+
+type replica struct {
+	index   int
+	engine  *xorm.Engine
+	healthy int32
+}
+
+func (r *replica) isHealthy() bool {
+	return atomic.LoadInt32(&r.healthy) == 1
+}
+
+// replicaSet selects a healthy read replica by round-robin.
+// Health of each replica is checked by ping every db.replicaCheckSec.
+type replicaSet struct {
+	replicas []*replica
+	next     uint32
+	interval time.Duration
+	done     chan struct{}
+}
+
+func newReplicaSet(dbConfig DBConfig) (*replicaSet, error) {
+	set := &replicaSet{
+		interval: time.Duration(dbConfig.ReplicaCheckSec) * time.Second,
+		done:     make(chan struct{}),
+	}
+
+	for i, dsn := range dbConfig.Replicas {
+		replicaConfig := dbConfig
+		replicaConfig.Conn = dsn
+
+		engine, err := newDBEngine(replicaConfig)
+		if err != nil {
+			set.closeEngines()
+			return nil, fmt.Errorf("replica[%v]: %v", i, err)
+		}
+		set.replicas = append(set.replicas, &replica{index: i, engine: engine})
+	}
+
+	set.checkAll()
+	go set.run()
+
+	return set, nil
+}
+
+func (s *replicaSet) run() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.checkAll()
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *replicaSet) checkAll() {
+	for _, each := range s.replicas {
+		s.check(each)
+	}
+}
+
+func (s *replicaSet) check(r *replica) {
+	ctx, cancel := context.WithTimeout(context.Background(), REPLICA_PING_TIMEOUT)
+	defer cancel()
+
+	healthy := int32(1)
+	if err := r.engine.PingContext(ctx); err != nil {
+		healthy = 0
+	}
+
+	if previous := atomic.SwapInt32(&r.healthy, healthy); previous != healthy {
+		if healthy == 1 {
+			fmt.Println("Replica", r.index, "is healthy")
+		} else {
+			fmt.Println("Replica", r.index, "is unhealthy")
+		}
+	}
+}
+
+// Next returns the next healthy replica or nil if there is no healthy replica.
+func (s *replicaSet) Next() *xorm.Engine {
+	numReplicas := len(s.replicas)
+	if numReplicas == 0 {
+		return nil
+	}
+
+	start := int(atomic.AddUint32(&s.next, 1))
+	for i := 0; i < numReplicas; i++ {
+		each := s.replicas[(start+i)%numReplicas]
+		if each.isHealthy() {
+			return each.engine
+		}
+	}
+
+	return nil
+}
+
+func (s *replicaSet) Close() {
+	close(s.done)
+	s.closeEngines()
+}
+
+func (s *replicaSet) closeEngines() {
+	for _, each := range s.replicas {
+		each.engine.Close()
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo"
+)
+
+// Repositories hide the storage(MySQL, memory, ...) from models and handlers.
+// A repository returns nil without error when a single record is not found.
+// Associations of posts are found for many posts at once and returned by post id to avoid a query per post.
+
+type PostOrder int
+
+const (
+	ORDER_BY_DATE_DESC PostOrder = iota
+	ORDER_BY_RANDOM
+)
+
+type PostRepository interface {
+	// FindOne finds a post(post_type = 'post') by id in one of the statuses.
+	FindOne(ctx context.Context, id int64, statuses ...string) (*Post, error)
+	// FindByName finds a published post by post_name(permalink).
+	FindByName(ctx context.Context, name string) (*Post, error)
+	FindByIds(ctx context.Context, ids []int64, postType string, postStatus string) ([]Post, error)
+	// FindRecent, FindByTerm and FindByAuthor return posts in post_date desc, ID desc order
+	// even if the range selects posts before a cursor.
+	FindRecent(ctx context.Context, postRange PostRange) ([]Post, error)
+	CountPublished(ctx context.Context) (int64, error)
+	FindByTerm(ctx context.Context, termId int, excludes []int, order PostOrder, postRange PostRange) ([]Post, error)
+	FindByAuthor(ctx context.Context, authorId int64, excludes []int, order PostOrder, postRange PostRange) ([]Post, error)
+	CountByTerm(ctx context.Context, termId int, excludes []int) (int64, error)
+	CountByAuthor(ctx context.Context, authorId int64, excludes []int) (int64, error)
+	// FindIdsByTerms returns ids of published posts by term of the taxonomy, in ascending order.
+	FindIdsByTerms(ctx context.Context, taxonomy string) (map[int][]int64, error)
+	// FindIdsByAuthors returns ids of published posts by author, in ascending order.
+	FindIdsByAuthors(ctx context.Context) (map[int64][]int64, error)
+	FindMetasByPosts(ctx context.Context, postIds []int64, keys ...string) (map[int64][]PostMeta, error)
+	UpdateFacebookLike(ctx context.Context, postId int64, likes int) error
+}
+
+type TermRepository interface {
+	FindByPosts(ctx context.Context, postIds []int64) (map[int64][]Term, error)
+	FindBySlug(ctx context.Context, slug string, taxonomy string) (*Term, error)
+	FindByIds(ctx context.Context, ids []int, taxonomy string) ([]Term, error)
+}
+
+type AuthorRepository interface {
+	FindOne(ctx context.Context, id int64) (*Author, error)
+	FindByIds(ctx context.Context, ids []int64) ([]Author, error)
+	FindByLoginName(ctx context.Context, loginName string) (*Author, error)
+}
+
+type SitePreferenceRepository interface {
+	FindByName(ctx context.Context, name string) (*SitePreference, error)
+	// FindByNames finds preferences of the names in one query. Missing names are left out.
+	FindByNames(ctx context.Context, names []string) ([]SitePreference, error)
+}
+
+type ExternalMetaRepository interface {
+	FindByPosts(ctx context.Context, postIds []int64) (map[int64][]PostExternalMeta, error)
+}
+
+type Store struct {
+	Posts           PostRepository
+	Terms           TermRepository
+	Authors         AuthorRepository
+	SitePreferences SitePreferenceRepository
+	ExternalMetas   ExternalMetaRepository
+}
+
+// Storage is the backend of a site. It opens a Store for each request.
+// A read only store may read from a replica, but writes always go to the primary database.
+type Storage interface {
+	Open(config *Config, readOnly bool) (store *Store, close func())
+	Close() error
+}
+
+var ErrNoStore = errors.New("Store is not exist")
+
+func NewStorage(dbConfig DBConfig) (Storage, error) {
+	switch dbConfig.Driver {
+	case "memory":
+		return NewMemoryStorage(dbConfig.Conn)
+	default:
+		engine, err := newDBEngine(dbConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		storage := &xormStorage{engine: engine, dialect: sqlDialects[dbConfig.Driver]}
+		if len(dbConfig.Replicas) > 0 {
+			if storage.replicas, err = newReplicaSet(dbConfig); err != nil {
+				engine.Close()
+				return nil, err
+			}
+		}
+		return storage, nil
+	}
+}
+
+// setStoreContext attaches a store of the site resolved by setSiteContext.
+// GET and HEAD requests are read only.
+func setStoreContext() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			req := ctx.Request()
+			site := GetSite(req.Context())
+			// GraphQL has no mutations, so its POST requests only read
+			readOnly := req.Method == http.MethodGet || req.Method == http.MethodHead || ctx.Path() == "/graphql"
+
+			store, closeStore := site.Storage.Open(site.Config, readOnly)
+			defer closeStore()
+
+			ctx.SetRequest(req.WithContext(withStore(req.Context(), store)))
+
+			return next(ctx)
+		}
+	}
+}
+
+func withStore(ctx context.Context, store *Store) context.Context {
+	return context.WithValue(ctx, "STORE", store)
+}
+
+func GetStore(ctx context.Context) (*Store, error) {
+	if store, ok := ctx.Value("STORE").(*Store); ok && store != nil {
+		return store, nil
+	}
+	return nil, ErrNoStore
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Shortcodes are WordPress macros in post contents: [name attr="value"] or [name attr="value"]content[/name].
+// Registered shortcodes are rendered by their handlers and the others are written back as they were.
+// [[name]] escapes a shortcode and is written as [name].
+
+// Shortcode is a shortcode found in a content.
+type Shortcode struct {
+	Name string
+	// Attrs are named attributes with lower case names. Positional are attributes without names, e.g. [embed url].
+	Attrs      map[string]string
+	Positional []string
+	// Content is the rendered content of an enclosing shortcode, or the raw content if the handler is RawContent.
+	Content   string
+	Enclosing bool
+}
+
+// Attr returns the first non empty attribute of the names.
+func (s *Shortcode) Attr(names ...string) string {
+	for _, name := range names {
+		if value := s.Attrs[name]; len(value) > 0 {
+			return value
+		}
+	}
+	return ""
+}
+
+type ShortcodeHandler struct {
+	// Render returns the text replacing the shortcode. The shortcode is written back as it was on an error.
+	Render func(ctx context.Context, shortcode *Shortcode) (string, error)
+	// RawContent leaves shortcodes in the content unparsed, e.g. [code].
+	RawContent bool
+	// closeTag finds the closing tag of RawContent, compiled by Register.
+	closeTag *regexp.Regexp
+}
+
+type ShortcodeRegistry struct {
+	handlers map[string]ShortcodeHandler
+}
+
+func NewShortcodeRegistry() *ShortcodeRegistry {
+	return &ShortcodeRegistry{handlers: make(map[string]ShortcodeHandler)}
+}
+
+func (r *ShortcodeRegistry) Register(name string, handler ShortcodeHandler) {
+	name = strings.ToLower(name)
+	if handler.RawContent {
+		handler.closeTag = regexp.MustCompile(`(?i)\[/` + regexp.QuoteMeta(name) + `\]`)
+	}
+	r.handlers[name] = handler
+}
+
+// Process renders the shortcodes in the content.
+func (r *ShortcodeRegistry) Process(ctx context.Context, content string) string {
+	if !strings.Contains(content, "[") {
+		return content
+	}
+
+	var result strings.Builder
+	for _, node := range r.parse(content) {
+		result.WriteString(r.render(ctx, node))
+	}
+	return result.String()
+}
+
+func (r *ShortcodeRegistry) render(ctx context.Context, node *shortcodeNode) string {
+	if node.tag == nil {
+		return node.text
+	}
+
+	var content strings.Builder
+	for _, child := range node.children {
+		content.WriteString(r.render(ctx, child))
+	}
+
+	original := node.tag.raw + content.String() + node.closeRaw
+	handler, has := r.handlers[node.tag.name]
+	if !has {
+		return original
+	}
+
+	shortcode := &Shortcode{
+		Name:      node.tag.name,
+		Attrs:     make(map[string]string),
+		Content:   content.String(),
+		Enclosing: len(node.closeRaw) > 0,
+	}
+	parseShortcodeAttrs(node.tag.attrs, shortcode)
+
+	rendered, err := handler.Render(ctx, shortcode)
+	if err != nil {
+		fmt.Println("ERROR shortcode", node.tag.raw, ":", err.Error())
+		return original
+	}
+	return rendered
+}
+
+// shortcodeTag is an opening or closing tag.
+type shortcodeTag struct {
+	name    string
+	attrs   string
+	raw     string
+	closing bool
+	// selfClosing is [name /].
+	selfClosing bool
+}
+
+// shortcodeNode is a text or a shortcode with its content.
+type shortcodeNode struct {
+	text     string
+	tag      *shortcodeTag
+	children []*shortcodeNode
+	// closeRaw is the closing tag of an enclosing shortcode.
+	closeRaw string
+}
+
+var shortcodeNamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]*`)
+
+// readShortcodeTag reads a tag at the start of s("[name ...]" or "[/name]") and returns its length.
+// Attributes end at the first "]" like WordPress.
+func readShortcodeTag(s string) (*shortcodeTag, int) {
+	if !strings.HasPrefix(s, "[") {
+		return nil, 0
+	}
+
+	tag := &shortcodeTag{}
+	rest := s[1:]
+	if strings.HasPrefix(rest, "/") {
+		tag.closing = true
+		rest = rest[1:]
+	}
+
+	name := shortcodeNamePattern.FindString(rest)
+	if len(name) == 0 {
+		return nil, 0
+	}
+	rest = rest[len(name):]
+
+	end := strings.Index(rest, "]")
+	if end < 0 {
+		return nil, 0
+	}
+	attrs := rest[:end]
+	if first, _ := utf8.DecodeRuneInString(attrs); len(attrs) > 0 && !unicode.IsSpace(first) && first != '/' {
+		// [name-like-text...] is not a tag. Spaces include no-break spaces of the WordPress editor.
+		return nil, 0
+	}
+	if tag.closing && len(strings.TrimSpace(attrs)) > 0 {
+		return nil, 0
+	}
+
+	trimmed := strings.TrimSpace(attrs)
+	if strings.HasSuffix(trimmed, "/") {
+		tag.selfClosing = true
+		trimmed = strings.TrimSuffix(trimmed, "/")
+	}
+
+	length := 1 + len(s[1:]) - len(rest) + end + 1
+	tag.name = strings.ToLower(name)
+	tag.attrs = strings.TrimSpace(trimmed)
+	tag.raw = s[:length]
+	return tag, length
+}
+
+// parse builds the shortcode tree of the content. A closing tag closes the nearest open tag of its name,
+// and open tags without their closing tags are self closing. Closing tags without open tags are texts.
+func (r *ShortcodeRegistry) parse(content string) []*shortcodeNode {
+	root := &shortcodeNode{tag: &shortcodeTag{}}
+	stack := []*shortcodeNode{root}
+
+	addText := func(text string) {
+		top := stack[len(stack)-1]
+		if last := len(top.children) - 1; last >= 0 && top.children[last].tag == nil {
+			top.children[last].text += text
+			return
+		}
+		top.children = append(top.children, &shortcodeNode{text: text})
+	}
+
+	// unclose makes the open tag on the top of the stack self closing.
+	unclose := func() {
+		node := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		parent := stack[len(stack)-1]
+		parent.children = append(parent.children, node.children...)
+		node.children = nil
+	}
+
+	pos := 0
+	for pos < len(content) {
+		next := strings.Index(content[pos:], "[")
+		if next < 0 {
+			addText(content[pos:])
+			break
+		}
+		addText(content[pos : pos+next])
+		pos += next
+
+		// [[name]] is an escaped shortcode
+		if strings.HasPrefix(content[pos:], "[[") {
+			if tag, length := readShortcodeTag(content[pos+1:]); tag != nil && strings.HasPrefix(content[pos+1+length:], "]") {
+				addText(tag.raw)
+				pos += length + 2
+				continue
+			}
+		}
+
+		tag, length := readShortcodeTag(content[pos:])
+		if tag == nil {
+			addText("[")
+			pos++
+			continue
+		}
+		pos += length
+
+		top := stack[len(stack)-1]
+		node := &shortcodeNode{tag: tag}
+		switch {
+		case tag.closing:
+			open := len(stack) - 1
+			for open > 0 && stack[open].tag.name != tag.name {
+				open--
+			}
+			if open == 0 {
+				addText(tag.raw)
+				continue
+			}
+			for len(stack)-1 > open {
+				unclose()
+			}
+			stack[open].closeRaw = tag.raw
+			stack = stack[:open]
+		case tag.selfClosing:
+			top.children = append(top.children, node)
+		case r.handlers[tag.name].RawContent:
+			top.children = append(top.children, node)
+			if end := r.handlers[tag.name].closeTag.FindStringIndex(content[pos:]); end != nil {
+				node.children = []*shortcodeNode{{text: content[pos : pos+end[0]]}}
+				node.closeRaw = content[pos+end[0] : pos+end[1]]
+				pos += end[1]
+			}
+		default:
+			top.children = append(top.children, node)
+			stack = append(stack, node)
+		}
+	}
+
+	for len(stack) > 1 {
+		unclose()
+	}
+	return root.children
+}
+
+var shortcodeAttrPattern = regexp.MustCompile(`([\w-]+)\s*=\s*"([^"]*)"(?:\s|$)|([\w-]+)\s*=\s*'([^']*)'(?:\s|$)|([\w-]+)\s*=\s*([^\s'"]+)(?:\s|$)|"([^"]*)"(?:\s|$)|'([^']*)'(?:\s|$)|(\S+)(?:\s|$)`)
+
+// parseShortcodeAttrs parses attributes like shortcode_parse_atts of WordPress.
+// Curly quotes and no-break spaces made by the WordPress editor are read as straight quotes and spaces.
+func parseShortcodeAttrs(attrs string, shortcode *Shortcode) {
+	attrs = strings.NewReplacer("\u201c", `"`, "\u201d", `"`, "\u2033", `"`, "\u2018", "'", "\u2019", "'", "\u2032", "'", "\u00a0", " ").Replace(attrs)
+
+	for _, match := range shortcodeAttrPattern.FindAllStringSubmatch(attrs, -1) {
+		switch {
+		case len(match[1]) > 0:
+			shortcode.Attrs[strings.ToLower(match[1])] = match[2]
+		case len(match[3]) > 0:
+			shortcode.Attrs[strings.ToLower(match[3])] = match[4]
+		case len(match[5]) > 0:
+			shortcode.Attrs[strings.ToLower(match[5])] = match[6]
+		case len(match[7]) > 0 || strings.HasPrefix(match[0], `""`):
+			shortcode.Positional = append(shortcode.Positional, match[7])
+		case len(match[8]) > 0 || strings.HasPrefix(match[0], "''"):
+			shortcode.Positional = append(shortcode.Positional, match[8])
+		default:
+			shortcode.Positional = append(shortcode.Positional, match[9])
+		}
+	}
+}
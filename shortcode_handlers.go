@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// postShortcodes render shortcodes of post contents in the html format. Clients get HTML instead of [gallery],
+// [caption], [embed], [video], [audio] and [code].
+var postShortcodes = newPostShortcodes()
+
+func newPostShortcodes() *ShortcodeRegistry {
+	registry := NewShortcodeRegistry()
+	registry.Register("gallery", ShortcodeHandler{Render: renderGalleryHTML})
+	registry.Register("caption", ShortcodeHandler{Render: renderCaption})
+	registry.Register("embed", ShortcodeHandler{Render: renderEmbed})
+	registry.Register("video", ShortcodeHandler{Render: renderMedia("video", map[string]string{
+		"mp4": "video/mp4", "m4v": "video/mp4", "webm": "video/webm", "ogv": "video/ogg",
+	})})
+	registry.Register("audio", ShortcodeHandler{Render: renderMedia("audio", map[string]string{
+		"mp3": "audio/mpeg", "m4a": "audio/mp4", "ogg": "audio/ogg", "wav": "audio/wav",
+	})})
+	registry.Register("code", ShortcodeHandler{Render: renderCode, RawContent: true})
+	registry.Register("sourcecode", ShortcodeHandler{Render: renderCode, RawContent: true})
+	return registry
+}
+
+// galleryImages finds the attachments of the ids in the order of ids.
+func galleryImages(ctx context.Context, ids []int64) ([]Post, error) {
+	// attachments need no associations
+	childPosts, err := Post{}.GetPostsByIds(withFields(ctx, FieldSet{"id": nil}), ids, "attachment")
+	if err != nil {
+		return nil, err
+	}
+
+	images := make([]Post, 0)
+	for _, eachId := range ids {
+		for _, eachChildPost := range childPosts {
+			if eachId == eachChildPost.ID {
+				images = append(images, eachChildPost)
+				break
+			}
+		}
+	}
+	return images, nil
+}
+
+// renderGalleryHTML renders the gallery as figures of the images with their excerpts as captions.
+func renderGalleryHTML(ctx context.Context, shortcode *Shortcode) (string, error) {
+	ids := make([]int64, 0)
+	for _, id := range strings.Split(shortcode.Attrs["ids"], ",") {
+		i64, err := strconv.ParseInt(strings.TrimSpace(id), 10, 32)
+		if err != nil {
+			return "", fmt.Errorf("gallery id error: %v", err)
+		}
+		ids = append(ids, i64)
+	}
+
+	childPosts, err := galleryImages(ctx, ids)
+	if err != nil {
+		return "", err
+	}
+
+	columns, err := strconv.Atoi(shortcode.Attr("columns"))
+	if err != nil || columns < 1 {
+		columns = 3
+	}
+	size := shortcode.Attr("size")
+	if len(size) == 0 {
+		size = "thumbnail"
+	}
+
+	var gallery strings.Builder
+	gallery.WriteString(fmt.Sprintf(`<div class="gallery gallery-columns-%v gallery-size-%v">`, columns, html.EscapeString(size)))
+	for _, image := range childPosts {
+		caption := html.EscapeString(strings.TrimSpace(image.PostExcerpt))
+		gallery.WriteString(fmt.Sprintf(`<figure class="gallery-item"><img src="%v" alt="%v" />`, html.EscapeString(image.Guid), caption))
+		if len(caption) > 0 {
+			gallery.WriteString(fmt.Sprintf(`<figcaption class="gallery-caption">%v</figcaption>`, caption))
+		}
+		gallery.WriteString("</figure>")
+	}
+	gallery.WriteString("</div>")
+	return gallery.String(), nil
+}
+
+var captionImagePattern = regexp.MustCompile(`(?is)^\s*((?:<a\s[^>]+>\s*)?<img\s[^>]+>(?:\s*</a>)?)(.*)$`)
+
+// renderCaption renders [caption id="attachment_1" align="aligncenter" width="300"]<img ...> text[/caption] as a figure.
+func renderCaption(ctx context.Context, shortcode *Shortcode) (string, error) {
+	content := shortcode.Content
+	caption := shortcode.Attr("caption")
+	if match := captionImagePattern.FindStringSubmatch(content); match != nil {
+		content = match[1]
+		if len(caption) == 0 {
+			caption = strings.TrimSpace(match[2])
+		}
+	}
+
+	attrs := ""
+	if id := shortcode.Attr("id"); len(id) > 0 {
+		attrs += fmt.Sprintf(` id="%v"`, html.EscapeString(id))
+	}
+	attrs += fmt.Sprintf(` class="%v"`, html.EscapeString(strings.TrimSpace("wp-caption "+shortcode.Attr("align"))))
+	if width, err := strconv.Atoi(shortcode.Attr("width")); err == nil && width > 0 {
+		attrs += fmt.Sprintf(` style="width: %vpx"`, width)
+	}
+
+	if len(caption) == 0 {
+		return fmt.Sprintf("<figure%v>%v</figure>", attrs, content), nil
+	}
+	return fmt.Sprintf(`<figure%v>%v<figcaption class="wp-caption-text">%v</figcaption></figure>`, attrs, content, caption), nil
+}
+
+var (
+	youTubePattern = regexp.MustCompile(`^https?://(?:www\.|m\.)?(?:youtube\.com/watch\?(?:.*&)?v=|youtu\.be/)([\w-]+)`)
+	vimeoPattern   = regexp.MustCompile(`^https?://(?:www\.)?vimeo\.com/(\d+)`)
+)
+
+// renderEmbed renders YouTube and Vimeo URLs as players and other URLs as links.
+func renderEmbed(ctx context.Context, shortcode *Shortcode) (string, error) {
+	link := strings.TrimSpace(html.UnescapeString(shortcode.Content))
+	if len(link) == 0 {
+		link = shortcode.Attr("src", "url")
+	}
+	if err := checkMediaURL(link); err != nil {
+		return "", err
+	}
+
+	size := ""
+	if width, err := strconv.Atoi(shortcode.Attr("width")); err == nil {
+		size += fmt.Sprintf(` width="%v"`, width)
+	}
+	if height, err := strconv.Atoi(shortcode.Attr("height")); err == nil {
+		size += fmt.Sprintf(` height="%v"`, height)
+	}
+
+	if match := youTubePattern.FindStringSubmatch(link); match != nil {
+		return fmt.Sprintf(`<iframe%v src="https://www.youtube.com/embed/%v" frameborder="0" allowfullscreen></iframe>`, size, match[1]), nil
+	}
+	if match := vimeoPattern.FindStringSubmatch(link); match != nil {
+		return fmt.Sprintf(`<iframe%v src="https://player.vimeo.com/video/%v" frameborder="0" allowfullscreen></iframe>`, size, match[1]), nil
+	}
+
+	escaped := html.EscapeString(link)
+	return fmt.Sprintf(`<a href="%v">%v</a>`, escaped, escaped), nil
+}
+
+// renderMedia renders [video] and [audio] as elements with a source of src and each format attribute.
+// types are MIME types by format attribute(mp4="..."), which are also extensions of src.
+func renderMedia(element string, types map[string]string) func(ctx context.Context, shortcode *Shortcode) (string, error) {
+	formats := make([]string, 0)
+	for format := range types {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+
+	return func(ctx context.Context, shortcode *Shortcode) (string, error) {
+		sources := make([]string, 0)
+		addSource := func(src string, mimeType string) error {
+			if err := checkMediaURL(src); err != nil {
+				return err
+			}
+			source := fmt.Sprintf(`<source src="%v"`, html.EscapeString(src))
+			if len(mimeType) > 0 {
+				source += fmt.Sprintf(` type="%v"`, mimeType)
+			}
+			sources = append(sources, source+" />")
+			return nil
+		}
+
+		if src := shortcode.Attr("src"); len(src) > 0 {
+			extension := strings.TrimPrefix(strings.ToLower(path.Ext(strings.SplitN(src, "?", 2)[0])), ".")
+			if err := addSource(src, types[extension]); err != nil {
+				return "", err
+			}
+		}
+		for _, format := range formats {
+			if src := shortcode.Attr(format); len(src) > 0 {
+				if err := addSource(src, types[format]); err != nil {
+					return "", err
+				}
+			}
+		}
+		if len(sources) == 0 {
+			return "", errors.New("no source")
+		}
+
+		attrs := " controls"
+		for _, name := range []string{"width", "height"} {
+			if value, err := strconv.Atoi(shortcode.Attr(name)); err == nil {
+				attrs += fmt.Sprintf(` %v="%v"`, name, value)
+			}
+		}
+		if poster := shortcode.Attr("poster"); checkMediaURL(poster) == nil {
+			attrs += fmt.Sprintf(` poster="%v"`, html.EscapeString(poster))
+		}
+		if preload := shortcode.Attr("preload"); preload == "none" || preload == "metadata" || preload == "auto" {
+			attrs += fmt.Sprintf(` preload="%v"`, preload)
+		}
+		for _, name := range []string{"loop", "autoplay", "muted"} {
+			if value := shortcode.Attr(name); value == "on" || value == "1" || value == "true" {
+				attrs += " " + name
+			}
+		}
+
+		return fmt.Sprintf("<%v%v>%v</%v>", element, attrs, strings.Join(sources, ""), element), nil
+	}
+}
+
+// renderCode renders [code language="go"]...[/code] as an escaped code block. Contents escaped by the editor are
+// unescaped first not to be escaped twice.
+func renderCode(ctx context.Context, shortcode *Shortcode) (string, error) {
+	code := html.EscapeString(html.UnescapeString(strings.Trim(shortcode.Content, "\r\n")))
+
+	language := shortcode.Attr("language", "lang")
+	if len(language) == 0 && len(shortcode.Positional) > 0 {
+		language = shortcode.Positional[0]
+	}
+	if len(language) == 0 {
+		return fmt.Sprintf("<pre><code>%v</code></pre>", code), nil
+	}
+	return fmt.Sprintf(`<pre><code class="language-%v">%v</code></pre>`, html.EscapeString(strings.ToLower(language)), code), nil
+}
+
+// checkMediaURL allows only http and https URLs in src attributes.
+func checkMediaURL(link string) error {
+	parsed, err := url.Parse(link)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || len(parsed.Host) == 0 {
+		return fmt.Errorf("wrong URL[%v]", link)
+	}
+	return nil
+}
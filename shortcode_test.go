@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// newTestShortcodes registers shortcodes rendering what they got: [b] and [i] wrap their contents, [attrs] lists
+// its attributes, [raw] gets its content unparsed and [fail] always fails.
+func newTestShortcodes() *ShortcodeRegistry {
+	wrap := func(tag string) ShortcodeHandler {
+		return ShortcodeHandler{Render: func(ctx context.Context, shortcode *Shortcode) (string, error) {
+			return fmt.Sprintf("<%v>%v</%v>", tag, shortcode.Content, tag), nil
+		}}
+	}
+
+	registry := NewShortcodeRegistry()
+	registry.Register("b", wrap("b"))
+	registry.Register("I", wrap("i"))
+	registry.Register("attrs", ShortcodeHandler{Render: func(ctx context.Context, shortcode *Shortcode) (string, error) {
+		attrs := make([]string, 0)
+		for name, value := range shortcode.Attrs {
+			attrs = append(attrs, name+"="+value)
+		}
+		sort.Strings(attrs)
+		return fmt.Sprintf("{%v|%v|%v}", strings.Join(attrs, ";"), strings.Join(shortcode.Positional, ";"), shortcode.Enclosing), nil
+	}})
+	registry.Register("raw", ShortcodeHandler{Render: func(ctx context.Context, shortcode *Shortcode) (string, error) {
+		return "<raw>" + shortcode.Content + "</raw>", nil
+	}, RawContent: true})
+	registry.Register("fail", ShortcodeHandler{Render: func(ctx context.Context, shortcode *Shortcode) (string, error) {
+		return "", errors.New("fail")
+	}})
+	return registry
+}
+
+func TestShortcodeRegistryProcess(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		result  string
+	}{
+		{"no shortcodes", "<p>text</p>", "<p>text</p>"},
+		{"enclosing", "a [b]bold[/b] c", "a <b>bold</b> c"},
+		{"nested", "[b]x [i]y[/i] z[/b]", "<b>x <i>y</i> z</b>"},
+		{"names are case insensitive", "[B]x[/b] [i]y[/I]", "<b>x</b> <i>y</i>"},
+		{"self closing", "[attrs a=1 /]x", "{a=1||false}x"},
+		{"enclosing flag", "[attrs]x[/attrs]", "{||true}"},
+		{"escaped", "[[b]] and [[attrs a=\"1\"]]", "[b] and [attrs a=\"1\"]"},
+		{"escaped inside a shortcode", "[b][[i]][/b]", "<b>[i]</b>"},
+		{"unknown", `[unknown a="x y"]in [b]side[/b][/unknown]`, `[unknown a="x y"]in <b>side</b>[/unknown]`},
+		{"not tags", "arr[0] [b-like] [ b] [/] [", "arr[0] [b-like] [ b] [/] ["},
+		{"quoted attributes", `[attrs a="x y" b='z w' c=bare "pos one" two]`, "{a=x y;b=z w;c=bare|pos one;two|false}"},
+		{"curly quotes", "[attrs a=“x y” b=‘z’ “pos”]", "{a=x y;b=z|pos|false}"},
+		{"no-break spaces", "[attrs\u00a0a=\"1\"\u00a0b=2]", "{a=1;b=2||false}"},
+		{"attribute names are lower case", `[attrs Size="M"]`, "{size=M||false}"},
+		{"unclosed", "[b]x", "<b></b>x"},
+		{"unclosed inside enclosing", "[b][i]x[/b]", "<b><i></i>x</b>"},
+		{"stray closing", "x[/b] [/i]y", "x[/b] [/i]y"},
+		{"closing an outer shortcode", "[b]x[/i]y[/b]", "<b>x[/i]y</b>"},
+		{"raw content", "[raw][b]x[/b] [[i]][/RAW] [b]y[/b]", "<raw>[b]x[/b] [[i]]</raw> <b>y</b>"},
+		{"unclosed raw", "[raw][b]x[/b]", "<raw></raw><b>x</b>"},
+		{"failed", "[fail a=1]x [b]y[/b][/fail]", "[fail a=1]x <b>y</b>[/fail]"},
+	}
+
+	registry := newTestShortcodes()
+	for _, test := range tests {
+		if result := registry.Process(context.Background(), test.content); result != test.result {
+			t.Errorf("%v: expected %q, got %q", test.name, test.result, result)
+		}
+	}
+}
+
+func TestRenderCode(t *testing.T) {
+	tests := []struct {
+		content string
+		result  string
+	}{
+		{"[code]a &lt; b[/code]", "<pre><code>a &lt; b</code></pre>"},
+		{"[code language=\"Go\"]\nif a < b {}\n[/code]", `<pre><code class="language-go">if a &lt; b {}</code></pre>`},
+		{"[sourcecode js]x[/sourcecode]", `<pre><code class="language-js">x</code></pre>`},
+		{"[code][caption]x[/caption][/code]", "<pre><code>[caption]x[/caption]</code></pre>"},
+	}
+
+	for _, test := range tests {
+		if result := postShortcodes.Process(context.Background(), test.content); result != test.result {
+			t.Errorf("%q: expected %q, got %q", test.content, test.result, result)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/labstack/echo"
+)
+
+const DEFAULT_SITE_NAME = "default"
+
+// Site is a WordPress blog served by this API. Each site has its own database, table prefix and public url.
+type Site struct {
+	Name      string
+	Hosts     []string
+	Config    *Config
+	Storage   Storage
+	PostIndex *PostIdIndex
+}
+
+type SiteRegistry struct {
+	sites       []*Site
+	byName      map[string]*Site
+	byHost      map[string]*Site
+	defaultSite *Site
+	storages    []Storage
+	shared      map[string]Storage
+}
+
+// NewSiteRegistry opens storages for all sites in config.
+// If no site is configured, the top level config is served as the only site for every host.
+func NewSiteRegistry(config *Config) (*SiteRegistry, error) {
+	registry := &SiteRegistry{
+		byName: make(map[string]*Site),
+		byHost: make(map[string]*Site),
+		shared: make(map[string]Storage),
+	}
+
+	siteConfigs := config.Sites
+	if len(siteConfigs) == 0 {
+		siteConfigs = []SiteConfig{{Name: DEFAULT_SITE_NAME}}
+	}
+
+	for _, siteConfig := range siteConfigs {
+		site := &Site{
+			Name:      siteConfig.Name,
+			Hosts:     siteConfig.Hosts,
+			Config:    config.ForSite(siteConfig),
+			PostIndex: &PostIdIndex{site: siteConfig.Name},
+		}
+
+		storage, err := registry.getStorage(site.Config.DB)
+		if err != nil {
+			registry.Close()
+			return nil, fmt.Errorf("site %v: %v", site.Name, err)
+		}
+		site.Storage = storage
+
+		registry.sites = append(registry.sites, site)
+		registry.byName[site.Name] = site
+		for _, host := range site.Hosts {
+			registry.byHost[host] = site
+		}
+	}
+
+	if len(config.DefaultSite) > 0 {
+		registry.defaultSite = registry.byName[config.DefaultSite]
+	} else if len(registry.sites) == 1 {
+		registry.defaultSite = registry.sites[0]
+	}
+
+	return registry, nil
+}
+
+// SharePostIndexes makes post id indexes of all sites invalidated together with the other API servers.
+func (r *SiteRegistry) SharePostIndexes(versions PostIndexVersions) {
+	for _, site := range r.sites {
+		site.PostIndex.versions = versions
+	}
+}
+
+// getStorage shares one database engine between sites on the same database.
+// Memory storages are not shared because they have no table prefix.
+func (r *SiteRegistry) getStorage(dbConfig DBConfig) (Storage, error) {
+	shareable := dbConfig.Driver != "memory"
+	key := dbConfig.Driver + "|" + dbConfig.Conn + "|" + strings.Join(dbConfig.Replicas, ",")
+
+	if storage, has := r.shared[key]; has && shareable {
+		return storage, nil
+	}
+
+	storage, err := NewStorage(dbConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	r.storages = append(r.storages, storage)
+	if shareable {
+		r.shared[key] = storage
+	}
+
+	return storage, nil
+}
+
+func (r *SiteRegistry) Sites() []*Site {
+	return r.sites
+}
+
+// Resolve finds the site by explicit name first and then by host.
+func (r *SiteRegistry) Resolve(host string, name string) (*Site, error) {
+	if len(name) > 0 {
+		if site, has := r.byName[name]; has {
+			return site, nil
+		}
+		return nil, fmt.Errorf("Unknown site [%v]", name)
+	}
+
+	host = strings.ToLower(host)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	if site, has := r.byHost[host]; has {
+		return site, nil
+	}
+
+	if r.defaultSite != nil {
+		return r.defaultSite, nil
+	}
+
+	return nil, fmt.Errorf("Unknown site host [%v]", host)
+}
+
+func (r *SiteRegistry) Close() {
+	for _, storage := range r.storages {
+		storage.Close()
+	}
+}
+
+// setSiteContext resolves the site by "site" query parameter or Host header
+// and attaches the site and its config to the request context.
+func setSiteContext(registry *SiteRegistry) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			req := ctx.Request()
+
+			site, err := registry.Resolve(req.Host, ctx.QueryParam("site"))
+			if err != nil {
+				return NewApiError(SITE_NOT_FOUND, err.Error())
+			}
+
+			ctx.SetRequest(req.WithContext(withSite(req.Context(), site)))
+
+			return next(ctx)
+		}
+	}
+}
+
+func withSite(ctx context.Context, site *Site) context.Context {
+	ctx = context.WithValue(ctx, "SITE", site)
+	return context.WithValue(ctx, "CONFIG", site.Config)
+}
+
+func GetSite(ctx context.Context) *Site {
+	v := ctx.Value("SITE")
+	if v == nil {
+		panic("Site is not exist")
+	}
+	if site, ok := v.(*Site); ok {
+		return site
+	}
+	panic("Site is not exist")
+}
@@ -15,17 +15,29 @@ func (SitePreference) TableName() string {
 }
 
 func (SitePreference)GetByName(ctx context.Context, name string) (*SitePreference, error) {
-	sitePref := SitePreference{Name: name}
+	store, err := GetStore(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-	has, err := GetDBConn(ctx).Get(&sitePref)
+	return store.SitePreferences.FindByName(ctx, name)
+}
 
+// GetByNames finds preferences of the names by their names.
+func (SitePreference)GetByNames(ctx context.Context, names []string) (map[string]SitePreference, error) {
+	store, err := GetStore(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	if !has {
-		return nil, nil
+	sitePrefs, err := store.SitePreferences.FindByNames(ctx, names)
+	if err != nil {
+		return nil, err
 	}
 
-	return &sitePref, nil
-}
\ No newline at end of file
+	sitePrefsByName := make(map[string]SitePreference)
+	for _, each := range sitePrefs {
+		sitePrefsByName[each.Name] = each
+	}
+	return sitePrefsByName, nil
+}
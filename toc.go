@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Single posts have a table of contents of their h2 to h4 headings. The headings get id attributes as anchors of the
+// entries, made from their titles like GitHub(lower case, spaces to "-", punctuations dropped). Korean and other
+// letters are kept, and anchors used twice get "-1", "-2", ... suffixes in the order of the headings.
+
+type TocEntry struct {
+	Level    int        `json:"level"`
+	Title    string     `json:"title"`
+	Anchor   string     `json:"anchor"`
+	Children []TocEntry `json:"children"`
+}
+
+var (
+	tocHeadingPattern = regexp.MustCompile(`(?is)<h([2-4])(\s[^>]*)?>(.*?)</h([2-4])\s*>`)
+	tocIdPattern      = regexp.MustCompile(`(?i)\sid\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+)
+
+// anchorHeadings adds ids to h2 to h4 headings of the HTML without ids and returns the table of contents.
+// Ids already in the HTML are kept and not used again.
+func anchorHeadings(content string) (string, []TocEntry) {
+	used := make(map[string]bool)
+	for _, match := range tocIdPattern.FindAllStringSubmatch(content, -1) {
+		used[match[1]+match[2]] = true
+	}
+
+	headings := make([]TocEntry, 0)
+	content = tocHeadingPattern.ReplaceAllStringFunc(content, func(heading string) string {
+		match := tocHeadingPattern.FindStringSubmatch(heading)
+		if match[1] != match[4] {
+			return heading
+		}
+
+		level, _ := strconv.Atoi(match[1])
+		attrs := match[2]
+		title := htmlToText(match[3])
+		if len(title) == 0 {
+			return heading
+		}
+
+		anchor := ""
+		if id := tocIdPattern.FindStringSubmatch(attrs); id != nil {
+			anchor = id[1] + id[2]
+		} else {
+			anchor = uniqueAnchor(headingAnchor(title), used)
+			attrs = fmt.Sprintf(` id="%v"`, anchor) + attrs
+		}
+
+		headings = append(headings, TocEntry{Level: level, Title: title, Anchor: anchor})
+		return "<h" + match[1] + attrs + ">" + match[3] + "</h" + match[4] + ">"
+	})
+	return content, buildToc(headings)
+}
+
+// headingAnchor makes an anchor of letters, digits, "_" and "-" of the title.
+func headingAnchor(title string) string {
+	var anchor strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(title) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r) || r == '_':
+			if dash && anchor.Len() > 0 {
+				anchor.WriteRune('-')
+			}
+			dash = false
+			anchor.WriteRune(r)
+		case unicode.IsSpace(r) || r == '-':
+			dash = true
+		}
+	}
+
+	if anchor.Len() == 0 {
+		return "section"
+	}
+	return anchor.String()
+}
+
+func uniqueAnchor(anchor string, used map[string]bool) string {
+	unique := anchor
+	for i := 1; used[unique]; i++ {
+		unique = fmt.Sprintf("%v-%v", anchor, i)
+	}
+	used[unique] = true
+	return unique
+}
+
+// buildToc nests headings under the previous heading of a higher level.
+func buildToc(headings []TocEntry) []TocEntry {
+	toc := make([]TocEntry, 0)
+	for i := 0; i < len(headings); {
+		entry := headings[i]
+		next := i + 1
+		for next < len(headings) && headings[next].Level > entry.Level {
+			next++
+		}
+		entry.Children = buildToc(headings[i+1 : next])
+		toc = append(toc, entry)
+		i = next
+	}
+	return toc
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHeadingAnchor(t *testing.T) {
+	tests := []struct {
+		title  string
+		anchor string
+	}{
+		{"설치 방법", "설치-방법"},
+		{"Go 1.10 설치하기!", "go-110-설치하기"},
+		{"리눅스 & macOS", "리눅스-macos"},
+		{"  앞뒤 공백  ", "앞뒤-공백"},
+		{"snake_case - kebab-case", "snake_case-kebab-case"},
+		{"???", "section"},
+		{"", "section"},
+	}
+
+	for _, test := range tests {
+		if anchor := headingAnchor(test.title); anchor != test.anchor {
+			t.Errorf("%q: expected %q, got %q", test.title, test.anchor, anchor)
+		}
+	}
+}
+
+func TestAnchorHeadings(t *testing.T) {
+	content := `<h2>설치 방법</h2><p id="설치-방법-1">본문</p>` +
+		`<h3 class="x">Go &amp; 설치</h3><h3>설치 방법</h3>` +
+		`<h2 id="custom">직접 지정한 앵커</h2><h2>custom</h2><h2>설치 방법</h2>` +
+		`<h5>무시</h5><h2></h2><h2>짝이 안 맞는 태그</h3>`
+
+	expectedContent := `<h2 id="설치-방법">설치 방법</h2><p id="설치-방법-1">본문</p>` +
+		`<h3 id="go-설치" class="x">Go &amp; 설치</h3><h3 id="설치-방법-2">설치 방법</h3>` +
+		`<h2 id="custom">직접 지정한 앵커</h2><h2 id="custom-1">custom</h2><h2 id="설치-방법-3">설치 방법</h2>` +
+		`<h5>무시</h5><h2></h2><h2>짝이 안 맞는 태그</h3>`
+
+	expectedToc := []TocEntry{
+		{Level: 2, Title: "설치 방법", Anchor: "설치-방법", Children: []TocEntry{
+			{Level: 3, Title: "Go & 설치", Anchor: "go-설치", Children: []TocEntry{}},
+			{Level: 3, Title: "설치 방법", Anchor: "설치-방법-2", Children: []TocEntry{}},
+		}},
+		{Level: 2, Title: "직접 지정한 앵커", Anchor: "custom", Children: []TocEntry{}},
+		{Level: 2, Title: "custom", Anchor: "custom-1", Children: []TocEntry{}},
+		{Level: 2, Title: "설치 방법", Anchor: "설치-방법-3", Children: []TocEntry{}},
+	}
+
+	anchored, toc := anchorHeadings(content)
+	if anchored != expectedContent {
+		t.Errorf("expected content\n%v\ngot\n%v", expectedContent, anchored)
+	}
+	if !reflect.DeepEqual(toc, expectedToc) {
+		t.Errorf("expected toc\n%+v\ngot\n%+v", expectedToc, toc)
+	}
+
+	// anchors depend only on the content
+	if again, _ := anchorHeadings(content); again != anchored {
+		t.Error("expected the same anchors again")
+	}
+}
+
+func TestBuildTocSkippedLevels(t *testing.T) {
+	headings := []TocEntry{
+		{Level: 4, Title: "a"},
+		{Level: 2, Title: "b"},
+		{Level: 4, Title: "c"},
+		{Level: 3, Title: "d"},
+		{Level: 4, Title: "e"},
+		{Level: 2, Title: "f"},
+	}
+
+	expected := []TocEntry{
+		{Level: 4, Title: "a", Children: []TocEntry{}},
+		{Level: 2, Title: "b", Children: []TocEntry{
+			{Level: 4, Title: "c", Children: []TocEntry{}},
+			{Level: 3, Title: "d", Children: []TocEntry{
+				{Level: 4, Title: "e", Children: []TocEntry{}},
+			}},
+		}},
+		{Level: 2, Title: "f", Children: []TocEntry{}},
+	}
+
+	if toc := buildToc(headings); !reflect.DeepEqual(toc, expected) {
+		t.Errorf("expected\n%+v\ngot\n%+v", expected, toc)
+	}
+}
@@ -0,0 +1,575 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-xorm/xorm"
+)
+
+// SQL(MySQL, SQLite, PostgreSQL) implementation of the repositories on WordPress tables.
+// Names in raw SQL are quoted by the dialect. Names given to Table, Join and In are quoted by xorm.
+
+var (
+	postColumns     = []string{"ID", "post_author", "post_content", "post_title", "post_date", "post_name", "guid", "post_excerpt"}
+	postListColumns = []string{"ID", "post_author", "post_content", "post_title", "post_date", "post_name"}
+	authorColumns   = []string{"ID", "user_login", "display_name", "user_url", "user_email"}
+)
+
+func newDBEngine(dbConfig DBConfig) (*xorm.Engine, error) {
+	db, err := xorm.NewEngine(dbConfig.Driver, dbConfig.Conn)
+	if err != nil {
+		return nil, err
+	}
+	db.ShowSQL(dbConfig.ShowSQL)
+	db.SetMaxOpenConns(dbConfig.MaxOpenConns)
+	db.SetMaxIdleConns(dbConfig.MaxIdleConns)
+	db.SetConnMaxLifetime(dbConfig.ConnMaxLifetime())
+
+	return db, nil
+}
+
+type xormStorage struct {
+	engine   *xorm.Engine
+	replicas *replicaSet
+	dialect  sqlDialect
+}
+
+// Open reads from a healthy replica if readOnly. It falls back to the primary if no replica is healthy.
+func (s *xormStorage) Open(config *Config, readOnly bool) (*Store, func()) {
+	primary := s.engine.NewSession()
+
+	if readOnly && s.replicas != nil {
+		if replicaEngine := s.replicas.Next(); replicaEngine != nil {
+			session := replicaEngine.NewSession()
+			return NewXormStore(session, primary, s.dialect, config), func() {
+				session.Close()
+				primary.Close()
+			}
+		}
+	}
+
+	return NewXormStore(primary, primary, s.dialect, config), primary.Close
+}
+
+func (s *xormStorage) Close() error {
+	if s.replicas != nil {
+		s.replicas.Close()
+	}
+	return s.engine.Close()
+}
+
+// NewXormStore makes a store which reads by session and writes by primary.
+func NewXormStore(session *xorm.Session, primary *xorm.Session, dialect sqlDialect, config *Config) *Store {
+	base := xormRepository{session: session, primary: primary, dialect: dialect, config: config}
+	return &Store{
+		Posts:           &xormPostRepository{base},
+		Terms:           &xormTermRepository{base},
+		Authors:         &xormAuthorRepository{base},
+		SitePreferences: &xormSitePreferenceRepository{base},
+		ExternalMetas:   &xormExternalMetaRepository{base},
+	}
+}
+
+type xormRepository struct {
+	session *xorm.Session
+	primary *xorm.Session
+	dialect sqlDialect
+	config  *Config
+}
+
+// table returns the name of a WordPress table(posts, users, ...) with the configured prefix.
+func (r xormRepository) table(name string) string {
+	return r.config.TablePrefix + name
+}
+
+// apiTable returns the name of a table owned by this API(site_prefs, post_external_metas, ...).
+func (r xormRepository) apiTable(name string) string {
+	return r.config.ApiTablePrefix + name
+}
+
+// quote quotes a name or a qualified name(table.column) for raw SQL.
+func (r xormRepository) quote(name string) string {
+	return r.dialect.quote(name)
+}
+
+// columns returns the quoted columns for Select, qualified by the table if it is not empty.
+func (r xormRepository) columns(table string, names ...string) string {
+	quoted := make([]string, 0, len(names))
+	for _, name := range names {
+		if len(table) > 0 {
+			name = table + "." + name
+		}
+		quoted = append(quoted, r.quote(name))
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func (r xormRepository) orderBy(order PostOrder) string {
+	if order == ORDER_BY_RANDOM {
+		return r.dialect.Random
+	}
+	return r.quote(r.table("posts")+".post_date") + " desc, " + r.quote(r.table("posts")+".ID") + " desc"
+}
+
+// findRange finds posts of the range by the query on the posts table.
+// Posts before a cursor are found in ascending order and reversed.
+func (r xormRepository) findRange(query *xorm.Session, order PostOrder, postRange PostRange) ([]Post, error) {
+	var posts []Post
+
+	cursor := postRange.Cursor
+	if cursor == nil || order == ORDER_BY_RANDOM {
+		if err := query.OrderBy(r.orderBy(order)).Limit(postRange.Limit, postRange.Offset).Find(&posts); err != nil {
+			return nil, err
+		}
+		return posts, nil
+	}
+
+	postDate, postId := r.quote(r.table("posts")+".post_date"), r.quote(r.table("posts")+".ID")
+	date := cursor.Date.Format(CURSOR_DATE_FORMAT)
+	if cursor.Prev {
+		query = query.And(fmt.Sprintf("(%[1]v > ? OR (%[1]v = ? AND %[2]v > ?))", postDate, postId), date, date, cursor.ID).
+			OrderBy(fmt.Sprintf("%v asc, %v asc", postDate, postId))
+	} else {
+		query = query.And(fmt.Sprintf("(%[1]v < ? OR (%[1]v = ? AND %[2]v < ?))", postDate, postId), date, date, cursor.ID).
+			OrderBy(r.orderBy(order))
+	}
+
+	if err := query.Limit(postRange.Limit).Find(&posts); err != nil {
+		return nil, err
+	}
+
+	if cursor.Prev {
+		for i, j := 0, len(posts)-1; i < j; i, j = i+1, j-1 {
+			posts[i], posts[j] = posts[j], posts[i]
+		}
+	}
+	return posts, nil
+}
+
+type xormPostRepository struct {
+	xormRepository
+}
+
+func (r *xormPostRepository) FindOne(ctx context.Context, id int64, statuses ...string) (*Post, error) {
+	post := &Post{}
+
+	has, err := r.session.Table(r.table("posts")).
+		Select(r.columns("", postColumns...)).
+		In("post_status", statuses).
+		And(r.quote("post_type")+" = 'post'").
+		And(r.quote("ID")+" = ?", id).
+		OrderBy(r.quote("post_date") + " desc").
+		Get(post)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !has {
+		return nil, nil
+	}
+
+	return post, nil
+}
+
+func (r *xormPostRepository) FindByName(ctx context.Context, name string) (*Post, error) {
+	post := &Post{}
+
+	has, err := r.session.Table(r.table("posts")).
+		Select(r.columns("", postColumns...)).
+		Where(r.quote("post_status")+" = 'publish'").
+		And(r.quote("post_type")+" = 'post'").
+		And(r.quote("post_name")+" = ?", name).
+		OrderBy(r.quote("post_date") + " desc").
+		Get(post)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !has {
+		return nil, nil
+	}
+
+	return post, nil
+}
+
+func (r *xormPostRepository) FindByIds(ctx context.Context, ids []int64, postType string, postStatus string) ([]Post, error) {
+	var posts []Post
+
+	err := r.session.Table(r.table("posts")).
+		Select(r.columns("", postColumns...)).
+		Where(r.quote("post_status")+" = ?", postStatus).
+		And(r.quote("post_type")+" = ?", postType).
+		In("ID", ids).
+		Find(&posts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return posts, nil
+}
+
+func (r *xormPostRepository) FindRecent(ctx context.Context, postRange PostRange) ([]Post, error) {
+	query := r.session.Table(r.table("posts")).
+		Select(r.columns(r.table("posts"), postListColumns...)).
+		Where(r.quote("post_status") + " = 'publish'").
+		And(r.quote("post_type") + " = 'post'")
+
+	return r.findRange(query, ORDER_BY_DATE_DESC, postRange)
+}
+
+func (r *xormPostRepository) CountPublished(ctx context.Context) (int64, error) {
+	return r.session.Table(r.table("posts")).
+		Where(r.quote("post_status") + " = 'publish'").
+		And(r.quote("post_type") + " = 'post'").
+		Count()
+}
+
+// termPosts makes a query of published posts having the term.
+func (r *xormPostRepository) termPosts(termId int, excludes []int) *xorm.Session {
+	postsTable := r.table("posts")
+	relationshipsTable := r.table("term_relationships")
+	taxonomyTable := r.table("term_taxonomy")
+
+	query := r.session.Table(postsTable).
+		Join("INNER", relationshipsTable, r.quote(postsTable+".ID")+" = "+r.quote(relationshipsTable+".object_id")).
+		Join("INNER", taxonomyTable, r.quote(taxonomyTable+".term_taxonomy_id")+" = "+r.quote(relationshipsTable+".term_taxonomy_id")).
+		Where(r.quote(postsTable+".post_status")+" = 'publish'").
+		And(r.quote(postsTable+".post_type")+" = 'post'").
+		And(r.quote(taxonomyTable+".term_id")+" = ?", termId)
+
+	if len(excludes) > 0 {
+		query = query.NotIn(postsTable+".ID", excludes)
+	}
+	return query
+}
+
+func (r *xormPostRepository) FindByTerm(ctx context.Context, termId int, excludes []int, order PostOrder, postRange PostRange) ([]Post, error) {
+	query := r.termPosts(termId, excludes).
+		Select(r.columns(r.table("posts"), postListColumns...))
+
+	return r.findRange(query, order, postRange)
+}
+
+func (r *xormPostRepository) CountByTerm(ctx context.Context, termId int, excludes []int) (int64, error) {
+	return r.termPosts(termId, excludes).Count()
+}
+
+// authorPosts makes a query of published posts written by the author.
+func (r *xormPostRepository) authorPosts(authorId int64, excludes []int) *xorm.Session {
+	postsTable := r.table("posts")
+	usersTable := r.table("users")
+
+	query := r.session.Table(postsTable).
+		Join("INNER", usersTable, r.quote(postsTable+".post_author")+" = "+r.quote(usersTable+".ID")).
+		Where(r.quote(postsTable+".post_status")+" = 'publish'").
+		And(r.quote(postsTable+".post_type")+" = 'post'").
+		And(r.quote(postsTable+".post_author")+" = ?", authorId)
+
+	if len(excludes) > 0 {
+		query = query.NotIn(postsTable+".ID", excludes)
+	}
+	return query
+}
+
+func (r *xormPostRepository) FindByAuthor(ctx context.Context, authorId int64, excludes []int, order PostOrder, postRange PostRange) ([]Post, error) {
+	query := r.authorPosts(authorId, excludes).
+		Select(r.columns(r.table("posts"), postListColumns...))
+
+	return r.findRange(query, order, postRange)
+}
+
+func (r *xormPostRepository) CountByAuthor(ctx context.Context, authorId int64, excludes []int) (int64, error) {
+	return r.authorPosts(authorId, excludes).Count()
+}
+
+func (r *xormPostRepository) FindIdsByTerms(ctx context.Context, taxonomy string) (map[int][]int64, error) {
+	query := fmt.Sprintf(`
+		SELECT d.term_id, f.%[4]v AS post_id
+		FROM %[1]v c
+			JOIN %[2]v d ON c.term_taxonomy_id = d.term_taxonomy_id
+			JOIN %[3]v f on f.%[4]v = c.object_id and f.post_status = 'publish' and f.post_type = 'post'
+		WHERE d.taxonomy = ?
+		ORDER BY f.%[4]v
+	`, r.quote(r.table("term_relationships")), r.quote(r.table("term_taxonomy")), r.quote(r.table("posts")), r.quote("ID"))
+
+	results, err := r.session.SQL(query, taxonomy).QueryString()
+	if err != nil {
+		return nil, err
+	}
+
+	idsByTerm := make(map[int][]int64)
+	for _, eachResult := range results {
+		termId, _ := strconv.Atoi(eachResult["term_id"])
+		postId, _ := strconv.ParseInt(eachResult["post_id"], 10, 64)
+		idsByTerm[termId] = append(idsByTerm[termId], postId)
+	}
+
+	return idsByTerm, nil
+}
+
+func (r *xormPostRepository) FindIdsByAuthors(ctx context.Context) (map[int64][]int64, error) {
+	var posts []Post
+
+	err := r.session.Table(r.table("posts")).
+		Select(r.columns("", "ID", "post_author")).
+		Where(r.quote("post_status") + " = 'publish'").
+		And(r.quote("post_type") + " = 'post'").
+		OrderBy(r.quote("ID")).
+		Find(&posts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	idsByAuthor := make(map[int64][]int64)
+	for _, each := range posts {
+		idsByAuthor[each.AuthorID] = append(idsByAuthor[each.AuthorID], each.ID)
+	}
+
+	return idsByAuthor, nil
+}
+
+func (r *xormPostRepository) FindMetasByPosts(ctx context.Context, postIds []int64, keys ...string) (map[int64][]PostMeta, error) {
+	var postMetas []PostMeta
+
+	err := r.session.Table(r.table("postmeta")).
+		Select(r.columns("", "post_id", "meta_key", "meta_value")).
+		In("post_id", postIds).
+		In("meta_key", keys).
+		Find(&postMetas)
+
+	if err != nil {
+		return nil, err
+	}
+
+	metasByPost := make(map[int64][]PostMeta)
+	for _, each := range postMetas {
+		metasByPost[each.PostID] = append(metasByPost[each.PostID], each)
+	}
+
+	return metasByPost, nil
+}
+
+func (r *xormPostRepository) UpdateFacebookLike(ctx context.Context, postId int64, likes int) error {
+	var facebookLike FacebookLike
+	table := r.apiTable("facebook_like")
+
+	has, err := r.primary.Table(table).Where(r.quote("post_id")+" = ?", postId).Get(&facebookLike)
+	if err != nil {
+		return err
+	}
+
+	if has {
+		fmt.Println("Update likes: id=", facebookLike.Id, ", likes=", likes)
+		facebookLike.Likes = likes
+		_, err := r.primary.Table(table).Where(r.quote("id")+" = ?", facebookLike.Id).Update(facebookLike)
+		if err != nil {
+			return err
+		}
+	} else {
+		fmt.Println("Insert likes: post_id=", postId, ", likes=", likes)
+		facebookLike.PostId = postId
+		facebookLike.Likes = likes
+		_, err := r.primary.Table(table).Insert(facebookLike)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type xormTermRepository struct {
+	xormRepository
+}
+
+// postTerm is a term with the post it belongs to.
+type postTerm struct {
+	PostID int64 `xorm:"object_id"`
+	Term   `xorm:"extends"`
+}
+
+func (r *xormTermRepository) FindByPosts(ctx context.Context, postIds []int64) (map[int64][]Term, error) {
+	var postTerms []postTerm
+
+	termsTable := r.table("terms")
+	taxonomyTable := r.table("term_taxonomy")
+	relationshipsTable := r.table("term_relationships")
+
+	err := r.session.Table(termsTable).
+		Select(r.columns(relationshipsTable, "object_id")+", "+r.columns(termsTable, "term_id", "name", "slug")+", "+r.columns(taxonomyTable, "taxonomy")).
+		Join("INNER", taxonomyTable, r.quote(termsTable+".term_id")+" = "+r.quote(taxonomyTable+".term_id")).
+		Join("INNER", relationshipsTable, r.quote(taxonomyTable+".term_taxonomy_id")+" = "+r.quote(relationshipsTable+".term_taxonomy_id")).
+		In(relationshipsTable+".object_id", postIds).
+		Find(&postTerms)
+
+	if err != nil {
+		return nil, err
+	}
+
+	termsByPost := make(map[int64][]Term)
+	for _, each := range postTerms {
+		termsByPost[each.PostID] = append(termsByPost[each.PostID], each.Term)
+	}
+
+	return termsByPost, nil
+}
+
+func (r *xormTermRepository) FindBySlug(ctx context.Context, slug string, taxonomy string) (*Term, error) {
+	var term Term
+
+	termsTable := r.table("terms")
+	taxonomyTable := r.table("term_taxonomy")
+
+	has, err := r.session.Table(termsTable).
+		Select(r.columns(termsTable, "term_id", "name", "slug")+", "+r.columns(taxonomyTable, "taxonomy")).
+		Join("INNER", taxonomyTable, r.termTaxonomyJoin(termsTable, taxonomyTable), taxonomy).
+		Where(r.quote(termsTable+".slug")+" = ?", slug).Get(&term)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !has {
+		return nil, nil
+	}
+
+	return &term, nil
+}
+
+func (r *xormTermRepository) FindByIds(ctx context.Context, ids []int, taxonomy string) ([]Term, error) {
+	var terms []Term
+
+	termsTable := r.table("terms")
+	taxonomyTable := r.table("term_taxonomy")
+
+	err := r.session.Table(termsTable).
+		Select(r.columns(termsTable, "term_id", "name", "slug")+", "+r.columns(taxonomyTable, "taxonomy")).
+		Join("INNER", taxonomyTable, r.termTaxonomyJoin(termsTable, taxonomyTable), taxonomy).
+		In(termsTable+".term_id", ids).
+		Find(&terms)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return terms, nil
+}
+
+// termTaxonomyJoin is the condition joining terms to their taxonomy given as the parameter.
+func (r *xormTermRepository) termTaxonomyJoin(termsTable string, taxonomyTable string) string {
+	return fmt.Sprintf("%v = %v and %v = ?", r.quote(termsTable+".term_id"), r.quote(taxonomyTable+".term_id"), r.quote(taxonomyTable+".taxonomy"))
+}
+
+type xormAuthorRepository struct {
+	xormRepository
+}
+
+func (r *xormAuthorRepository) FindOne(ctx context.Context, id int64) (*Author, error) {
+	var author Author
+
+	exists, err := r.session.Table(r.table("users")).
+		Select(r.columns("", authorColumns...)).
+		Where(r.quote("ID")+" = ?", id).Get(&author)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		return nil, nil
+	}
+
+	return &author, nil
+}
+
+func (r *xormAuthorRepository) FindByIds(ctx context.Context, ids []int64) ([]Author, error) {
+	var authors []Author
+
+	err := r.session.Table(r.table("users")).
+		Select(r.columns("", authorColumns...)).
+		In("ID", ids).Find(&authors)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return authors, nil
+}
+
+func (r *xormAuthorRepository) FindByLoginName(ctx context.Context, loginName string) (*Author, error) {
+	var author Author
+
+	exists, err := r.session.Table(r.table("users")).
+		Select(r.columns("", authorColumns...)).
+		Where(r.quote("user_login")+" = ?", loginName).Get(&author)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		return nil, nil
+	}
+
+	return &author, nil
+}
+
+type xormSitePreferenceRepository struct {
+	xormRepository
+}
+
+func (r *xormSitePreferenceRepository) FindByName(ctx context.Context, name string) (*SitePreference, error) {
+	sitePref := SitePreference{Name: name}
+
+	has, err := r.session.Table(r.apiTable("site_prefs")).Get(&sitePref)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !has {
+		return nil, nil
+	}
+
+	return &sitePref, nil
+}
+
+func (r *xormSitePreferenceRepository) FindByNames(ctx context.Context, names []string) ([]SitePreference, error) {
+	sitePrefs := make([]SitePreference, 0)
+	if len(names) == 0 {
+		return sitePrefs, nil
+	}
+
+	err := r.session.Table(r.apiTable("site_prefs")).In("name", names).Find(&sitePrefs)
+	if err != nil {
+		return nil, err
+	}
+	return sitePrefs, nil
+}
+
+type xormExternalMetaRepository struct {
+	xormRepository
+}
+
+func (r *xormExternalMetaRepository) FindByPosts(ctx context.Context, postIds []int64) (map[int64][]PostExternalMeta, error) {
+	var metas []PostExternalMeta
+
+	err := r.session.Table(r.apiTable("post_external_metas")).In("post_id", postIds).Find(&metas)
+
+	if err != nil {
+		return nil, err
+	}
+
+	metasByPost := make(map[int64][]PostExternalMeta)
+	for _, each := range metas {
+		metasByPost[each.PostId] = append(metasByPost[each.PostId], each)
+	}
+
+	return metasByPost, nil
+}